@@ -0,0 +1,38 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// GraceContext returns a context which isn't canceled with input context,
+// but only once grace period is elapsed after input context cancellation.
+//
+// It allows in-flight gitlab api calls (e.g. deletions) to finish when a run is canceled.
+// The returned cancel function must be called to release associated resources.
+func GraceContext(ctx context.Context, grace time.Duration) (context.Context, context.CancelFunc) {
+	graceCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	stop := context.AfterFunc(ctx, func() {
+		timer := time.NewTimer(grace)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-graceCtx.Done():
+		}
+		cancel()
+	})
+	return graceCtx, func() {
+		stop()
+		cancel()
+	}
+}
+
+// Interrupted returns an error when input context was canceled (e.g. on SIGINT or SIGTERM),
+// in which case the run was interrupted and its summary is only partial.
+func Interrupted(ctx context.Context) error {
+	if ctx.Err() == nil {
+		return nil
+	}
+	return fmt.Errorf("run interrupted: %w", context.Cause(ctx))
+}
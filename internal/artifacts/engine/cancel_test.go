@@ -0,0 +1,84 @@
+package engine_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/artifacts/engine"
+	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/testutils"
+)
+
+func TestGraceContext(t *testing.T) {
+	t.Run("success_not_canceled", func(t *testing.T) {
+		// Arrange
+		ctx, cancel := context.WithCancel(t.Context())
+		defer cancel()
+
+		// Act
+		graceCtx, release := engine.GraceContext(ctx, time.Minute)
+		defer release()
+		cancel()
+
+		// Assert
+		testutils.NoError(t, graceCtx.Err())
+	})
+
+	t.Run("success_canceled_after_grace_period", func(t *testing.T) {
+		// Arrange
+		ctx, cancel := context.WithCancel(t.Context())
+
+		// Act
+		graceCtx, release := engine.GraceContext(ctx, 10*time.Millisecond)
+		defer release()
+		cancel()
+
+		// Assert
+		<-graceCtx.Done()
+		testutils.ErrorIs(t, graceCtx.Err(), context.Canceled)
+	})
+
+	t.Run("success_released", func(t *testing.T) {
+		// Act
+		graceCtx, release := engine.GraceContext(t.Context(), time.Minute)
+		release()
+
+		// Assert
+		testutils.ErrorIs(t, graceCtx.Err(), context.Canceled)
+	})
+
+	t.Run("success_values", func(t *testing.T) {
+		// Arrange
+		ctx := context.WithValue(t.Context(), engine.FailuresKey, &engine.Failures{})
+
+		// Act
+		graceCtx, release := engine.GraceContext(ctx, time.Minute)
+		defer release()
+
+		// Assert
+		testutils.NotNil(t, engine.GetFailures(graceCtx))
+	})
+}
+
+func TestInterrupted(t *testing.T) {
+	t.Run("success_not_interrupted", func(t *testing.T) {
+		// Act
+		err := engine.Interrupted(t.Context())
+
+		// Assert
+		testutils.NoError(t, err)
+	})
+
+	t.Run("success_interrupted", func(t *testing.T) {
+		// Arrange
+		ctx, cancel := context.WithCancel(t.Context())
+		cancel()
+
+		// Act
+		err := engine.Interrupted(ctx)
+
+		// Assert
+		testutils.ErrorIs(t, err, context.Canceled)
+		testutils.Equal(t, "run interrupted: context canceled", err.Error())
+	})
+}
@@ -0,0 +1,235 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// checkpointInterval is the minimal duration between two state file writes,
+// it avoids rewriting the whole state file for every listed page when a lot of projects are processed concurrently.
+const checkpointInterval = time.Second
+
+// Checkpoint records the progress of a run (completed projects and last listed jobs page of each project)
+// into a state file, allowing a crashed or interrupted run to be resumed where it stopped.
+//
+// A state file can be shared by multiple runs (e.g. configuration rules), each one with its own scope.
+//
+// All methods are no-op on nil Checkpoint.
+type Checkpoint struct {
+	path  string
+	scope string
+
+	// readOnly is truthy during dry runs, no progress is recorded since nothing is deleted
+	readOnly bool
+
+	err    error
+	failed map[int64]struct{}
+	mutex  sync.Mutex
+	saved  time.Time
+	state  checkpointState
+}
+
+// checkpointState is the content of a state file.
+type checkpointState struct {
+	Scopes map[string]map[int64]*projectCheckpoint `json:"scopes"`
+}
+
+// projectCheckpoint is the progress of a project in a state file.
+type projectCheckpoint struct {
+	Completed bool  `json:"completed,omitempty"`
+	JobsPage  int64 `json:"jobs_page,omitempty"`
+}
+
+// OpenCheckpoint returns the Checkpoint associated to run options state file, nil when no state file is provided.
+//
+// Without resume, the progress of run options scope is reset. With force rescan, completed projects are processed again.
+//
+// In dry run mode, the state file is only read (e.g. to preview a resumed run) and never written,
+// as such a dry run doesn't mark projects as completed while nothing was deleted.
+func (ro RunOptions) OpenCheckpoint() (*Checkpoint, error) {
+	if ro.StateFile == "" {
+		return nil, nil //nolint:nilnil
+	}
+
+	c := &Checkpoint{path: ro.StateFile, scope: ro.StateScope, readOnly: ro.DryRun, failed: map[int64]struct{}{}}
+	bytes, err := os.ReadFile(ro.StateFile)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("read state file: %w", err)
+	}
+	if len(bytes) > 0 {
+		if err := json.Unmarshal(bytes, &c.state); err != nil {
+			return nil, fmt.Errorf("invalid state file '%s': %w", ro.StateFile, err)
+		}
+	}
+	if c.state.Scopes == nil {
+		c.state.Scopes = map[string]map[int64]*projectCheckpoint{}
+	}
+
+	projects := c.state.Scopes[c.scope]
+	if projects == nil || !ro.Resume {
+		projects = map[int64]*projectCheckpoint{}
+	}
+	if ro.ForceRescan {
+		for id, project := range projects {
+			if project.Completed {
+				delete(projects, id)
+			}
+		}
+	}
+	c.state.Scopes[c.scope] = projects
+	if c.readOnly {
+		return c, nil
+	}
+
+	// state file is written right away to report an invalid path before starting the run
+	if err := c.save(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Completed returns truthy if input project was completed in a previous run.
+func (c *Checkpoint) Completed(projectID int64) bool {
+	if c == nil {
+		return false
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	project, ok := c.state.Scopes[c.scope][projectID]
+	return ok && project.Completed
+}
+
+// JobsPage returns the last listed jobs page of input project, zero when unknown.
+func (c *Checkpoint) JobsPage(projectID int64) int64 {
+	if c == nil {
+		return 0
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if project, ok := c.state.Scopes[c.scope][projectID]; ok {
+		return project.JobsPage
+	}
+	return 0
+}
+
+// SetJobsPage records the last listed jobs page of input project.
+func (c *Checkpoint) SetJobsPage(projectID, page int64) {
+	if c == nil || c.readOnly {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.project(projectID).JobsPage = page
+	c.throttledSave()
+}
+
+// Fail marks input project as failed (e.g. its jobs couldn't be listed), as such it can't be completed during this run.
+func (c *Checkpoint) Fail(projectID int64) {
+	if c == nil {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.failed[projectID] = struct{}{}
+}
+
+// Complete records input project as completed, unless it was marked as failed.
+func (c *Checkpoint) Complete(projectID int64) {
+	if c == nil || c.readOnly {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if _, ok := c.failed[projectID]; ok {
+		return
+	}
+	c.project(projectID).Completed = true
+	c.throttledSave()
+}
+
+// Close writes the state file a last time and returns the first error which occurred while writing it.
+func (c *Checkpoint) Close() error {
+	if c == nil || c.readOnly {
+		return nil
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if err := c.save(); err != nil && c.err == nil {
+		c.err = err
+	}
+	return c.err
+}
+
+// project returns the progress of input project in checkpoint scope, creating it if needed.
+func (c *Checkpoint) project(projectID int64) *projectCheckpoint {
+	projects := c.state.Scopes[c.scope]
+	project, ok := projects[projectID]
+	if !ok {
+		project = &projectCheckpoint{}
+		projects[projectID] = project
+	}
+	return project
+}
+
+// throttledSave writes the state file unless it was already written less than checkpointInterval ago.
+func (c *Checkpoint) throttledSave() {
+	if time.Since(c.saved) < checkpointInterval {
+		return
+	}
+	if err := c.save(); err != nil && c.err == nil {
+		c.err = err
+	}
+}
+
+// save writes the state file atomically (into a temporary file renamed afterwards),
+// as such a crash while writing it doesn't corrupt it.
+func (c *Checkpoint) save() error {
+	bytes, err := json.Marshal(c.state)
+	if err != nil {
+		return fmt.Errorf("marshal state: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(c.path), filepath.Base(c.path)+".*")
+	if err != nil {
+		return fmt.Errorf("write state file: %w", err)
+	}
+	defer os.Remove(tmp.Name()) // no-op once renamed
+
+	if _, err := tmp.Write(bytes); err != nil {
+		tmp.Close()
+		return fmt.Errorf("write state file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("write state file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), c.path); err != nil {
+		return fmt.Errorf("write state file: %w", err)
+	}
+	c.saved = time.Now()
+	return nil
+}
+
+type checkpointKeyType string
+
+// CheckpointKey is the context key for run checkpoint.
+const CheckpointKey checkpointKeyType = "checkpoint"
+
+// GetCheckpoint returns the context checkpoint.
+//
+// It returns nil when the context has no checkpoint, in which case the run progress isn't recorded.
+func GetCheckpoint(ctx context.Context) *Checkpoint {
+	checkpoint, _ := ctx.Value(CheckpointKey).(*Checkpoint)
+	return checkpoint
+}
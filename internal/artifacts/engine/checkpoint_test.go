@@ -0,0 +1,181 @@
+package engine_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/artifacts/engine"
+	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/testutils"
+)
+
+func TestOpenCheckpoint(t *testing.T) {
+	open := func(t *testing.T, opts ...engine.RunOption) *engine.Checkpoint {
+		t.Helper()
+		ro, err := engine.NewRunOptions(append(opts, engine.WithThresholdDuration(time.Hour))...)
+		testutils.NoError(testutils.Require(t), err)
+		checkpoint, err := ro.OpenCheckpoint()
+		testutils.NoError(testutils.Require(t), err)
+		return checkpoint
+	}
+
+	t.Run("success_no_state_file", func(t *testing.T) {
+		// Act
+		checkpoint := open(t)
+
+		// Assert
+		testutils.True(t, checkpoint == nil)
+		checkpoint.Complete(1) // no-op on nil checkpoint
+		testutils.False(t, checkpoint.Completed(1))
+		testutils.NoError(t, checkpoint.Close())
+	})
+
+	t.Run("error_invalid_state_file", func(t *testing.T) {
+		// Arrange
+		path := filepath.Join(t.TempDir(), "state.json")
+		testutils.NoError(testutils.Require(t), os.WriteFile(path, []byte("invalid"), 0o600))
+
+		ro, err := engine.NewRunOptions(engine.WithStateFile(path), engine.WithThresholdDuration(time.Hour))
+		testutils.NoError(testutils.Require(t), err)
+
+		// Act
+		_, err = ro.OpenCheckpoint()
+
+		// Assert
+		testutils.Error(testutils.Require(t), err)
+		testutils.Contains(t, err.Error(), "invalid state file")
+	})
+
+	t.Run("error_unwritable_state_file", func(t *testing.T) {
+		// Arrange
+		path := filepath.Join(t.TempDir(), "missing", "state.json")
+
+		ro, err := engine.NewRunOptions(engine.WithStateFile(path), engine.WithThresholdDuration(time.Hour))
+		testutils.NoError(testutils.Require(t), err)
+
+		// Act
+		_, err = ro.OpenCheckpoint()
+
+		// Assert
+		testutils.Error(testutils.Require(t), err)
+		testutils.Contains(t, err.Error(), "write state file")
+	})
+
+	t.Run("success_resume", func(t *testing.T) {
+		// Arrange
+		path := filepath.Join(t.TempDir(), "state.json")
+
+		previous := open(t, engine.WithStateFile(path))
+		previous.Complete(1)
+		previous.SetJobsPage(2, 5)
+		previous.Fail(3)
+		previous.Complete(3)
+		testutils.NoError(testutils.Require(t), previous.Close())
+
+		// Act
+		checkpoint := open(t, engine.WithStateFile(path), engine.WithResume(true))
+
+		// Assert
+		testutils.True(t, checkpoint.Completed(1))
+		testutils.False(t, checkpoint.Completed(2))
+		testutils.Equal(t, 5, checkpoint.JobsPage(2))
+		testutils.False(t, checkpoint.Completed(3))
+		testutils.NoError(t, checkpoint.Close())
+	})
+
+	t.Run("success_not_resumed", func(t *testing.T) {
+		// Arrange
+		path := filepath.Join(t.TempDir(), "state.json")
+
+		previous := open(t, engine.WithStateFile(path))
+		previous.Complete(1)
+		testutils.NoError(testutils.Require(t), previous.Close())
+
+		// Act
+		checkpoint := open(t, engine.WithStateFile(path))
+
+		// Assert
+		testutils.False(t, checkpoint.Completed(1))
+		testutils.NoError(t, checkpoint.Close())
+	})
+
+	t.Run("success_dry_run", func(t *testing.T) {
+		// Arrange
+		path := filepath.Join(t.TempDir(), "state.json")
+
+		previous := open(t, engine.WithStateFile(path))
+		previous.Complete(1)
+		testutils.NoError(testutils.Require(t), previous.Close())
+
+		dryRun := open(t, engine.WithStateFile(path), engine.WithResume(true), engine.WithDryRun(true))
+		dryRun.Complete(2)
+		dryRun.SetJobsPage(3, 5)
+		testutils.NoError(testutils.Require(t), dryRun.Close())
+
+		// Act
+		checkpoint := open(t, engine.WithStateFile(path), engine.WithResume(true))
+
+		// Assert
+		testutils.True(t, dryRun.Completed(1))
+		testutils.False(t, dryRun.Completed(2))
+		testutils.True(t, checkpoint.Completed(1))
+		testutils.False(t, checkpoint.Completed(2))
+		testutils.Equal(t, 0, checkpoint.JobsPage(3))
+		testutils.NoError(t, checkpoint.Close())
+	})
+
+	t.Run("success_dry_run_no_state_file", func(t *testing.T) {
+		// Arrange
+		path := filepath.Join(t.TempDir(), "state.json")
+
+		// Act
+		checkpoint := open(t, engine.WithStateFile(path), engine.WithDryRun(true))
+
+		// Assert
+		checkpoint.Complete(1)
+		testutils.NoError(t, checkpoint.Close())
+		_, err := os.Stat(path)
+		testutils.ErrorIs(t, err, os.ErrNotExist)
+	})
+
+	t.Run("success_force_rescan", func(t *testing.T) {
+		// Arrange
+		path := filepath.Join(t.TempDir(), "state.json")
+
+		previous := open(t, engine.WithStateFile(path))
+		previous.SetJobsPage(1, 5)
+		previous.Complete(1)
+		previous.SetJobsPage(2, 3)
+		testutils.NoError(testutils.Require(t), previous.Close())
+
+		// Act
+		checkpoint := open(t, engine.WithStateFile(path), engine.WithResume(true), engine.WithForceRescan(true))
+
+		// Assert
+		testutils.False(t, checkpoint.Completed(1))
+		testutils.Equal(t, 0, checkpoint.JobsPage(1))
+		testutils.Equal(t, 3, checkpoint.JobsPage(2))
+		testutils.NoError(t, checkpoint.Close())
+	})
+
+	t.Run("success_scopes", func(t *testing.T) {
+		// Arrange
+		path := filepath.Join(t.TempDir(), "state.json")
+
+		first := open(t, engine.WithStateFile(path), engine.WithStateScope("first"))
+		first.Complete(1)
+		testutils.NoError(testutils.Require(t), first.Close())
+
+		second := open(t, engine.WithStateFile(path), engine.WithStateScope("second"))
+		testutils.False(t, second.Completed(1))
+		testutils.NoError(testutils.Require(t), second.Close())
+
+		// Act
+		checkpoint := open(t, engine.WithStateFile(path), engine.WithStateScope("first"), engine.WithResume(true))
+
+		// Assert
+		testutils.True(t, checkpoint.Completed(1))
+		testutils.NoError(t, checkpoint.Close())
+	})
+}
@@ -0,0 +1,293 @@
+package engine
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"time"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// ConfigVersion is the current (and only supported) version of configuration files.
+const ConfigVersion = 1
+
+// Config represents a configuration file with multiple rule blocks,
+// each one of them being mapped onto its own run options.
+type Config struct {
+	// Version is the configuration file version, it must be ConfigVersion.
+	Version int `yaml:"version"`
+
+	// Rules is the list of rule blocks to run one after the other.
+	Rules []ConfigRule `yaml:"rules"`
+}
+
+// ConfigRule represents a rule block of a configuration file.
+//
+// Unset values are inherited from other run options (e.g. command line flags).
+type ConfigRule struct {
+	// Archived is the way archived projects are considered for cleaning with this rule.
+	Archived ArchivedMode `yaml:"archived"`
+
+	// ArchivedOnlyThresholdDuration is the duration threshold of archived projects with 'only' archived mode for this rule.
+	ArchivedOnlyThresholdDuration time.Duration `yaml:"archived-only-threshold-duration"`
+
+	// DryRun is a flag to enable dry-run mode for this rule.
+	//
+	// It can only enable dry-run mode, a dry run requested by other run options (e.g. command line flag) is never disabled.
+	DryRun *bool `yaml:"dry-run"`
+
+	// ExcludePaths is a list of paths (regexps or raw paths) to exclude projects from cleaning with this rule.
+	ExcludePaths []string `yaml:"exclude-paths"`
+
+	// Groups is a list of groups (IDs or paths with namespace) to read projects from with this rule.
+	Groups []string `yaml:"groups"`
+
+	// IdleDuration is the minimum duration since projects last activity for this rule.
+	IdleDuration time.Duration `yaml:"idle-duration"`
+
+	// IncludeArchivedThresholdDuration is the duration threshold of archived projects with 'include' archived mode for this rule.
+	IncludeArchivedThresholdDuration time.Duration `yaml:"include-archived-threshold-duration"`
+
+	// Jobs represents filters on jobs to consider for artifacts cleaning with this rule.
+	Jobs *JobFilters `yaml:"jobs"`
+
+	// KeepLatestBy is the way latest successful jobs' artifacts are kept for this rule.
+	KeepLatestBy KeepLatestBy `yaml:"keep-latest-by"`
+
+	// MaxArtifactsSize is the storage budget of each project jobs' artifacts for this rule.
+	MaxArtifactsSize Size `yaml:"max-artifacts-size"`
+
+	// Name is an optional name to identify the rule in logs.
+	Name string `yaml:"name"`
+
+	// Paths is a list of paths (regexps or raw paths) to filter projects to clean with this rule.
+	Paths []string `yaml:"paths"`
+
+	// Policies is the ordered list of retention policies for this rule.
+	Policies []Policy `yaml:"policies"`
+
+	// ProtectedThresholdDuration is the duration threshold of jobs ran on protected branches and tags for this rule.
+	ProtectedThresholdDuration time.Duration `yaml:"protected-threshold-duration"`
+
+	// ThresholdDuration is the duration threshold for this rule.
+	ThresholdDuration time.Duration `yaml:"threshold-duration"`
+
+	// Topics is a list of topics projects must all have to be cleaned with this rule.
+	Topics []string `yaml:"topics"`
+
+	// Visibilities is a list of visibility levels projects must have one of to be cleaned with this rule.
+	Visibilities []string `yaml:"visibilities"`
+}
+
+// LoadConfig reads and validates the configuration file at input path.
+//
+// Both YAML and JSON files are supported since JSON is a subset of YAML.
+func LoadConfig(path string) (Config, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, fmt.Errorf("read file: %w", err)
+	}
+
+	config, err := ParseConfig(b)
+	if err != nil {
+		return Config{}, fmt.Errorf("parse '%s': %w", path, err)
+	}
+	return config, nil
+}
+
+// ParseConfig decodes and validates input configuration bytes.
+//
+// Unknown fields are rejected and all errors are prefixed with the line they were found at.
+func ParseConfig(b []byte) (Config, error) {
+	var config Config
+	decoder := yaml.NewDecoder(bytes.NewReader(b))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&config); err != nil {
+		if errors.Is(err, io.EOF) {
+			return Config{}, errors.New("empty configuration")
+		}
+		return Config{}, err
+	}
+
+	// decode a second time into a node to retrieve lines for validation errors,
+	// no error can happen since the same bytes were already decoded successfully
+	var root yaml.Node
+	_ = yaml.Unmarshal(b, &root)
+	return config, config.validate(root.Content[0])
+}
+
+// validate checks the configuration and its rules, node being the document root node.
+func (c Config) validate(node *yaml.Node) error {
+	var errs []error
+	if c.Version != ConfigVersion {
+		errs = append(errs, fmt.Errorf("line %d: unsupported version '%d', expected '%d'", keyLine(node, "version"), c.Version, ConfigVersion))
+	}
+	if len(c.Rules) == 0 {
+		errs = append(errs, fmt.Errorf("line %d: at least one rule is required", keyLine(node, "rules")))
+	}
+
+	if rules := keyValue(node, "rules"); rules != nil {
+		for i, rule := range c.Rules {
+			errs = append(errs, rule.validate(rules.Content[i]))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Options returns the run options associated to the rule.
+//
+// Those options are meant to be appended after other ones (e.g. command line flags) to override them.
+func (r ConfigRule) Options() []RunOption {
+	var opts []RunOption
+	if len(r.Paths) > 0 {
+		opts = append(opts, WithPaths(r.Paths...))
+	}
+	if r.Archived != "" {
+		opts = append(opts, WithArchivedMode(r.Archived))
+	}
+	if r.ArchivedOnlyThresholdDuration > 0 {
+		opts = append(opts, WithArchivedOnlyThresholdDuration(r.ArchivedOnlyThresholdDuration))
+	}
+	if r.IncludeArchivedThresholdDuration > 0 {
+		opts = append(opts, WithIncludeArchivedThresholdDuration(r.IncludeArchivedThresholdDuration))
+	}
+	if r.DryRun != nil && *r.DryRun {
+		opts = append(opts, WithDryRun(true))
+	}
+	if len(r.ExcludePaths) > 0 {
+		opts = append(opts, WithExcludePaths(r.ExcludePaths...))
+	}
+	if len(r.Groups) > 0 {
+		opts = append(opts, WithGroups(r.Groups...))
+	}
+	if r.IdleDuration > 0 {
+		opts = append(opts, WithIdleDuration(r.IdleDuration))
+	}
+	if r.Jobs != nil {
+		opts = append(opts, WithJobFilters(*r.Jobs))
+	}
+	if r.KeepLatestBy != "" {
+		opts = append(opts, WithKeepLatestJobsBy(r.KeepLatestBy))
+	}
+	if r.MaxArtifactsSize > 0 {
+		opts = append(opts, WithMaxArtifactsSize(r.MaxArtifactsSize))
+	}
+	if len(r.Policies) > 0 {
+		opts = append(opts, WithPolicies(r.Policies...))
+	}
+	if r.ProtectedThresholdDuration > 0 {
+		opts = append(opts, WithProtectedThresholdDuration(r.ProtectedThresholdDuration))
+	}
+	if r.ThresholdDuration > 0 {
+		opts = append(opts, WithThresholdDuration(r.ThresholdDuration))
+	}
+	if len(r.Topics) > 0 {
+		opts = append(opts, WithTopics(r.Topics...))
+	}
+	if len(r.Visibilities) > 0 {
+		opts = append(opts, WithVisibilities(r.Visibilities...))
+	}
+	return opts
+}
+
+// validate checks the rule values, node being the rule mapping node.
+func (r ConfigRule) validate(node *yaml.Node) error {
+	var errs []error
+	if len(r.Paths) == 0 && len(r.Groups) == 0 {
+		errs = append(errs, fmt.Errorf("line %d: at least one path or group is required", keyLine(node, "paths")))
+	}
+	errs = append(errs, validateRegexps(keyValue(node, "paths"), r.Paths))
+	errs = append(errs, validateRegexps(keyValue(node, "exclude-paths"), r.ExcludePaths))
+	if r.Jobs != nil {
+		if _, err := r.Jobs.compile(); err != nil {
+			errs = append(errs, fmt.Errorf("line %d: invalid job filters: %w", keyLine(node, "jobs"), err))
+		}
+	}
+	if r.KeepLatestBy != "" {
+		if err := r.KeepLatestBy.validate(); err != nil {
+			errs = append(errs, fmt.Errorf("line %d: %w", keyLine(node, "keep-latest-by"), err))
+		}
+	}
+	if r.Archived != "" {
+		if err := r.Archived.validate(); err != nil {
+			errs = append(errs, fmt.Errorf("line %d: %w", keyLine(node, "archived"), err))
+		}
+	}
+	if r.ArchivedOnlyThresholdDuration < 0 {
+		errs = append(errs, fmt.Errorf("line %d: invalid archived only threshold duration '%s'", keyLine(node, "archived-only-threshold-duration"), r.ArchivedOnlyThresholdDuration))
+	}
+	if r.IncludeArchivedThresholdDuration < 0 {
+		errs = append(errs, fmt.Errorf("line %d: invalid include archived threshold duration '%s'", keyLine(node, "include-archived-threshold-duration"), r.IncludeArchivedThresholdDuration))
+	}
+	if r.IdleDuration < 0 {
+		errs = append(errs, fmt.Errorf("line %d: invalid idle duration '%s'", keyLine(node, "idle-duration"), r.IdleDuration))
+	}
+	if r.ProtectedThresholdDuration < 0 {
+		errs = append(errs, fmt.Errorf("line %d: invalid protected threshold duration '%s'", keyLine(node, "protected-threshold-duration"), r.ProtectedThresholdDuration))
+	}
+	if r.ThresholdDuration < 0 {
+		errs = append(errs, fmt.Errorf("line %d: invalid threshold duration '%s'", keyLine(node, "threshold-duration"), r.ThresholdDuration))
+	}
+	if visibilities := keyValue(node, "visibilities"); visibilities != nil {
+		for i, visibility := range r.Visibilities {
+			if err := validateVisibility(visibility); err != nil {
+				errs = append(errs, fmt.Errorf("line %d: %w", visibilities.Content[i].Line, err))
+			}
+		}
+	}
+	if policies := keyValue(node, "policies"); policies != nil {
+		for i, policy := range r.Policies {
+			// threshold duration is only validated, it will be defaulted when building run options
+			if _, err := policy.compile(time.Nanosecond); err != nil {
+				errs = append(errs, fmt.Errorf("line %d: invalid policy: %w", policies.Content[i].Line, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// validateRegexps checks that all input values are valid regexps, node being the sequence node holding them.
+func validateRegexps(node *yaml.Node, values []string) error {
+	if node == nil {
+		return nil
+	}
+	var errs []error
+	for i, value := range values {
+		if _, err := regexp.Compile(value); err != nil {
+			errs = append(errs, fmt.Errorf("line %d: invalid regexp '%s': %w", node.Content[i].Line, value, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// keyValue returns the value node associated to input key in mapping node.
+//
+// It returns nil if node isn't a mapping or if key isn't present.
+func keyValue(node *yaml.Node, key string) *yaml.Node {
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// keyLine returns the line of input key in mapping node,
+// or the line of the mapping node itself when key isn't present.
+func keyLine(node *yaml.Node, key string) int {
+	if node.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value == key {
+				return node.Content[i].Line
+			}
+		}
+	}
+	return node.Line
+}
@@ -0,0 +1,277 @@
+package engine_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/artifacts/engine"
+	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/testutils"
+)
+
+func TestLoadConfig(t *testing.T) {
+	t.Run("error_not_found", func(t *testing.T) {
+		// Arrange
+		path := filepath.Join(t.TempDir(), "config.yml")
+
+		// Act
+		_, err := engine.LoadConfig(path)
+
+		// Assert
+		testutils.Error(testutils.Require(t), err)
+		testutils.Contains(t, err.Error(), "read file")
+	})
+
+	t.Run("success_yaml", func(t *testing.T) {
+		// Arrange
+		path := filepath.Join(t.TempDir(), "config.yml")
+		content := `version: 1
+rules:
+  - name: group
+    paths: ["^group/.*$"]
+    dry-run: true
+    keep-latest-by: ref
+    max-artifacts-size: 5GiB
+    threshold-duration: 72h
+    policies:
+      - name: releases
+        paths: ["^group/releases/"]
+        job-names: ["^build$"]
+        keep-count: 3
+        threshold-duration: 2160h
+  - paths: ["^other/.*$"]
+  - groups: [other]
+`
+		testutils.NoError(testutils.Require(t), os.WriteFile(path, []byte(content), 0o600))
+
+		// Act
+		config, err := engine.LoadConfig(path)
+
+		// Assert
+		testutils.NoError(testutils.Require(t), err)
+		testutils.Equal(testutils.Require(t), 3, len(config.Rules))
+
+		ro, err := engine.NewRunOptions(append([]engine.RunOption{engine.WithThresholdDuration(time.Hour)}, config.Rules[0].Options()...)...)
+		testutils.NoError(testutils.Require(t), err)
+		testutils.True(t, ro.DryRun)
+		testutils.Equal(t, engine.KeepLatestRef, ro.KeepLatestJobs)
+		testutils.Equal(t, 5<<30, ro.MaxArtifactsSize)
+		testutils.Equal(t, 72*time.Hour, ro.ThresholdDuration)
+		testutils.Equal(t, "^group/.*$", ro.Paths[0])
+		policy := ro.Policy("group/releases/app")
+		testutils.Equal(t, "releases", policy.Name)
+		testutils.Equal(t, 3, policy.KeepCount)
+		testutils.Equal(t, 2160*time.Hour, policy.ThresholdDuration)
+
+		ro, err = engine.NewRunOptions(append([]engine.RunOption{engine.WithDryRun(true), engine.WithThresholdDuration(time.Hour)}, config.Rules[1].Options()...)...)
+		testutils.NoError(testutils.Require(t), err)
+		testutils.True(t, ro.DryRun)                        // inherited
+		testutils.Equal(t, time.Hour, ro.ThresholdDuration) // inherited
+		testutils.Equal(t, "^other/.*$", ro.Paths[0])
+
+		ro, err = engine.NewRunOptions(append([]engine.RunOption{engine.WithPaths("^group/"), engine.WithThresholdDuration(time.Hour)}, config.Rules[2].Options()...)...)
+		testutils.NoError(testutils.Require(t), err)
+		testutils.Equal(t, "^group/", ro.Paths[0]) // inherited
+		testutils.Equal(t, "other", ro.Groups[0])
+	})
+
+	t.Run("success_dry_run_not_disabled", func(t *testing.T) {
+		// Arrange
+		path := filepath.Join(t.TempDir(), "config.yml")
+		content := `version: 1
+rules:
+  - paths: ["^group/.*$"]
+    dry-run: false
+`
+		testutils.NoError(testutils.Require(t), os.WriteFile(path, []byte(content), 0o600))
+
+		config, err := engine.LoadConfig(path)
+		testutils.NoError(testutils.Require(t), err)
+
+		// Act
+		ro, err := engine.NewRunOptions(append([]engine.RunOption{engine.WithDryRun(true), engine.WithThresholdDuration(time.Hour)}, config.Rules[0].Options()...)...)
+
+		// Assert
+		testutils.NoError(testutils.Require(t), err)
+		testutils.True(t, ro.DryRun) // command line dry run is kept
+	})
+
+	t.Run("success_json", func(t *testing.T) {
+		// Arrange
+		path := filepath.Join(t.TempDir(), "config.json")
+		content := `{"version": 1, "rules": [{"paths": ["^group/.*$"], "dry-run": false, "threshold-duration": "24h"}]}`
+		testutils.NoError(testutils.Require(t), os.WriteFile(path, []byte(content), 0o600))
+
+		// Act
+		config, err := engine.LoadConfig(path)
+
+		// Assert
+		testutils.NoError(testutils.Require(t), err)
+		testutils.Equal(testutils.Require(t), 1, len(config.Rules))
+		testutils.False(t, *config.Rules[0].DryRun)
+		testutils.Equal(t, 24*time.Hour, config.Rules[0].ThresholdDuration)
+	})
+}
+
+func TestParseConfig(t *testing.T) {
+	t.Run("error_empty", func(t *testing.T) {
+		// Act
+		_, err := engine.ParseConfig(nil)
+
+		// Assert
+		testutils.Error(testutils.Require(t), err)
+		testutils.Contains(t, err.Error(), "empty configuration")
+	})
+
+	t.Run("error_unknown_field", func(t *testing.T) {
+		// Arrange
+		content := `version: 1
+rules:
+  - paths: ["^group/.*$"]
+    unknown: value
+`
+
+		// Act
+		_, err := engine.ParseConfig([]byte(content))
+
+		// Assert
+		testutils.Error(testutils.Require(t), err)
+		testutils.Contains(t, err.Error(), "line 4: field unknown not found")
+	})
+
+	t.Run("error_invalid_type", func(t *testing.T) {
+		// Arrange
+		content := `version: 1
+rules:
+  - paths: ["^group/.*$"]
+    threshold-duration: invalid
+`
+
+		// Act
+		_, err := engine.ParseConfig([]byte(content))
+
+		// Assert
+		testutils.Error(testutils.Require(t), err)
+		testutils.Contains(t, err.Error(), "line 4:")
+	})
+
+	t.Run("error_invalid_size", func(t *testing.T) {
+		// Arrange
+		content := `version: 1
+rules:
+  - paths: ["^group/.*$"]
+    max-artifacts-size: 5 parsecs
+`
+
+		// Act
+		_, err := engine.ParseConfig([]byte(content))
+
+		// Assert
+		testutils.Error(testutils.Require(t), err)
+		testutils.Contains(t, err.Error(), "line 4: invalid size '5 parsecs'")
+	})
+
+	t.Run("error_invalid_version_and_rules", func(t *testing.T) {
+		// Arrange
+		content := `version: 2
+rules: []
+`
+
+		// Act
+		_, err := engine.ParseConfig([]byte(content))
+
+		// Assert
+		testutils.Error(testutils.Require(t), err)
+		testutils.Contains(t, err.Error(), "line 1: unsupported version '2', expected '1'")
+		testutils.Contains(t, err.Error(), "line 2: at least one rule is required")
+	})
+
+	t.Run("error_invalid_rules", func(t *testing.T) {
+		// Arrange
+		content := `version: 1
+rules:
+  - name: no paths
+    threshold-duration: -1h
+  - paths:
+      - ^valid$
+      - /\/\
+    keep-latest-by: invalid
+    policies:
+      - paths: ["^valid$"]
+      - keep-count: -1
+`
+
+		// Act
+		_, err := engine.ParseConfig([]byte(content))
+
+		// Assert
+		testutils.Error(testutils.Require(t), err)
+		testutils.Contains(t, err.Error(), "line 3: at least one path or group is required")
+		testutils.Contains(t, err.Error(), "line 4: invalid threshold duration '-1h0m0s'")
+		testutils.Contains(t, err.Error(), `line 7: invalid regexp '/\/\'`)
+		testutils.Contains(t, err.Error(), "line 8: invalid keep latest mode 'invalid'")
+		testutils.Contains(t, err.Error(), "line 11: invalid policy: at least one path is required")
+		testutils.Contains(t, err.Error(), "invalid keep count '-1'")
+		testutils.NotContains(t, err.Error(), "line 10")
+	})
+	t.Run("error_invalid_exclude_paths", func(t *testing.T) {
+		// Arrange
+		content := `version: 1
+rules:
+  - paths: ["^group/"]
+    exclude-paths:
+      - ^group/valid$
+      - ^group/(invalid$
+`
+
+		// Act
+		_, err := engine.ParseConfig([]byte(content))
+
+		// Assert
+		testutils.Error(testutils.Require(t), err)
+		testutils.Contains(t, err.Error(), "line 6: invalid regexp '^group/(invalid$'")
+	})
+	t.Run("error_invalid_project_filters", func(t *testing.T) {
+		// Arrange
+		content := `version: 1
+rules:
+  - paths: ["^group/"]
+    idle-duration: -24h
+    visibilities: [private, secret]
+    archived: never
+    protected-threshold-duration: -1h
+    include-archived-threshold-duration: -2h
+`
+
+		// Act
+		_, err := engine.ParseConfig([]byte(content))
+
+		// Assert
+		testutils.Error(testutils.Require(t), err)
+		testutils.Contains(t, err.Error(), "line 4: invalid idle duration '-24h0m0s'")
+		testutils.Contains(t, err.Error(), "line 5: invalid visibility 'secret'")
+		testutils.Contains(t, err.Error(), "line 6: invalid archived mode 'never'")
+		testutils.Contains(t, err.Error(), "line 7: invalid protected threshold duration '-1h0m0s'")
+		testutils.Contains(t, err.Error(), "line 8: invalid include archived threshold duration '-2h0m0s'")
+	})
+	t.Run("error_invalid_job_filters", func(t *testing.T) {
+		// Arrange
+		content := `version: 1
+rules:
+  - paths: ["^group/"]
+    jobs:
+      names: ["^test:("]
+      statuses: [success, done]
+`
+
+		// Act
+		_, err := engine.ParseConfig([]byte(content))
+
+		// Assert
+		testutils.Error(testutils.Require(t), err)
+		testutils.Contains(t, err.Error(), "line 4: invalid job filters")
+		testutils.Contains(t, err.Error(), "invalid regexp '^test:('")
+		testutils.Contains(t, err.Error(), "invalid job status 'done'")
+	})
+}
@@ -0,0 +1,65 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Failures collects the errors occurring during a run.
+//
+// Those errors are logged and don't stop the run (e.g. a project jobs can't be listed),
+// they're collected to be returned once the run is done.
+type Failures struct {
+	errs  []error
+	mutex sync.Mutex
+}
+
+type failuresKeyType string
+
+// FailuresKey is the context key for run failures.
+const FailuresKey failuresKeyType = "failures"
+
+// GetFailures returns the context failures.
+//
+// It returns nil when the context has no failures, in which case errors aren't collected.
+func GetFailures(ctx context.Context) *Failures {
+	failures, _ := ctx.Value(FailuresKey).(*Failures)
+	return failures
+}
+
+// Add adds the input error to failures.
+//
+// It's a no-op on nil Failures.
+func (f *Failures) Add(err error) {
+	if f == nil || err == nil {
+		return
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.errs = append(f.errs, err)
+}
+
+// Len returns the number of collected errors.
+func (f *Failures) Len() int {
+	if f == nil {
+		return 0
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return len(f.errs)
+}
+
+// Err returns all collected errors joined together, nil when there's none.
+func (f *Failures) Err() error {
+	if f == nil {
+		return nil
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if len(f.errs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%d failure(s) during run: %w", len(f.errs), errors.Join(f.errs...))
+}
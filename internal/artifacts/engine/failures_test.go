@@ -0,0 +1,55 @@
+package engine_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/artifacts/engine"
+	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/testutils"
+)
+
+func TestFailures(t *testing.T) {
+	t.Run("success_no_failures", func(t *testing.T) {
+		// Arrange
+		failures := engine.GetFailures(t.Context())
+
+		// Act
+		failures.Add(errors.New("an error"))
+
+		// Assert
+		testutils.Equal(t, 0, failures.Len())
+		testutils.NoError(t, failures.Err())
+	})
+
+	t.Run("success_empty", func(t *testing.T) {
+		// Arrange
+		failures := &engine.Failures{}
+
+		// Act
+		failures.Add(nil)
+
+		// Assert
+		testutils.Equal(t, 0, failures.Len())
+		testutils.NoError(t, failures.Err())
+	})
+
+	t.Run("success_joined", func(t *testing.T) {
+		// Arrange
+		target := errors.New("second error")
+		ctx := context.WithValue(t.Context(), engine.FailuresKey, &engine.Failures{})
+
+		// Act
+		engine.GetFailures(ctx).Add(errors.New("first error"))
+		engine.GetFailures(ctx).Add(target)
+
+		// Assert
+		failures := engine.GetFailures(ctx)
+		testutils.Equal(t, 2, failures.Len())
+		err := failures.Err()
+		testutils.Error(testutils.Require(t), err)
+		testutils.Contains(t, err.Error(), "2 failure(s) during run")
+		testutils.Contains(t, err.Error(), "first error")
+		testutils.ErrorIs(t, err, target)
+	})
+}
@@ -0,0 +1,116 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/artifacts/models"
+)
+
+// JobFilters represents filters on jobs to consider for artifacts cleaning.
+type JobFilters struct {
+	// ArtifactTypes is a list of artifacts file types (e.g. 'archive', 'junit') allowed for deletion,
+	// a job having an artifact with another type is never cleaned (when not empty).
+	//
+	// GitLab can't delete job artifacts by type, see models.Job MatchesArtifactTypes for more information.
+	ArtifactTypes []string `yaml:"artifact-types"`
+
+	// ExcludeNames is a list of regexps on job name, a job matching any of them is never cleaned.
+	ExcludeNames []string `yaml:"exclude-names"`
+
+	// ExcludeRefs is a list of regexps on job ref, a job matching any of them is never cleaned.
+	ExcludeRefs []string `yaml:"exclude-refs"`
+
+	// ExcludeStages is a list of regexps on job stage, a job matching any of them is never cleaned.
+	ExcludeStages []string `yaml:"exclude-stages"`
+
+	// Names is a list of regexps on job name, a job must match one of them to be cleaned (when not empty).
+	Names []string `yaml:"names"`
+
+	// Refs is a list of regexps on job ref, a job must match one of them to be cleaned (when not empty).
+	Refs []string `yaml:"refs"`
+
+	// Stages is a list of regexps on job stage, a job must match one of them to be cleaned (when not empty).
+	Stages []string `yaml:"stages"`
+
+	// Statuses is a list of job statuses (e.g. 'failed', 'success', 'canceled') to consider for cleaning.
+	//
+	// Default statuses are 'failed' and 'success'.
+	Statuses []string `yaml:"statuses"`
+
+	filter models.JobFilter
+}
+
+// WithJobFilters sets the jobs filters in run options.
+//
+// Examples:
+//
+//	Given the job filters names are '^test:'
+//	And the threshold duration is 1 day
+//	Then artifacts of 'test:coverage' jobs older than 1 day will be deleted
+//	And artifacts of 'build:linux' jobs will never be deleted
+//
+//	Given the job filters exclude refs are '^main$'
+//	Then artifacts of jobs ran on 'main' ref will never be deleted
+//
+//	Given the job filters artifact types are 'archive'
+//	Then artifacts of jobs only having an 'archive' artifact will be deleted
+//	And artifacts of jobs having both 'archive' and 'junit' artifacts will never be deleted
+func WithJobFilters(filters JobFilters) RunOption {
+	return func(o RunOptions) RunOptions {
+		o.JobFilters = filters
+		return o
+	}
+}
+
+// Filter returns the compiled jobs filter.
+func (f JobFilters) Filter() models.JobFilter {
+	return f.filter
+}
+
+// compile compiles the jobs filters regexps and validates statuses.
+func (f JobFilters) compile() (JobFilters, error) {
+	var errs []error
+	compile := func(patterns []string) []*regexp.Regexp {
+		regexps := make([]*regexp.Regexp, 0, len(patterns))
+		for _, pattern := range patterns {
+			reg, err := regexp.Compile(pattern)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("invalid regexp '%s': %w", pattern, err))
+				continue
+			}
+			regexps = append(regexps, reg)
+		}
+		return regexps
+	}
+
+	f.filter = models.JobFilter{
+		ExcludeNames:  compile(f.ExcludeNames),
+		ExcludeRefs:   compile(f.ExcludeRefs),
+		ExcludeStages: compile(f.ExcludeStages),
+		Names:         compile(f.Names),
+		Refs:          compile(f.Refs),
+		Stages:        compile(f.Stages),
+	}
+
+	if len(f.Statuses) == 0 {
+		f.Statuses = []string{"failed", "success"}
+	}
+	for _, status := range f.Statuses {
+		if err := validateJobStatus(status); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return f, errors.Join(errs...)
+}
+
+// validateJobStatus returns an error when the status isn't a known gitlab job status.
+func validateJobStatus(status string) error {
+	switch status {
+	case "canceled", "created", "failed", "manual", "pending", "preparing", "running", "scheduled", "skipped", "success", "waiting_for_resource":
+		return nil
+	default:
+		return fmt.Errorf("invalid job status '%s'", status)
+	}
+}
@@ -0,0 +1,137 @@
+package packages
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/fogfactory/pipe"
+	"github.com/samber/lo"
+	gitlab "gitlab.com/gitlab-org/api/client-go/v2"
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/artifacts/engine"
+	artifacts "github.com/kilianpaquier/gitlab-storage-cleaner/internal/artifacts/engine/v2"
+	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/artifacts/models"
+)
+
+// ReadPackages returns the function to send all package versions of a given Project into pipe processing.
+//
+// Package versions are read from the most recent to the oldest one in order to keep
+// the most recent versions of each package (see engine.WithKeepVersions).
+func ReadPackages(ctx context.Context, client *gitlab.Client, runOptions engine.RunOptions) pipe.Split[artifacts.Project, models.Package] {
+	logger := engine.GetLogger(ctx)
+	return func(project artifacts.Project, in chan<- models.Package) {
+		opts := &gitlab.ListProjectPackagesOptions{
+			ListOptions: gitlab.ListOptions{
+				Page:    1,
+				PerPage: 100,
+			},
+			OrderBy: lo.ToPtr("created_at"),
+			Sort:    lo.ToPtr("desc"),
+		}
+
+		// number of versions already read per package
+		versions := map[string]int{}
+
+		// package versions are sent once all pages are read since their deletion would shift next pages
+		var outdated []models.Package
+
+		for {
+			packages, err := artifacts.Fetch(ctx, "list_project_packages", func(ctx context.Context) ([]*gitlab.Package, *gitlab.Response, error) {
+				return client.Packages.ListProjectPackages(project.ID, opts, gitlab.WithContext(ctx))
+			}, attribute.Int64("project.id", project.ID), attribute.Int64("page", opts.Page))
+			if ctx.Err() != nil {
+				return // run canceled
+			}
+			if err != nil {
+				logger.Warn("failed to retrieve project packages",
+					"error", err,
+					"project_id", project.ID,
+					"project_path", project.PathWithNamespace)
+				engine.GetFailures(ctx).Add(fmt.Errorf("project '%s': list packages: %w", project.PathWithNamespace, err))
+				break
+			}
+
+			// stop infinite loop
+			if len(packages) == 0 {
+				break
+			}
+			opts.Page++
+
+			for _, gitlab := range packages {
+				pkg := models.PackageFromGitLab(project.ID, gitlab)
+
+				versions[pkg.Key()]++
+				if versions[pkg.Key()] <= runOptions.KeepVersions {
+					logger.Debug("keeping package version",
+						"package", pkg.Key(),
+						"package_id", pkg.ID,
+						"project_id", project.ID,
+						"version", pkg.Version)
+					continue
+				}
+
+				// check that the package version needs cleanup before sending it
+				if pkg.NeedCleanup(runOptions.ProjectThreshold(project.Project)) {
+					outdated = append(outdated, pkg)
+				}
+			}
+		}
+
+		for _, pkg := range outdated {
+			select {
+			case in <- pkg:
+			case <-ctx.Done():
+				return // run canceled
+			}
+		}
+	}
+}
+
+// DeletePackage returns the function to delete a specific package version.
+//
+// Once input context is canceled, deletions not yet started are skipped
+// while in-flight ones are given run options grace period to finish.
+func DeletePackage(ctx context.Context, client *gitlab.Client, opts engine.RunOptions) pipe.Process[models.Package] {
+	return func(pkg models.Package) models.Package {
+		logger := engine.GetLogger(ctx)
+
+		if ctx.Err() != nil {
+			logger.Debug("run interrupted, skipping package version deletion",
+				"package", pkg.Key(),
+				"package_id", pkg.ID,
+				"project_id", pkg.ProjectID,
+				"version", pkg.Version)
+			return pkg
+		}
+
+		if opts.DryRun {
+			logger.Info("running in dry run mode, skipping package version deletion",
+				"package", pkg.Key(),
+				"package_id", pkg.ID,
+				"project_id", pkg.ProjectID,
+				"version", pkg.Version)
+			return pkg
+		}
+
+		graceCtx, cancel := engine.GraceContext(ctx, opts.GracePeriod)
+		defer cancel()
+
+		err := artifacts.Call(graceCtx, "delete_project_package", func(ctx context.Context) (*gitlab.Response, error) {
+			return pkg.Delete(ctx, client)
+		}, attribute.Int64("project.id", pkg.ProjectID), attribute.Int64("package.id", pkg.ID))
+		if err != nil {
+			logger.Warn("failed to delete package version",
+				"error", err,
+				"package", pkg.Key(),
+				"package_id", pkg.ID,
+				"project_id", pkg.ProjectID,
+				"version", pkg.Version)
+			engine.GetFailures(ctx).Add(fmt.Errorf("project %d: delete package %d: %w", pkg.ProjectID, pkg.ID, err))
+			return pkg
+		}
+
+		pkg.Cleaned = true
+		return pkg
+	}
+}
@@ -0,0 +1,155 @@
+package packages_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/samber/lo"
+	gitlab "gitlab.com/gitlab-org/api/client-go/v2"
+
+	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/artifacts/engine"
+	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/artifacts/engine/packages"
+	artifacts "github.com/kilianpaquier/gitlab-storage-cleaner/internal/artifacts/engine/v2"
+	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/artifacts/models"
+	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/testutils"
+)
+
+func TestReadPackages(t *testing.T) {
+	ctx := t.Context()
+
+	httpmock.Activate()
+	t.Cleanup(httpmock.DeactivateAndReset)
+
+	// setup mock client
+	client, err := gitlab.NewClient("",
+		gitlab.WithHTTPClient(&http.Client{Transport: httpmock.DefaultTransport}),
+		gitlab.WithoutRetries())
+	testutils.NoError(testutils.Require(t), err)
+
+	project := artifacts.Project{Project: models.Project{ID: 5}}
+
+	t.Run("error_list_packages", func(t *testing.T) {
+		// Arrange
+		t.Cleanup(httpmock.Reset)
+		httpmock.RegisterResponder(http.MethodGet, fmt.Sprintf(packagesURL, project.ID),
+			httpmock.NewStringResponder(http.StatusInternalServerError, "an error"))
+
+		var buf strings.Builder
+		ctx := context.WithValue(ctx, engine.LoggerKey, engine.NewTestLogger(&buf))
+
+		// Act
+		packages.ReadPackages(ctx, client, engine.RunOptions{})(project, nil)
+
+		// Assert
+		logs := buf.String()
+		testutils.Contains(t, logs, "an error")
+		testutils.Contains(t, logs, "failed to retrieve project packages")
+	})
+
+	t.Run("success_populate_channel", func(t *testing.T) {
+		// Arrange
+		now := time.Now()
+
+		t.Cleanup(httpmock.Reset)
+		httpmock.RegisterResponder(http.MethodGet, fmt.Sprintf(packagesURL, project.ID),
+			httpmock.NewJsonResponderOrPanic(http.StatusOK, []*gitlab.Package{
+				{ID: 1, Name: "app", PackageType: "generic", Version: "4", CreatedAt: lo.ToPtr(now.Add(-2 * time.Hour))},
+				{ID: 2, Name: "app", PackageType: "generic", Version: "3", CreatedAt: lo.ToPtr(now.Add(-3 * time.Hour))},
+			}).Then(httpmock.NewJsonResponderOrPanic(http.StatusOK, []*gitlab.Package{
+				{ID: 3, Name: "app", PackageType: "generic", Version: "2", CreatedAt: lo.ToPtr(now.Add(-4 * time.Hour))},
+				{ID: 4, Name: "lib", PackageType: "generic", Version: "1", CreatedAt: lo.ToPtr(now.Add(-5 * time.Hour))},
+			})).Then(httpmock.NewJsonResponderOrPanic(http.StatusOK, []*gitlab.Package{})))
+
+		ro, _ := engine.NewRunOptions(engine.WithKeepVersions(2), engine.WithThresholdDuration(time.Hour))
+
+		var buf strings.Builder
+		ctx := context.WithValue(ctx, engine.LoggerKey, engine.NewTestLogger(&buf))
+
+		pkgs := make(chan models.Package, 10)
+		t.Cleanup(func() { close(pkgs) })
+
+		// Act
+		packages.ReadPackages(ctx, client, ro)(project, pkgs)
+
+		// Assert
+		testutils.Equal(testutils.Require(t), 1, len(pkgs)) // only third 'app' version, other ones are kept
+		testutils.Equal(t, int64(3), (<-pkgs).ID)
+		testutils.Equal(t, 3, httpmock.GetTotalCallCount())
+		logs := buf.String()
+		testutils.Contains(t, logs, "keeping package version package=generic/app package_id=1")
+		testutils.Contains(t, logs, "keeping package version package=generic/app package_id=2")
+		testutils.Contains(t, logs, "keeping package version package=generic/lib package_id=4")
+	})
+}
+
+func TestDeletePackage(t *testing.T) {
+	ctx := t.Context()
+
+	httpmock.Activate()
+	t.Cleanup(httpmock.DeactivateAndReset)
+
+	// setup mock client
+	client, err := gitlab.NewClient("",
+		gitlab.WithHTTPClient(&http.Client{Transport: httpmock.DefaultTransport}),
+		gitlab.WithoutRetries())
+	testutils.NoError(testutils.Require(t), err)
+
+	pkg := models.Package{ID: 7, ProjectID: 5}
+
+	t.Run("success_dry_run", func(t *testing.T) {
+		// Arrange
+		var buf strings.Builder
+		ctx := context.WithValue(ctx, engine.LoggerKey, engine.NewTestLogger(&buf))
+
+		// Act
+		pkg := packages.DeletePackage(ctx, client, engine.RunOptions{DryRun: true})(pkg)
+
+		// Assert
+		testutils.False(t, pkg.Cleaned)
+		testutils.Contains(t, buf.String(), "running in dry run mode, skipping package version deletion")
+	})
+
+	t.Run("error_delete_package", func(t *testing.T) {
+		// Arrange
+		t.Cleanup(httpmock.Reset)
+		httpmock.RegisterResponder(http.MethodDelete, fmt.Sprintf(packageURL, pkg.ProjectID, pkg.ID),
+			httpmock.NewStringResponder(http.StatusInternalServerError, "an error"))
+
+		var buf strings.Builder
+		failures := &engine.Failures{}
+		ctx := context.WithValue(ctx, engine.LoggerKey, engine.NewTestLogger(&buf))
+		ctx = context.WithValue(ctx, engine.FailuresKey, failures)
+
+		// Act
+		pkg := packages.DeletePackage(ctx, client, engine.RunOptions{})(pkg)
+
+		// Assert
+		testutils.False(t, pkg.Cleaned)
+		testutils.Equal(t, 1, failures.Len())
+		logs := buf.String()
+		testutils.Contains(t, logs, "an error")
+		testutils.Contains(t, logs, "failed to delete package version")
+	})
+
+	t.Run("success_delete_package", func(t *testing.T) {
+		// Arrange
+		t.Cleanup(httpmock.Reset)
+		httpmock.RegisterResponder(http.MethodDelete, fmt.Sprintf(packageURL, pkg.ProjectID, pkg.ID),
+			httpmock.NewStringResponder(http.StatusNoContent, ""))
+
+		var buf strings.Builder
+		ctx := context.WithValue(ctx, engine.LoggerKey, engine.NewTestLogger(&buf))
+
+		// Act
+		pkg := packages.DeletePackage(ctx, client, engine.RunOptions{})(pkg)
+
+		// Assert
+		testutils.Equal(t, "", buf.String())
+		testutils.True(t, pkg.Cleaned)
+	})
+}
@@ -0,0 +1,30 @@
+package packages
+
+import (
+	"context"
+
+	gitlab "gitlab.com/gitlab-org/api/client-go/v2"
+
+	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/artifacts/engine"
+	artifacts "github.com/kilianpaquier/gitlab-storage-cleaner/internal/artifacts/engine/v2"
+	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/artifacts/models"
+)
+
+// Run retrieves gitlab projects and filters the one not appropriate with options (paths regexps).
+//
+// For every appropriate project, it will retrieve package registry versions
+// and delete outdated ones according to input option threshold and number of versions to keep.
+// It returns the Summary of all processed projects (number of cleaned package versions)
+// along with all errors which occurred during the run (e.g. failed package versions deletions), joined together.
+//
+// When input context is canceled, no new project nor deletion is started, in-flight deletions
+// are given run options grace period to finish and an interruption error is returned.
+func Run(parent context.Context, client *gitlab.Client, opts ...engine.RunOption) (artifacts.Summary, error) {
+	return artifacts.RunCleaner(parent, client, artifacts.Cleaner[models.Package]{
+		Name:       "packages",
+		CleanedKey: "packages_cleaned",
+		Read:       ReadPackages,
+		Delete:     DeletePackage,
+		Cleaned:    func(pkg models.Package) bool { return pkg.Cleaned },
+	}, opts...)
+}
@@ -0,0 +1,122 @@
+package packages_test
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/samber/lo"
+	gitlab "gitlab.com/gitlab-org/api/client-go/v2"
+
+	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/artifacts/engine"
+	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/artifacts/engine/packages"
+	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/testutils"
+)
+
+const (
+	projectsURL = "https://gitlab.com/api/v4/projects"
+	packagesURL = "https://gitlab.com/api/v4/projects/%d/packages"
+	packageURL  = "https://gitlab.com/api/v4/projects/%d/packages/%d"
+)
+
+func TestRun(t *testing.T) {
+	now := time.Now()
+	ctx := t.Context()
+
+	httpmock.Activate()
+	t.Cleanup(httpmock.DeactivateAndReset)
+
+	// setup mock client
+	client, err := gitlab.NewClient("",
+		gitlab.WithHTTPClient(&http.Client{Transport: httpmock.DefaultTransport}),
+		gitlab.WithoutRetries(),
+	)
+	testutils.NoError(testutils.Require(t), err)
+
+	var buf strings.Builder
+	opts := []engine.RunOption{
+		engine.WithKeepVersions(1),
+		engine.WithLogger(engine.NewTestLogger(&buf)),
+		engine.WithPaths("^project_path$"),
+		engine.WithThresholdDuration(time.Hour),
+	}
+
+	t.Run("success_e2e", func(t *testing.T) {
+		// Arrange
+		t.Cleanup(httpmock.Reset)
+
+		// projects endpoint mock
+		projectID := int64(7)
+		httpmock.RegisterResponder(http.MethodGet, projectsURL,
+			httpmock.NewJsonResponderOrPanic(http.StatusOK, []*gitlab.Project{
+				{ID: projectID, PathWithNamespace: "project_path"},
+				{ID: 8, PathWithNamespace: "not_matching"},
+			}).Then(httpmock.NewJsonResponderOrPanic(http.StatusOK, []*gitlab.Project{})))
+
+		// packages endpoint mock (sorted by creation date descending)
+		packageID := int64(11)
+		httpmock.RegisterResponder(http.MethodGet, fmt.Sprintf(packagesURL, projectID),
+			httpmock.NewJsonResponderOrPanic(http.StatusOK, []*gitlab.Package{
+				{ID: 10, Name: "app", PackageType: "npm", Version: "1.0.2", CreatedAt: lo.ToPtr(now.Add(-2 * time.Hour))},        // kept as most recent version
+				{ID: packageID, Name: "app", PackageType: "npm", Version: "1.0.1", CreatedAt: lo.ToPtr(now.Add(-3 * time.Hour))}, // deleted
+				{ID: 12, Name: "app", PackageType: "maven", Version: "1.0.0", CreatedAt: lo.ToPtr(now.Add(-4 * time.Hour))},      // kept as most recent version
+			}).Then(httpmock.NewJsonResponderOrPanic(http.StatusOK, []*gitlab.Package{})))
+
+		// package deletion endpoint mock
+		httpmock.RegisterResponder(http.MethodDelete, fmt.Sprintf(packageURL, projectID, packageID),
+			httpmock.NewStringResponder(http.StatusNoContent, ""))
+
+		// expected calls to be made
+		expectedCalls := map[string]int{
+			"GET " + projectsURL:                                      2,
+			"GET " + fmt.Sprintf(packagesURL, projectID):              2,
+			"DELETE " + fmt.Sprintf(packageURL, projectID, packageID): 1,
+		}
+
+		// Act
+		summary, err := packages.Run(ctx, client, opts...)
+
+		// Assert
+		testutils.NoError(testutils.Require(t), err)
+		testutils.Equal(t, 1, summary.ItemsCleaned)
+		testutils.Equal(t, 1, summary.Projects)
+		for k, v := range expectedCalls {
+			actual, ok := httpmock.GetCallCountInfo()[k]
+			testutils.True(t, ok)
+			testutils.Equal(t, v, actual)
+		}
+		logs := buf.String()
+		testutils.Contains(t, logs, "starting project execution")
+		testutils.Contains(t, logs, "ending project execution")
+		testutils.Contains(t, logs, "packages_cleaned=1")
+		testutils.NotContains(t, logs, "failed to retrieve")
+		testutils.NotContains(t, logs, "failed to delete package version")
+	})
+
+	t.Run("error_failures", func(t *testing.T) {
+		// Arrange
+		t.Cleanup(httpmock.Reset)
+
+		// projects endpoint mock
+		projectID := int64(7)
+		httpmock.RegisterResponder(http.MethodGet, projectsURL,
+			httpmock.NewJsonResponderOrPanic(http.StatusOK, []*gitlab.Project{{ID: projectID, PathWithNamespace: "project_path"}}).
+				Then(httpmock.NewJsonResponderOrPanic(http.StatusOK, []*gitlab.Project{})))
+
+		// packages endpoint mock
+		httpmock.RegisterResponder(http.MethodGet, fmt.Sprintf(packagesURL, projectID),
+			httpmock.NewStringResponder(http.StatusForbidden, `{"message":"403 Forbidden"}`))
+
+		// Act
+		summary, err := packages.Run(ctx, client, opts...)
+
+		// Assert
+		testutils.Error(testutils.Require(t), err)
+		testutils.Equal(t, 1, summary.Failures)
+		testutils.Contains(t, err.Error(), "1 failure(s) during run")
+		testutils.Contains(t, err.Error(), "project 'project_path': list packages")
+	})
+}
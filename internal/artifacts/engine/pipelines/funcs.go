@@ -0,0 +1,186 @@
+package pipelines
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/fogfactory/pipe"
+	"github.com/samber/lo"
+	gitlab "gitlab.com/gitlab-org/api/client-go/v2"
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/artifacts/engine"
+	artifacts "github.com/kilianpaquier/gitlab-storage-cleaner/internal/artifacts/engine/v2"
+	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/artifacts/models"
+)
+
+const (
+	// scopeBranches is the pipelines scope to retrieve the latest pipeline of each branch.
+	scopeBranches = "branches"
+
+	// scopeFinished is the pipelines scope to retrieve finished pipelines (success, failed, canceled, skipped).
+	scopeFinished = "finished"
+
+	// scopeTags is the pipelines scope to retrieve the latest pipeline of each tag.
+	scopeTags = "tags"
+)
+
+// ReadPipelines returns the function to send all outdated Pipelines of a given Project into pipe processing.
+//
+// The latest pipeline of each protected branch and each tag is never sent.
+// In case those latest pipelines can't be retrieved, no pipeline is sent at all.
+func ReadPipelines(ctx context.Context, client *gitlab.Client, runOptions engine.RunOptions) pipe.Split[artifacts.Project, models.Pipeline] {
+	logger := engine.GetLogger(ctx)
+	return func(project artifacts.Project, in chan<- models.Pipeline) {
+		latest, err := readLatestPipelines(ctx, client, project.ID)
+		if ctx.Err() != nil {
+			return // run canceled
+		}
+		if err != nil {
+			logger.Warn("failed to retrieve project latest pipelines",
+				"error", err,
+				"project_id", project.ID,
+				"project_path", project.PathWithNamespace)
+			engine.GetFailures(ctx).Add(fmt.Errorf("project '%s': read latest pipelines: %w", project.PathWithNamespace, err))
+			return
+		}
+
+		// pipelines are all read before being sent since their deletion would shift next pages
+		pipelines, err := readPipelines(ctx, client, project.ID, &gitlab.ListProjectPipelinesOptions{
+			CreatedBefore: lo.ToPtr(time.Now().Add(-runOptions.ProjectThreshold(project.Project))),
+			Scope:         lo.ToPtr(scopeFinished),
+		})
+		if ctx.Err() != nil {
+			return // run canceled
+		}
+		if err != nil {
+			logger.Warn("failed to retrieve project pipelines",
+				"error", err,
+				"project_id", project.ID,
+				"project_path", project.PathWithNamespace)
+			engine.GetFailures(ctx).Add(fmt.Errorf("project '%s': list pipelines: %w", project.PathWithNamespace, err))
+		}
+
+		for _, gitlab := range pipelines {
+			pipeline := models.PipelineFromGitLab(project.ID, gitlab)
+			if _, ok := latest[pipeline.ID]; ok {
+				logger.Debug("keeping latest pipeline of protected branch or tag",
+					"pipeline_id", pipeline.ID,
+					"project_id", project.ID,
+					"ref", pipeline.Ref)
+				continue
+			}
+
+			// check that the pipeline needs cleanup before sending it
+			if !pipeline.NeedCleanup(runOptions.ProjectThreshold(project.Project)) {
+				continue
+			}
+			select {
+			case in <- pipeline:
+			case <-ctx.Done():
+				return // run canceled
+			}
+		}
+	}
+}
+
+// readLatestPipelines returns the identifiers of the latest pipeline of each protected branch and each tag of a given project.
+func readLatestPipelines(ctx context.Context, client *gitlab.Client, projectID int64) (map[int64]struct{}, error) {
+	branches, err := artifacts.ReadProtectedBranches(ctx, client, projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	tags, err := readPipelines(ctx, client, projectID, &gitlab.ListProjectPipelinesOptions{Scope: lo.ToPtr(scopeTags)})
+	if err != nil {
+		return nil, err
+	}
+
+	heads, err := readPipelines(ctx, client, projectID, &gitlab.ListProjectPipelinesOptions{Scope: lo.ToPtr(scopeBranches)})
+	if err != nil {
+		return nil, err
+	}
+
+	latest := make(map[int64]struct{}, len(tags))
+	for _, pipeline := range tags {
+		latest[pipeline.ID] = struct{}{}
+	}
+	for _, pipeline := range heads {
+		if branches.Matches(pipeline.Ref) {
+			latest[pipeline.ID] = struct{}{}
+		}
+	}
+	return latest, nil
+}
+
+// readPipelines returns all pipelines of a given project matching input options.
+//
+// In case of error, already read pipelines are returned alongside the error.
+func readPipelines(ctx context.Context, client *gitlab.Client, projectID int64, opts *gitlab.ListProjectPipelinesOptions) ([]*gitlab.PipelineInfo, error) {
+	opts.ListOptions = gitlab.ListOptions{
+		Page:    1,
+		PerPage: 100,
+	}
+
+	var result []*gitlab.PipelineInfo
+	for {
+		pipelines, err := artifacts.Fetch(ctx, "list_project_pipelines", func(ctx context.Context) ([]*gitlab.PipelineInfo, *gitlab.Response, error) {
+			return client.Pipelines.ListProjectPipelines(projectID, opts, gitlab.WithContext(ctx))
+		}, attribute.Int64("project.id", projectID), attribute.String("scope", lo.FromPtr(opts.Scope)), attribute.Int64("page", opts.Page))
+		if err != nil {
+			return result, err
+		}
+
+		// stop infinite loop
+		if len(pipelines) == 0 {
+			break
+		}
+		opts.Page++
+
+		result = append(result, pipelines...)
+	}
+	return result, nil
+}
+
+// DeletePipeline returns the function to delete a specific pipeline.
+//
+// Once input context is canceled, deletions not yet started are skipped
+// while in-flight ones are given run options grace period to finish.
+func DeletePipeline(ctx context.Context, client *gitlab.Client, opts engine.RunOptions) pipe.Process[models.Pipeline] {
+	return func(pipeline models.Pipeline) models.Pipeline {
+		logger := engine.GetLogger(ctx)
+
+		if ctx.Err() != nil {
+			logger.Debug("run interrupted, skipping pipeline deletion",
+				"pipeline_id", pipeline.ID,
+				"project_id", pipeline.ProjectID)
+			return pipeline
+		}
+
+		if opts.DryRun {
+			logger.Info("running in dry run mode, skipping pipeline deletion",
+				"pipeline_id", pipeline.ID,
+				"project_id", pipeline.ProjectID)
+			return pipeline
+		}
+
+		graceCtx, cancel := engine.GraceContext(ctx, opts.GracePeriod)
+		defer cancel()
+
+		err := artifacts.Call(graceCtx, "delete_pipeline", func(ctx context.Context) (*gitlab.Response, error) {
+			return pipeline.Delete(ctx, client)
+		}, attribute.Int64("project.id", pipeline.ProjectID), attribute.Int64("pipeline.id", pipeline.ID))
+		if err != nil {
+			logger.Warn("failed to delete pipeline",
+				"error", err,
+				"pipeline_id", pipeline.ID,
+				"project_id", pipeline.ProjectID)
+			engine.GetFailures(ctx).Add(fmt.Errorf("project %d: delete pipeline %d: %w", pipeline.ProjectID, pipeline.ID, err))
+			return pipeline
+		}
+
+		pipeline.Cleaned = true
+		return pipeline
+	}
+}
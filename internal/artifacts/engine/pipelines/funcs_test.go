@@ -0,0 +1,179 @@
+package pipelines_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/samber/lo"
+	gitlab "gitlab.com/gitlab-org/api/client-go/v2"
+
+	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/artifacts/engine"
+	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/artifacts/engine/pipelines"
+	artifacts "github.com/kilianpaquier/gitlab-storage-cleaner/internal/artifacts/engine/v2"
+	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/artifacts/models"
+	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/testutils"
+)
+
+func TestReadPipelines(t *testing.T) {
+	ctx := t.Context()
+
+	httpmock.Activate()
+	t.Cleanup(httpmock.DeactivateAndReset)
+
+	// setup mock client
+	client, err := gitlab.NewClient("",
+		gitlab.WithHTTPClient(&http.Client{Transport: httpmock.DefaultTransport}),
+		gitlab.WithoutRetries())
+	testutils.NoError(testutils.Require(t), err)
+
+	project := artifacts.Project{Project: models.Project{ID: 5}}
+
+	t.Run("error_list_protected_branches", func(t *testing.T) {
+		// Arrange
+		t.Cleanup(httpmock.Reset)
+		httpmock.RegisterResponder(http.MethodGet, fmt.Sprintf(protectedBranchesURL, project.ID),
+			httpmock.NewStringResponder(http.StatusInternalServerError, "an error"))
+
+		var buf strings.Builder
+		ctx := context.WithValue(ctx, engine.LoggerKey, engine.NewTestLogger(&buf))
+
+		// Act
+		pipelines.ReadPipelines(ctx, client, engine.RunOptions{})(project, nil)
+
+		// Assert
+		logs := buf.String()
+		testutils.Contains(t, logs, "an error")
+		testutils.Contains(t, logs, "failed to retrieve project latest pipelines")
+		testutils.Equal(t, 1, httpmock.GetTotalCallCount()) // no pipeline listing when protected refs are unknown
+	})
+
+	t.Run("error_list_pipelines", func(t *testing.T) {
+		// Arrange
+		t.Cleanup(httpmock.Reset)
+		httpmock.RegisterResponder(http.MethodGet, fmt.Sprintf(protectedBranchesURL, project.ID),
+			httpmock.NewJsonResponderOrPanic(http.StatusOK, []*gitlab.ProtectedBranch{}))
+		httpmock.RegisterResponder(http.MethodGet, fmt.Sprintf(pipelinesURL, project.ID),
+			httpmock.NewJsonResponderOrPanic(http.StatusOK, []*gitlab.PipelineInfo{}).
+				Then(httpmock.NewJsonResponderOrPanic(http.StatusOK, []*gitlab.PipelineInfo{})).
+				Then(httpmock.NewStringResponder(http.StatusInternalServerError, "an error")))
+
+		var buf strings.Builder
+		ctx := context.WithValue(ctx, engine.LoggerKey, engine.NewTestLogger(&buf))
+
+		// Act
+		pipelines.ReadPipelines(ctx, client, engine.RunOptions{})(project, nil)
+
+		// Assert
+		logs := buf.String()
+		testutils.Contains(t, logs, "an error")
+		testutils.Contains(t, logs, "failed to retrieve project pipelines")
+	})
+
+	t.Run("success_populate_channel", func(t *testing.T) {
+		// Arrange
+		now := time.Now()
+
+		t.Cleanup(httpmock.Reset)
+		httpmock.RegisterResponder(http.MethodGet, fmt.Sprintf(protectedBranchesURL, project.ID),
+			httpmock.NewJsonResponderOrPanic(http.StatusOK, []*gitlab.ProtectedBranch{{Name: "release/*"}}).
+				Then(httpmock.NewJsonResponderOrPanic(http.StatusOK, []*gitlab.ProtectedBranch{})))
+		httpmock.RegisterResponder(http.MethodGet, fmt.Sprintf(pipelinesURL, project.ID),
+			httpmock.NewJsonResponderOrPanic(http.StatusOK, []*gitlab.PipelineInfo{}). // no tags
+													Then(httpmock.NewJsonResponderOrPanic(http.StatusOK, []*gitlab.PipelineInfo{{ID: 3, Ref: "release/1.x"}})).
+													Then(httpmock.NewJsonResponderOrPanic(http.StatusOK, []*gitlab.PipelineInfo{})).
+													Then(httpmock.NewJsonResponderOrPanic(http.StatusOK, []*gitlab.PipelineInfo{
+					{ID: 3, Ref: "release/1.x", CreatedAt: lo.ToPtr(now.Add(-2 * time.Hour))},
+					{ID: 2, Ref: "release/1.x", CreatedAt: lo.ToPtr(now.Add(-3 * time.Hour))},
+					{ID: 1, Ref: "release/1.x", CreatedAt: lo.ToPtr(now)},
+				})).
+				Then(httpmock.NewJsonResponderOrPanic(http.StatusOK, []*gitlab.PipelineInfo{})))
+
+		ro, _ := engine.NewRunOptions(engine.WithThresholdDuration(time.Hour))
+
+		var buf strings.Builder
+		ctx := context.WithValue(ctx, engine.LoggerKey, engine.NewTestLogger(&buf))
+
+		out := make(chan models.Pipeline, 10)
+		t.Cleanup(func() { close(out) })
+
+		// Act
+		pipelines.ReadPipelines(ctx, client, ro)(project, out)
+
+		// Assert
+		testutils.Equal(testutils.Require(t), 1, len(out)) // latest of protected branch is kept and last one is too recent
+		testutils.Equal(t, int64(2), (<-out).ID)
+		testutils.Contains(t, buf.String(), "keeping latest pipeline of protected branch or tag pipeline_id=3 project_id=5 ref=release/1.x")
+	})
+}
+
+func TestDeletePipeline(t *testing.T) {
+	ctx := t.Context()
+
+	httpmock.Activate()
+	t.Cleanup(httpmock.DeactivateAndReset)
+
+	// setup mock client
+	client, err := gitlab.NewClient("",
+		gitlab.WithHTTPClient(&http.Client{Transport: httpmock.DefaultTransport}),
+		gitlab.WithoutRetries())
+	testutils.NoError(testutils.Require(t), err)
+
+	pipeline := models.Pipeline{ID: 7, ProjectID: 5}
+
+	t.Run("success_dry_run", func(t *testing.T) {
+		// Arrange
+		var buf strings.Builder
+		ctx := context.WithValue(ctx, engine.LoggerKey, engine.NewTestLogger(&buf))
+
+		// Act
+		pipeline := pipelines.DeletePipeline(ctx, client, engine.RunOptions{DryRun: true})(pipeline)
+
+		// Assert
+		testutils.False(t, pipeline.Cleaned)
+		testutils.Contains(t, buf.String(), "running in dry run mode, skipping pipeline deletion")
+	})
+
+	t.Run("error_delete_pipeline", func(t *testing.T) {
+		// Arrange
+		t.Cleanup(httpmock.Reset)
+		httpmock.RegisterResponder(http.MethodDelete, fmt.Sprintf(pipelineURL, pipeline.ProjectID, pipeline.ID),
+			httpmock.NewStringResponder(http.StatusInternalServerError, "an error"))
+
+		var buf strings.Builder
+		failures := &engine.Failures{}
+		ctx := context.WithValue(ctx, engine.LoggerKey, engine.NewTestLogger(&buf))
+		ctx = context.WithValue(ctx, engine.FailuresKey, failures)
+
+		// Act
+		pipeline := pipelines.DeletePipeline(ctx, client, engine.RunOptions{})(pipeline)
+
+		// Assert
+		testutils.False(t, pipeline.Cleaned)
+		testutils.Equal(t, 1, failures.Len())
+		logs := buf.String()
+		testutils.Contains(t, logs, "an error")
+		testutils.Contains(t, logs, "failed to delete pipeline")
+	})
+
+	t.Run("success_delete_pipeline", func(t *testing.T) {
+		// Arrange
+		t.Cleanup(httpmock.Reset)
+		httpmock.RegisterResponder(http.MethodDelete, fmt.Sprintf(pipelineURL, pipeline.ProjectID, pipeline.ID),
+			httpmock.NewStringResponder(http.StatusNoContent, ""))
+
+		var buf strings.Builder
+		ctx := context.WithValue(ctx, engine.LoggerKey, engine.NewTestLogger(&buf))
+
+		// Act
+		pipeline := pipelines.DeletePipeline(ctx, client, engine.RunOptions{})(pipeline)
+
+		// Assert
+		testutils.Equal(t, "", buf.String())
+		testutils.True(t, pipeline.Cleaned)
+	})
+}
@@ -0,0 +1,30 @@
+package pipelines
+
+import (
+	"context"
+
+	gitlab "gitlab.com/gitlab-org/api/client-go/v2"
+
+	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/artifacts/engine"
+	artifacts "github.com/kilianpaquier/gitlab-storage-cleaner/internal/artifacts/engine/v2"
+	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/artifacts/models"
+)
+
+// Run retrieves gitlab projects and filters the one not appropriate with options (paths regexps).
+//
+// For every appropriate project, it will retrieve pipelines and delete outdated ones (with their jobs, logs and artifacts)
+// according to input option threshold. The latest pipeline of each protected branch and tag is never deleted.
+// It returns the Summary of all processed projects (number of cleaned pipelines)
+// along with all errors which occurred during the run (e.g. failed pipelines deletions), joined together.
+//
+// When input context is canceled, no new project nor deletion is started, in-flight deletions
+// are given run options grace period to finish and an interruption error is returned.
+func Run(parent context.Context, client *gitlab.Client, opts ...engine.RunOption) (artifacts.Summary, error) {
+	return artifacts.RunCleaner(parent, client, artifacts.Cleaner[models.Pipeline]{
+		Name:       "pipelines",
+		CleanedKey: "pipelines_cleaned",
+		Read:       ReadPipelines,
+		Delete:     DeletePipeline,
+		Cleaned:    func(pipeline models.Pipeline) bool { return pipeline.Cleaned },
+	}, opts...)
+}
@@ -0,0 +1,138 @@
+package pipelines_test
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/samber/lo"
+	gitlab "gitlab.com/gitlab-org/api/client-go/v2"
+
+	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/artifacts/engine"
+	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/artifacts/engine/pipelines"
+	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/testutils"
+)
+
+const (
+	projectsURL          = "https://gitlab.com/api/v4/projects"
+	protectedBranchesURL = "https://gitlab.com/api/v4/projects/%d/protected_branches"
+	pipelinesURL         = "https://gitlab.com/api/v4/projects/%d/pipelines"
+	pipelineURL          = "https://gitlab.com/api/v4/projects/%d/pipelines/%d"
+)
+
+func TestRun(t *testing.T) {
+	now := time.Now()
+	ctx := t.Context()
+
+	httpmock.Activate()
+	t.Cleanup(httpmock.DeactivateAndReset)
+
+	// setup mock client
+	client, err := gitlab.NewClient("",
+		gitlab.WithHTTPClient(&http.Client{Transport: httpmock.DefaultTransport}),
+		gitlab.WithoutRetries(),
+	)
+	testutils.NoError(testutils.Require(t), err)
+
+	var buf strings.Builder
+	opts := []engine.RunOption{
+		engine.WithLogger(engine.NewTestLogger(&buf)),
+		engine.WithPaths("^project_path$"),
+		engine.WithThresholdDuration(time.Hour),
+	}
+
+	t.Run("success_e2e", func(t *testing.T) {
+		// Arrange
+		t.Cleanup(httpmock.Reset)
+
+		// projects endpoint mock
+		projectID := int64(7)
+		httpmock.RegisterResponder(http.MethodGet, projectsURL,
+			httpmock.NewJsonResponderOrPanic(http.StatusOK, []*gitlab.Project{
+				{ID: projectID, PathWithNamespace: "project_path"},
+				{ID: 8, PathWithNamespace: "not_matching"},
+			}).Then(httpmock.NewJsonResponderOrPanic(http.StatusOK, []*gitlab.Project{})))
+
+		// protected branches endpoint mock
+		httpmock.RegisterResponder(http.MethodGet, fmt.Sprintf(protectedBranchesURL, projectID),
+			httpmock.NewJsonResponderOrPanic(http.StatusOK, []*gitlab.ProtectedBranch{{Name: "main"}}).
+				Then(httpmock.NewJsonResponderOrPanic(http.StatusOK, []*gitlab.ProtectedBranch{})))
+
+		// pipelines endpoint mock (tags scope, then branches scope and finally finished scope)
+		old := lo.ToPtr(now.Add(-2 * time.Hour))
+		pipelineID := int64(10)
+		httpmock.RegisterResponder(http.MethodGet, fmt.Sprintf(pipelinesURL, projectID),
+			httpmock.NewJsonResponderOrPanic(http.StatusOK, []*gitlab.PipelineInfo{{ID: 12, Ref: "v1.0.0"}}).
+				Then(httpmock.NewJsonResponderOrPanic(http.StatusOK, []*gitlab.PipelineInfo{})).
+				Then(httpmock.NewJsonResponderOrPanic(http.StatusOK, []*gitlab.PipelineInfo{{ID: 11, Ref: "main"}, {ID: 13, Ref: "feat"}})).
+				Then(httpmock.NewJsonResponderOrPanic(http.StatusOK, []*gitlab.PipelineInfo{})).
+				Then(httpmock.NewJsonResponderOrPanic(http.StatusOK, []*gitlab.PipelineInfo{
+					{ID: 13, Ref: "feat", CreatedAt: old},   // latest of unprotected branch
+					{ID: 12, Ref: "v1.0.0", CreatedAt: old}, // latest of tag
+					{ID: 11, Ref: "main", CreatedAt: old},   // latest of protected branch
+					{ID: pipelineID, Ref: "main", CreatedAt: old},
+				})).
+				Then(httpmock.NewJsonResponderOrPanic(http.StatusOK, []*gitlab.PipelineInfo{})))
+
+		// pipeline deletion endpoint mock
+		httpmock.RegisterResponder(http.MethodDelete, fmt.Sprintf(pipelineURL, projectID, 13),
+			httpmock.NewStringResponder(http.StatusNoContent, ""))
+		httpmock.RegisterResponder(http.MethodDelete, fmt.Sprintf(pipelineURL, projectID, pipelineID),
+			httpmock.NewStringResponder(http.StatusNoContent, ""))
+
+		// expected calls to be made
+		expectedCalls := map[string]int{
+			"GET " + projectsURL: 2,
+			"GET " + fmt.Sprintf(protectedBranchesURL, projectID):       2,
+			"GET " + fmt.Sprintf(pipelinesURL, projectID):               6,
+			"DELETE " + fmt.Sprintf(pipelineURL, projectID, 13):         1,
+			"DELETE " + fmt.Sprintf(pipelineURL, projectID, pipelineID): 1,
+		}
+
+		// Act
+		summary, err := pipelines.Run(ctx, client, opts...)
+
+		// Assert
+		testutils.NoError(testutils.Require(t), err)
+		testutils.Equal(t, 2, summary.ItemsCleaned)
+		testutils.Equal(t, 1, summary.Projects)
+		for k, v := range expectedCalls {
+			actual, ok := httpmock.GetCallCountInfo()[k]
+			testutils.True(t, ok)
+			testutils.Equal(t, v, actual)
+		}
+		logs := buf.String()
+		testutils.Contains(t, logs, "starting project execution")
+		testutils.Contains(t, logs, "ending project execution")
+		testutils.Contains(t, logs, "pipelines_cleaned=2")
+		testutils.NotContains(t, logs, "failed to retrieve")
+		testutils.NotContains(t, logs, "failed to delete pipeline")
+	})
+
+	t.Run("error_failures", func(t *testing.T) {
+		// Arrange
+		t.Cleanup(httpmock.Reset)
+
+		// projects endpoint mock
+		projectID := int64(7)
+		httpmock.RegisterResponder(http.MethodGet, projectsURL,
+			httpmock.NewJsonResponderOrPanic(http.StatusOK, []*gitlab.Project{{ID: projectID, PathWithNamespace: "project_path"}}).
+				Then(httpmock.NewJsonResponderOrPanic(http.StatusOK, []*gitlab.Project{})))
+
+		// protected branches endpoint mock
+		httpmock.RegisterResponder(http.MethodGet, fmt.Sprintf(protectedBranchesURL, projectID),
+			httpmock.NewStringResponder(http.StatusForbidden, `{"message":"403 Forbidden"}`))
+
+		// Act
+		summary, err := pipelines.Run(ctx, client, opts...)
+
+		// Assert
+		testutils.Error(testutils.Require(t), err)
+		testutils.Equal(t, 1, summary.Failures)
+		testutils.Contains(t, err.Error(), "1 failure(s) during run")
+		testutils.Contains(t, err.Error(), "project 'project_path': read latest pipelines")
+	})
+}
@@ -0,0 +1,113 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// Policy represents a retention policy applied to jobs' artifacts of projects matching its paths.
+type Policy struct {
+	// JobNames is a list of regexps to filter jobs by name.
+	//
+	// Only jobs matching at least one of them are considered for cleanup, all jobs are when empty.
+	JobNames []string `yaml:"job-names"`
+
+	// KeepCount is the number of most recent jobs with artifacts to never clean, among the ones which would otherwise be cleaned.
+	KeepCount int `yaml:"keep-count"`
+
+	// Name is an optional name to identify the policy in logs.
+	Name string `yaml:"name"`
+
+	// Paths is a list of regexps to match project path (with namespace).
+	Paths []string `yaml:"paths"`
+
+	// ThresholdDuration is the duration threshold of the policy.
+	//
+	// RunOptions ThresholdDuration is used when it's not set.
+	ThresholdDuration time.Duration `yaml:"threshold-duration"`
+
+	jobNames []*regexp.Regexp
+	paths    []*regexp.Regexp
+}
+
+// WithPolicies sets the ordered list of retention policies in run options.
+//
+// The first policy whose paths match a project path is applied to this project.
+// When no policy matches, the project is cleaned with run options threshold duration.
+//
+// Examples:
+//
+//	Given a first policy with paths '^releases/' and a threshold duration of 90 days
+//	And a second policy with paths '^sandboxes/' and a threshold duration of 1 day
+//	Then artifacts of 'releases/app' jobs will be deleted after 90 days
+//	And artifacts of 'sandboxes/app' jobs will be deleted after 1 day
+func WithPolicies(policies ...Policy) RunOption {
+	return func(o RunOptions) RunOptions {
+		o.Policies = policies
+		return o
+	}
+}
+
+// Policy returns the first policy matching input project path.
+//
+// When no policy matches, a default one with run options threshold duration is returned.
+func (ro RunOptions) Policy(path string) Policy {
+	for _, policy := range ro.Policies {
+		for _, reg := range policy.paths {
+			if reg.MatchString(path) {
+				return policy
+			}
+		}
+	}
+	return Policy{ThresholdDuration: ro.ThresholdDuration}
+}
+
+// MatchesJob returns truthy if input job name matches any of the policy job names regexps,
+// or if the policy doesn't filter jobs by name.
+func (p Policy) MatchesJob(name string) bool {
+	if len(p.jobNames) == 0 {
+		return true
+	}
+	for _, reg := range p.jobNames {
+		if reg.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// compile compiles the policy regexps and validates its values,
+// threshold being the default threshold duration when the policy doesn't define one.
+func (p Policy) compile(threshold time.Duration) (Policy, error) {
+	var errs []error
+	if len(p.Paths) == 0 {
+		errs = append(errs, errors.New("at least one path is required"))
+	}
+	for _, path := range p.Paths {
+		reg, err := regexp.Compile(path)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid regexp '%s': %w", path, err))
+		}
+		p.paths = append(p.paths, reg)
+	}
+	for _, name := range p.JobNames {
+		reg, err := regexp.Compile(name)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid regexp '%s': %w", name, err))
+		}
+		p.jobNames = append(p.jobNames, reg)
+	}
+
+	if p.KeepCount < 0 {
+		errs = append(errs, fmt.Errorf("invalid keep count '%d'", p.KeepCount))
+	}
+	if p.ThresholdDuration == 0 {
+		p.ThresholdDuration = threshold
+	}
+	if p.ThresholdDuration < 0 {
+		errs = append(errs, fmt.Errorf("invalid threshold duration '%d'", p.ThresholdDuration))
+	}
+	return p, errors.Join(errs...)
+}
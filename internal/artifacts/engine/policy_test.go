@@ -0,0 +1,89 @@
+package engine_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/artifacts/engine"
+	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/testutils"
+)
+
+func TestPolicy(t *testing.T) {
+	t.Run("error_invalid_policy", func(t *testing.T) {
+		// Arrange
+		opts := []engine.RunOption{
+			engine.WithPolicies(
+				engine.Policy{Paths: []string{"^valid$"}},
+				engine.Policy{JobNames: []string{`/\/\`}, KeepCount: -1, ThresholdDuration: -time.Hour},
+			),
+			engine.WithThresholdDuration(time.Hour),
+		}
+
+		// Act
+		_, err := engine.NewRunOptions(opts...)
+
+		// Assert
+		testutils.Error(testutils.Require(t), err)
+		testutils.Contains(t, err.Error(), "invalid policy #2: at least one path is required")
+		testutils.Contains(t, err.Error(), `invalid regexp '/\/\'`)
+		testutils.Contains(t, err.Error(), "invalid keep count '-1'")
+		testutils.NotContains(t, err.Error(), "invalid policy #1")
+	})
+
+	t.Run("success_first_match", func(t *testing.T) {
+		// Arrange
+		ro, err := engine.NewRunOptions(
+			engine.WithPolicies(
+				engine.Policy{Name: "first", Paths: []string{"^releases/"}, ThresholdDuration: 90 * 24 * time.Hour},
+				engine.Policy{Name: "second", Paths: []string{"^releases/", "^sandboxes/"}},
+			),
+			engine.WithThresholdDuration(time.Hour))
+		testutils.NoError(testutils.Require(t), err)
+
+		// Act
+		first := ro.Policy("releases/app")
+		second := ro.Policy("sandboxes/app")
+
+		// Assert
+		testutils.Equal(t, "first", first.Name)
+		testutils.Equal(t, 90*24*time.Hour, first.ThresholdDuration)
+		testutils.Equal(t, "second", second.Name)
+		testutils.Equal(t, time.Hour, second.ThresholdDuration) // inherited
+	})
+
+	t.Run("success_no_match", func(t *testing.T) {
+		// Arrange
+		ro, err := engine.NewRunOptions(
+			engine.WithPolicies(engine.Policy{Name: "releases", Paths: []string{"^releases/"}, KeepCount: 3}),
+			engine.WithThresholdDuration(time.Hour))
+		testutils.NoError(testutils.Require(t), err)
+
+		// Act
+		policy := ro.Policy("other/app")
+
+		// Assert
+		testutils.Equal(t, "", policy.Name)
+		testutils.Equal(t, 0, policy.KeepCount)
+		testutils.Equal(t, time.Hour, policy.ThresholdDuration)
+		testutils.True(t, policy.MatchesJob("any"))
+	})
+
+	t.Run("success_matches_job", func(t *testing.T) {
+		// Arrange
+		ro, err := engine.NewRunOptions(
+			engine.WithPolicies(engine.Policy{Paths: []string{".*"}, JobNames: []string{"^build", "^test$"}}),
+			engine.WithThresholdDuration(time.Hour))
+		testutils.NoError(testutils.Require(t), err)
+		policy := ro.Policy("path")
+
+		// Act
+		build := policy.MatchesJob("build:linux")
+		test := policy.MatchesJob("test")
+		e2e := policy.MatchesJob("test:e2e")
+
+		// Assert
+		testutils.True(t, build)
+		testutils.True(t, test)
+		testutils.False(t, e2e)
+	})
+}
@@ -0,0 +1,203 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/fogfactory/pipe"
+	gitlab "gitlab.com/gitlab-org/api/client-go/v2"
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/artifacts/engine"
+	artifacts "github.com/kilianpaquier/gitlab-storage-cleaner/internal/artifacts/engine/v2"
+	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/artifacts/models"
+)
+
+// ReadTags returns the function to send all container registry Tags of a given Project into pipe processing.
+func ReadTags(ctx context.Context, client *gitlab.Client, runOptions engine.RunOptions) pipe.Split[artifacts.Project, models.Tag] {
+	logger := engine.GetLogger(ctx)
+	return func(project artifacts.Project, in chan<- models.Tag) {
+		opts := &gitlab.ListProjectRegistryRepositoriesOptions{
+			ListOptions: gitlab.ListOptions{
+				Page:    1,
+				PerPage: 100,
+			},
+		}
+
+		for {
+			repositories, err := artifacts.Fetch(ctx, "list_project_registry_repositories", func(ctx context.Context) ([]*gitlab.RegistryRepository, *gitlab.Response, error) {
+				return client.ContainerRegistry.ListProjectRegistryRepositories(project.ID, opts, gitlab.WithContext(ctx))
+			}, attribute.Int64("project.id", project.ID), attribute.Int64("page", opts.Page))
+			if ctx.Err() != nil {
+				return // run canceled
+			}
+			if err != nil {
+				logger.Warn("failed to retrieve project registry repositories",
+					"error", err,
+					"project_id", project.ID,
+					"project_path", project.PathWithNamespace)
+				engine.GetFailures(ctx).Add(fmt.Errorf("project '%s': list registry repositories: %w", project.PathWithNamespace, err))
+				break
+			}
+
+			// stop infinite loop
+			if len(repositories) == 0 {
+				break
+			}
+			opts.Page++
+
+			for _, repository := range repositories {
+				readRepositoryTags(ctx, client, runOptions, project, repository.ID, in)
+			}
+		}
+	}
+}
+
+// readRepositoryTags sends all tags of a given registry repository needing cleanup into the input channel.
+func readRepositoryTags(ctx context.Context, client *gitlab.Client, runOptions engine.RunOptions, project artifacts.Project, repositoryID int64, in chan<- models.Tag) {
+	logger := engine.GetLogger(ctx)
+
+	opts := &gitlab.ListRegistryRepositoryTagsOptions{
+		ListOptions: gitlab.ListOptions{
+			Page:    1,
+			PerPage: 100,
+		},
+	}
+
+	// tags are sent once all pages are read since their deletion would shift next pages
+	var outdated []models.Tag
+
+	for {
+		tags, err := artifacts.Fetch(ctx, "list_registry_repository_tags", func(ctx context.Context) ([]*gitlab.RegistryRepositoryTag, *gitlab.Response, error) {
+			return client.ContainerRegistry.ListRegistryRepositoryTags(project.ID, repositoryID, opts, gitlab.WithContext(ctx))
+		}, attribute.Int64("project.id", project.ID), attribute.Int64("repository.id", repositoryID), attribute.Int64("page", opts.Page))
+		if ctx.Err() != nil {
+			return // run canceled
+		}
+		if err != nil {
+			logger.Warn("failed to retrieve registry repository tags",
+				"error", err,
+				"project_id", project.ID,
+				"repository_id", repositoryID)
+			engine.GetFailures(ctx).Add(fmt.Errorf("project '%s': list registry repository %d tags: %w", project.PathWithNamespace, repositoryID, err))
+			break
+		}
+
+		// stop infinite loop
+		if len(tags) == 0 {
+			break
+		}
+		opts.Page++
+
+		for _, gitlab := range tags {
+			tag := models.TagFromGitLab(project.ID, repositoryID, gitlab)
+			if keep(runOptions, tag) {
+				logger.Debug("keeping registry tag",
+					"project_id", project.ID,
+					"repository_id", repositoryID,
+					"tag", tag.Name)
+				continue
+			}
+
+			// tags listing doesn't provide the creation date, it must be retrieved tag by tag
+			detail, err := readTagDetail(ctx, client, tag)
+			if ctx.Err() != nil {
+				return // run canceled
+			}
+			if err != nil {
+				logger.Warn("failed to retrieve registry tag details",
+					"error", err,
+					"project_id", project.ID,
+					"repository_id", repositoryID,
+					"tag", tag.Name)
+				engine.GetFailures(ctx).Add(fmt.Errorf("project '%s': read registry repository %d tag '%s': %w", project.PathWithNamespace, repositoryID, tag.Name, err))
+				continue
+			}
+
+			// check that the tag needs cleanup before sending it
+			if detail.NeedCleanup(runOptions.ProjectThreshold(project.Project)) {
+				outdated = append(outdated, detail)
+			}
+		}
+	}
+
+	for _, tag := range outdated {
+		select {
+		case in <- tag:
+		case <-ctx.Done():
+			return // run canceled
+		}
+	}
+}
+
+// readTagDetail retrieves the full view of the input tag (with its creation date).
+func readTagDetail(ctx context.Context, client *gitlab.Client, tag models.Tag) (models.Tag, error) {
+	var detail *gitlab.RegistryRepositoryTag
+	err := artifacts.Call(ctx, "get_registry_repository_tag_detail", func(ctx context.Context) (response *gitlab.Response, err error) {
+		detail, response, err = client.ContainerRegistry.GetRegistryRepositoryTagDetail(tag.ProjectID, tag.RepositoryID, tag.Name, gitlab.WithContext(ctx))
+		return response, err
+	}, tagAttributes(tag)...)
+	if err != nil {
+		return models.Tag{}, err
+	}
+	return models.TagFromGitLab(tag.ProjectID, tag.RepositoryID, detail), nil
+}
+
+// keep returns truthy if the input tag matches one of keep rules defined in run options.
+func keep(runOptions engine.RunOptions, tag models.Tag) bool {
+	return (runOptions.KeepLatest && tag.IsLatest()) || (runOptions.KeepSemver && tag.IsSemver())
+}
+
+// DeleteTag returns the function to delete a specific registry tag.
+//
+// Once input context is canceled, deletions not yet started are skipped
+// while in-flight ones are given run options grace period to finish.
+func DeleteTag(ctx context.Context, client *gitlab.Client, opts engine.RunOptions) pipe.Process[models.Tag] {
+	return func(tag models.Tag) models.Tag {
+		logger := engine.GetLogger(ctx)
+
+		if ctx.Err() != nil {
+			logger.Debug("run interrupted, skipping registry tag deletion",
+				"project_id", tag.ProjectID,
+				"repository_id", tag.RepositoryID,
+				"tag", tag.Name)
+			return tag
+		}
+
+		if opts.DryRun {
+			logger.Info("running in dry run mode, skipping registry tag deletion",
+				"project_id", tag.ProjectID,
+				"repository_id", tag.RepositoryID,
+				"tag", tag.Name)
+			return tag
+		}
+
+		graceCtx, cancel := engine.GraceContext(ctx, opts.GracePeriod)
+		defer cancel()
+
+		err := artifacts.Call(graceCtx, "delete_registry_repository_tag", func(ctx context.Context) (*gitlab.Response, error) {
+			return tag.Delete(ctx, client)
+		}, tagAttributes(tag)...)
+		if err != nil {
+			logger.Warn("failed to delete registry tag",
+				"error", err,
+				"project_id", tag.ProjectID,
+				"repository_id", tag.RepositoryID,
+				"tag", tag.Name)
+			engine.GetFailures(ctx).Add(fmt.Errorf("project %d: delete registry repository %d tag '%s': %w", tag.ProjectID, tag.RepositoryID, tag.Name, err))
+			return tag
+		}
+
+		tag.Cleaned = true
+		return tag
+	}
+}
+
+// tagAttributes returns the tracing attributes of input tag.
+func tagAttributes(tag models.Tag) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.Int64("project.id", tag.ProjectID),
+		attribute.Int64("repository.id", tag.RepositoryID),
+		attribute.String("tag", tag.Name),
+	}
+}
@@ -0,0 +1,183 @@
+package registry_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jarcoal/httpmock"
+	gitlab "gitlab.com/gitlab-org/api/client-go/v2"
+
+	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/artifacts/engine"
+	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/artifacts/engine/registry"
+	artifacts "github.com/kilianpaquier/gitlab-storage-cleaner/internal/artifacts/engine/v2"
+	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/artifacts/models"
+	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/testutils"
+)
+
+func TestReadTags(t *testing.T) {
+	ctx := t.Context()
+
+	httpmock.Activate()
+	t.Cleanup(httpmock.DeactivateAndReset)
+
+	// setup mock client
+	client, err := gitlab.NewClient("",
+		gitlab.WithHTTPClient(&http.Client{Transport: httpmock.DefaultTransport}),
+		gitlab.WithoutRetries())
+	testutils.NoError(testutils.Require(t), err)
+
+	project := artifacts.Project{Project: models.Project{ID: 5}}
+	repositoryID := int64(3)
+
+	t.Run("error_list_repositories", func(t *testing.T) {
+		// Arrange
+		t.Cleanup(httpmock.Reset)
+		httpmock.RegisterResponder(http.MethodGet, fmt.Sprintf(repositoriesURL, project.ID),
+			httpmock.NewStringResponder(http.StatusInternalServerError, "an error"))
+
+		var buf strings.Builder
+		ctx := context.WithValue(ctx, engine.LoggerKey, engine.NewTestLogger(&buf))
+
+		// Act
+		registry.ReadTags(ctx, client, engine.RunOptions{})(project, nil)
+
+		// Assert
+		logs := buf.String()
+		testutils.Contains(t, logs, "an error")
+		testutils.Contains(t, logs, "failed to retrieve project registry repositories")
+	})
+
+	t.Run("error_list_tags", func(t *testing.T) {
+		// Arrange
+		t.Cleanup(httpmock.Reset)
+		httpmock.RegisterResponder(http.MethodGet, fmt.Sprintf(repositoriesURL, project.ID),
+			httpmock.NewJsonResponderOrPanic(http.StatusOK, []*gitlab.RegistryRepository{{ID: repositoryID}}).
+				Then(httpmock.NewJsonResponderOrPanic(http.StatusOK, []*gitlab.RegistryRepository{})))
+		httpmock.RegisterResponder(http.MethodGet, fmt.Sprintf(tagsURL, project.ID, repositoryID),
+			httpmock.NewStringResponder(http.StatusInternalServerError, "an error"))
+
+		var buf strings.Builder
+		ctx := context.WithValue(ctx, engine.LoggerKey, engine.NewTestLogger(&buf))
+
+		// Act
+		registry.ReadTags(ctx, client, engine.RunOptions{})(project, nil)
+
+		// Assert
+		logs := buf.String()
+		testutils.Contains(t, logs, "an error")
+		testutils.Contains(t, logs, "failed to retrieve registry repository tags")
+	})
+
+	t.Run("success_populate_channel", func(t *testing.T) {
+		// Arrange
+		start := time.Now().Add(-2 * time.Hour) // tags are old
+
+		t.Cleanup(httpmock.Reset)
+		httpmock.RegisterResponder(http.MethodGet, fmt.Sprintf(repositoriesURL, project.ID),
+			httpmock.NewJsonResponderOrPanic(http.StatusOK, []*gitlab.RegistryRepository{{ID: repositoryID}}).
+				Then(httpmock.NewJsonResponderOrPanic(http.StatusOK, []*gitlab.RegistryRepository{})))
+		httpmock.RegisterResponder(http.MethodGet, fmt.Sprintf(tagsURL, project.ID, repositoryID),
+			httpmock.NewJsonResponderOrPanic(http.StatusOK, []*gitlab.RegistryRepositoryTag{
+				{Name: "latest"},
+				{Name: "v1.2.3"},
+				{Name: "feat-branch"},
+				{Name: "unknown"},
+			}).Then(httpmock.NewJsonResponderOrPanic(http.StatusOK, []*gitlab.RegistryRepositoryTag{})))
+		for _, name := range []string{"latest", "v1.2.3", "feat-branch"} {
+			httpmock.RegisterResponder(http.MethodGet, fmt.Sprintf(tagURL, project.ID, repositoryID, name),
+				httpmock.NewJsonResponderOrPanic(http.StatusOK, gitlab.RegistryRepositoryTag{Name: name, CreatedAt: &start}))
+		}
+		httpmock.RegisterResponder(http.MethodGet, fmt.Sprintf(tagURL, project.ID, repositoryID, "unknown"),
+			httpmock.NewStringResponder(http.StatusInternalServerError, "an error"))
+
+		ro, _ := engine.NewRunOptions(engine.WithKeepLatest(true), engine.WithKeepSemver(true), engine.WithThresholdDuration(time.Hour))
+
+		var buf strings.Builder
+		ctx := context.WithValue(ctx, engine.LoggerKey, engine.NewTestLogger(&buf))
+
+		tags := make(chan models.Tag, 10)
+		t.Cleanup(func() { close(tags) })
+
+		// Act
+		registry.ReadTags(ctx, client, ro)(project, tags)
+
+		// Assert
+		testutils.Equal(testutils.Require(t), 1, len(tags)) // only feat-branch since latest and v1.2.3 are kept and unknown details failed
+		testutils.Equal(t, "feat-branch", (<-tags).Name)
+		logs := buf.String()
+		testutils.Contains(t, logs, "keeping registry tag project_id=5 repository_id=3 tag=latest")
+		testutils.Contains(t, logs, "keeping registry tag project_id=5 repository_id=3 tag=v1.2.3")
+		testutils.Contains(t, logs, "failed to retrieve registry tag details")
+	})
+}
+
+func TestDeleteTag(t *testing.T) {
+	ctx := t.Context()
+
+	httpmock.Activate()
+	t.Cleanup(httpmock.DeactivateAndReset)
+
+	// setup mock client
+	client, err := gitlab.NewClient("",
+		gitlab.WithHTTPClient(&http.Client{Transport: httpmock.DefaultTransport}),
+		gitlab.WithoutRetries())
+	testutils.NoError(testutils.Require(t), err)
+
+	tag := models.Tag{Name: "old", ProjectID: 5, RepositoryID: 3}
+
+	t.Run("success_dry_run", func(t *testing.T) {
+		// Arrange
+		var buf strings.Builder
+		ctx := context.WithValue(ctx, engine.LoggerKey, engine.NewTestLogger(&buf))
+
+		// Act
+		tag := registry.DeleteTag(ctx, client, engine.RunOptions{DryRun: true})(tag)
+
+		// Assert
+		testutils.False(t, tag.Cleaned)
+		testutils.Contains(t, buf.String(), "running in dry run mode, skipping registry tag deletion")
+	})
+
+	t.Run("error_delete_tag", func(t *testing.T) {
+		// Arrange
+		t.Cleanup(httpmock.Reset)
+		httpmock.RegisterResponder(http.MethodDelete, fmt.Sprintf(tagURL, tag.ProjectID, tag.RepositoryID, tag.Name),
+			httpmock.NewStringResponder(http.StatusInternalServerError, "an error"))
+
+		var buf strings.Builder
+		failures := &engine.Failures{}
+		ctx := context.WithValue(ctx, engine.LoggerKey, engine.NewTestLogger(&buf))
+		ctx = context.WithValue(ctx, engine.FailuresKey, failures)
+
+		// Act
+		tag := registry.DeleteTag(ctx, client, engine.RunOptions{})(tag)
+
+		// Assert
+		testutils.False(t, tag.Cleaned)
+		testutils.Equal(t, 1, failures.Len())
+		logs := buf.String()
+		testutils.Contains(t, logs, "an error")
+		testutils.Contains(t, logs, "failed to delete registry tag")
+	})
+
+	t.Run("success_delete_tag", func(t *testing.T) {
+		// Arrange
+		t.Cleanup(httpmock.Reset)
+		httpmock.RegisterResponder(http.MethodDelete, fmt.Sprintf(tagURL, tag.ProjectID, tag.RepositoryID, tag.Name),
+			httpmock.NewStringResponder(http.StatusOK, ""))
+
+		var buf strings.Builder
+		ctx := context.WithValue(ctx, engine.LoggerKey, engine.NewTestLogger(&buf))
+
+		// Act
+		tag := registry.DeleteTag(ctx, client, engine.RunOptions{})(tag)
+
+		// Assert
+		testutils.Equal(t, "", buf.String())
+		testutils.True(t, tag.Cleaned)
+	})
+}
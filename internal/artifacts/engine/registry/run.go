@@ -0,0 +1,30 @@
+package registry
+
+import (
+	"context"
+
+	gitlab "gitlab.com/gitlab-org/api/client-go/v2"
+
+	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/artifacts/engine"
+	artifacts "github.com/kilianpaquier/gitlab-storage-cleaner/internal/artifacts/engine/v2"
+	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/artifacts/models"
+)
+
+// Run retrieves gitlab projects and filters the one not appropriate with options (paths regexps).
+//
+// For every appropriate project, it will retrieve container registry repositories' tags
+// and delete outdated ones according to input option threshold and keep rules.
+// It returns the Summary of all processed projects (number of cleaned tags)
+// along with all errors which occurred during the run (e.g. failed tags deletions), joined together.
+//
+// When input context is canceled, no new project nor deletion is started, in-flight deletions
+// are given run options grace period to finish and an interruption error is returned.
+func Run(parent context.Context, client *gitlab.Client, opts ...engine.RunOption) (artifacts.Summary, error) {
+	return artifacts.RunCleaner(parent, client, artifacts.Cleaner[models.Tag]{
+		Name:       "registry",
+		CleanedKey: "tags_cleaned",
+		Read:       ReadTags,
+		Delete:     DeleteTag,
+		Cleaned:    func(tag models.Tag) bool { return tag.Cleaned },
+	}, opts...)
+}
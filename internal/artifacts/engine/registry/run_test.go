@@ -0,0 +1,139 @@
+package registry_test
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/samber/lo"
+	gitlab "gitlab.com/gitlab-org/api/client-go/v2"
+
+	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/artifacts/engine"
+	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/artifacts/engine/registry"
+	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/testutils"
+)
+
+const (
+	projectsURL     = "https://gitlab.com/api/v4/projects"
+	repositoriesURL = "https://gitlab.com/api/v4/projects/%d/registry/repositories"
+	tagsURL         = "https://gitlab.com/api/v4/projects/%d/registry/repositories/%d/tags"
+	tagURL          = "https://gitlab.com/api/v4/projects/%d/registry/repositories/%d/tags/%s"
+)
+
+func TestRun(t *testing.T) {
+	now := time.Now()
+	ctx := t.Context()
+
+	httpmock.Activate()
+	t.Cleanup(httpmock.DeactivateAndReset)
+
+	// setup mock client
+	client, err := gitlab.NewClient("",
+		gitlab.WithHTTPClient(&http.Client{Transport: httpmock.DefaultTransport}),
+		gitlab.WithoutRetries(),
+	)
+	testutils.NoError(testutils.Require(t), err)
+
+	var buf strings.Builder
+	opts := []engine.RunOption{
+		engine.WithKeepLatest(true),
+		engine.WithKeepSemver(true),
+		engine.WithLogger(engine.NewTestLogger(&buf)),
+		engine.WithPaths("^project_path$"),
+		engine.WithThresholdDuration(time.Hour),
+	}
+
+	t.Run("success_e2e", func(t *testing.T) {
+		// Arrange
+		t.Cleanup(httpmock.Reset)
+
+		// projects endpoint mock
+		projectID := int64(7)
+		httpmock.RegisterResponder(http.MethodGet, projectsURL,
+			httpmock.NewJsonResponderOrPanic(http.StatusOK, []*gitlab.Project{
+				{ID: projectID, PathWithNamespace: "project_path"},
+				{ID: 8, PathWithNamespace: "not_matching"},
+			}).Then(httpmock.NewJsonResponderOrPanic(http.StatusOK, []*gitlab.Project{})))
+
+		// registry repositories endpoint mock
+		repositoryID := int64(3)
+		httpmock.RegisterResponder(http.MethodGet, fmt.Sprintf(repositoriesURL, projectID),
+			httpmock.NewJsonResponderOrPanic(http.StatusOK, []*gitlab.RegistryRepository{{ID: repositoryID}}).
+				Then(httpmock.NewJsonResponderOrPanic(http.StatusOK, []*gitlab.RegistryRepository{})))
+
+		// registry tags endpoint mock
+		httpmock.RegisterResponder(http.MethodGet, fmt.Sprintf(tagsURL, projectID, repositoryID),
+			httpmock.NewJsonResponderOrPanic(http.StatusOK, []*gitlab.RegistryRepositoryTag{
+				{Name: "latest"}, // kept
+				{Name: "v1.0.0"}, // kept
+				{Name: "old"},
+				{Name: "recent"},
+			}).Then(httpmock.NewJsonResponderOrPanic(http.StatusOK, []*gitlab.RegistryRepositoryTag{})))
+
+		// registry tags details endpoint mock
+		httpmock.RegisterResponder(http.MethodGet, fmt.Sprintf(tagURL, projectID, repositoryID, "old"),
+			httpmock.NewJsonResponderOrPanic(http.StatusOK, gitlab.RegistryRepositoryTag{Name: "old", CreatedAt: lo.ToPtr(now.Add(-2 * time.Hour))}))
+		httpmock.RegisterResponder(http.MethodGet, fmt.Sprintf(tagURL, projectID, repositoryID, "recent"),
+			httpmock.NewJsonResponderOrPanic(http.StatusOK, gitlab.RegistryRepositoryTag{Name: "recent", CreatedAt: lo.ToPtr(now)}))
+
+		// registry tag deletion endpoint mock
+		httpmock.RegisterResponder(http.MethodDelete, fmt.Sprintf(tagURL, projectID, repositoryID, "old"),
+			httpmock.NewStringResponder(http.StatusOK, ""))
+
+		// expected calls to be made
+		expectedCalls := map[string]int{
+			"GET " + projectsURL:                                            2,
+			"GET " + fmt.Sprintf(repositoriesURL, projectID):                2,
+			"GET " + fmt.Sprintf(tagsURL, projectID, repositoryID):          2,
+			"GET " + fmt.Sprintf(tagURL, projectID, repositoryID, "old"):    1,
+			"GET " + fmt.Sprintf(tagURL, projectID, repositoryID, "recent"): 1,
+			"DELETE " + fmt.Sprintf(tagURL, projectID, repositoryID, "old"): 1,
+		}
+
+		// Act
+		summary, err := registry.Run(ctx, client, opts...)
+
+		// Assert
+		testutils.NoError(testutils.Require(t), err)
+		testutils.Equal(t, 1, summary.ItemsCleaned)
+		testutils.Equal(t, 1, summary.Projects)
+		for k, v := range expectedCalls {
+			actual, ok := httpmock.GetCallCountInfo()[k]
+			testutils.True(t, ok)
+			testutils.Equal(t, v, actual)
+		}
+		logs := buf.String()
+		testutils.Contains(t, logs, "starting project execution")
+		testutils.Contains(t, logs, "ending project execution")
+		testutils.Contains(t, logs, "tags_cleaned=1")
+		testutils.NotContains(t, logs, "failed to retrieve")
+		testutils.NotContains(t, logs, "failed to delete registry tag")
+	})
+
+	t.Run("error_failures", func(t *testing.T) {
+		// Arrange
+		t.Cleanup(httpmock.Reset)
+
+		// projects endpoint mock
+		projectID := int64(7)
+		httpmock.RegisterResponder(http.MethodGet, projectsURL,
+			httpmock.NewJsonResponderOrPanic(http.StatusOK, []*gitlab.Project{{ID: projectID, PathWithNamespace: "project_path"}}).
+				Then(httpmock.NewJsonResponderOrPanic(http.StatusOK, []*gitlab.Project{})))
+
+		// registry repositories endpoint mock
+		httpmock.RegisterResponder(http.MethodGet, fmt.Sprintf(repositoriesURL, projectID),
+			httpmock.NewStringResponder(http.StatusForbidden, `{"message":"403 Forbidden"}`))
+
+		// Act
+		summary, err := registry.Run(ctx, client, opts...)
+
+		// Assert
+		testutils.Error(testutils.Require(t), err)
+		testutils.Equal(t, 1, summary.Failures)
+		testutils.Contains(t, err.Error(), "1 failure(s) during run")
+		testutils.Contains(t, err.Error(), "project 'project_path': list registry repositories")
+	})
+}
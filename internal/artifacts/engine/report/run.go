@@ -0,0 +1,92 @@
+package report
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/samber/lo"
+	gitlab "gitlab.com/gitlab-org/api/client-go/v2"
+
+	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/artifacts/engine"
+	artifacts "github.com/kilianpaquier/gitlab-storage-cleaner/internal/artifacts/engine/v2"
+	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/artifacts/models"
+)
+
+// Run retrieves gitlab projects and filters the one not appropriate with options (paths regexps),
+// the same way cleaning commands do.
+//
+// For every appropriate project, it retrieves its storage statistics and returns them.
+// Nothing is ever deleted, as such a token with reporter access level is enough.
+//
+// All errors which occurred during the run (e.g. failed projects listing or statistics retrieval) are returned joined together.
+// Those errors don't stop the run, as such the returned usages are still relevant (though incomplete) when an error is returned.
+//
+// When input context is canceled, no new project is read and an interruption error is returned.
+func Run(parent context.Context, client *gitlab.Client, opts ...engine.RunOption) ([]Usage, error) {
+	// threshold duration isn't used by reports but is required by run options
+	defaults := []engine.RunOption{
+		engine.WithMinAccessLevel(gitlab.ReporterPermissions),
+		engine.WithStatistics(true),
+		engine.WithThresholdDuration(time.Nanosecond),
+	}
+	ro, err := engine.NewRunOptions(append(defaults, opts...)...)
+	if err != nil {
+		return nil, fmt.Errorf("new run options: %w", err)
+	}
+	failures := &engine.Failures{}
+	ctx := context.WithValue(ro.Context(parent), engine.FailuresKey, failures)
+
+	projects := artifacts.ReadProjects(ctx, client, ro)
+
+	var (
+		mutex  sync.Mutex
+		usages []Usage
+		wg     sync.WaitGroup
+	)
+	// projects statistics are retrieved concurrently
+	for range ro.ProjectConcurrency {
+		wg.Go(func() {
+			for project := range projects {
+				usage, ok := ReadUsage(ctx, client, project.Project)
+				if !ok {
+					continue
+				}
+				mutex.Lock()
+				usages = append(usages, usage)
+				mutex.Unlock()
+			}
+		})
+	}
+	wg.Wait()
+	failures.Add(engine.Interrupted(ctx))
+	return usages, failures.Err()
+}
+
+// ReadUsage returns the storage usage of input project,
+// its storage statistics being retrieved when they weren't read along with the project (e.g. with groups projects listing).
+//
+// It returns false if statistics couldn't be retrieved, the error being added to context failures.
+func ReadUsage(ctx context.Context, client *gitlab.Client, project models.Project) (Usage, bool) {
+	logger := engine.GetLogger(ctx)
+
+	if project.Statistics != nil {
+		return UsageFromProject(project), true
+	}
+
+	var detailed *gitlab.Project
+	err := engine.Retry(ctx, "get_project", func() (err error) {
+		detailed, _, err = client.Projects.GetProject(project.ID, &gitlab.GetProjectOptions{Statistics: lo.ToPtr(true)}, gitlab.WithContext(ctx))
+		return err
+	})
+	if err != nil {
+		logger.Warn("failed to retrieve project statistics",
+			"error", err,
+			"project_id", project.ID,
+			"project_path", project.PathWithNamespace)
+		engine.GetFailures(ctx).Add(fmt.Errorf("project '%s': get statistics: %w", project.PathWithNamespace, err))
+		return Usage{}, false
+	}
+	return UsageFromProject(models.ProjectFromGitLab(detailed)), true
+}
@@ -0,0 +1,204 @@
+package report_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/jarcoal/httpmock"
+	gitlab "gitlab.com/gitlab-org/api/client-go/v2"
+
+	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/artifacts/engine"
+	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/artifacts/engine/report"
+	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/artifacts/models"
+	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/testutils"
+)
+
+const (
+	projectsURL = "https://gitlab.com/api/v4/projects"
+	projectURL  = "https://gitlab.com/api/v4/projects/%d"
+)
+
+func TestRun(t *testing.T) {
+	ctx := t.Context()
+
+	httpmock.Activate()
+	t.Cleanup(httpmock.DeactivateAndReset)
+
+	// setup mock client
+	client, err := gitlab.NewClient("",
+		gitlab.WithHTTPClient(&http.Client{Transport: httpmock.DefaultTransport}),
+		gitlab.WithoutRetries(),
+	)
+	testutils.NoError(testutils.Require(t), err)
+
+	var buf strings.Builder
+	opts := []engine.RunOption{
+		engine.WithLogger(engine.NewTestLogger(&buf)),
+		engine.WithPaths("^project_path$"),
+		engine.WithRetryPolicy(engine.RetryPolicy{MaxAttempts: 1}),
+	}
+
+	t.Run("error_failures", func(t *testing.T) {
+		// Arrange
+		t.Cleanup(httpmock.Reset)
+
+		// projects listed without statistics (e.g. with groups), the first one failing to retrieve them
+		httpmock.RegisterResponder(http.MethodGet, projectsURL, func(req *http.Request) (*http.Response, error) {
+			if req.URL.Query().Get("page") != "1" {
+				return httpmock.NewJsonResponse(http.StatusOK, []*gitlab.Project{})
+			}
+			return httpmock.NewJsonResponse(http.StatusOK, []*gitlab.Project{
+				{ID: 7, PathWithNamespace: "project_path"},
+				{ID: 8, PathWithNamespace: "project_path"},
+			})
+		})
+		httpmock.RegisterResponder(http.MethodGet, fmt.Sprintf(projectURL, 7),
+			httpmock.NewStringResponder(http.StatusInternalServerError, "error"))
+		httpmock.RegisterResponder(http.MethodGet, fmt.Sprintf(projectURL, 8),
+			httpmock.NewJsonResponderOrPanic(http.StatusOK, gitlab.Project{ID: 8, PathWithNamespace: "project_path"}))
+
+		// Act
+		usages, err := report.Run(ctx, client, opts...)
+
+		// Assert
+		testutils.Error(testutils.Require(t), err)
+		testutils.Contains(t, err.Error(), "1 failure(s) during run")
+		testutils.Contains(t, err.Error(), "project 'project_path': get statistics")
+		testutils.Equal(t, 1, len(usages)) // partial usages are still returned
+		testutils.Equal(t, 8, usages[0].ProjectID)
+	})
+
+	t.Run("error_list_projects", func(t *testing.T) {
+		// Arrange
+		t.Cleanup(httpmock.Reset)
+
+		httpmock.RegisterResponder(http.MethodGet, projectsURL,
+			httpmock.NewStringResponder(http.StatusInternalServerError, "error"))
+
+		// Act
+		usages, err := report.Run(ctx, client, opts...)
+
+		// Assert
+		testutils.Error(testutils.Require(t), err)
+		testutils.Contains(t, err.Error(), "list projects")
+		testutils.Equal(t, 0, len(usages))
+	})
+
+	t.Run("success_e2e", func(t *testing.T) {
+		// Arrange
+		t.Cleanup(httpmock.Reset)
+
+		// projects endpoint mock (with statistics)
+		projectID := int64(7)
+		var query url.Values
+		projects := httpmock.NewJsonResponderOrPanic(http.StatusOK, []*gitlab.Project{
+			{
+				ID:                projectID,
+				PathWithNamespace: "project_path",
+				Statistics: &gitlab.Statistics{
+					StorageSize:      300,
+					RepositorySize:   100,
+					JobArtifactsSize: 200,
+				},
+			},
+			{ID: 8, PathWithNamespace: "not_matching"},
+		})
+		httpmock.RegisterResponder(http.MethodGet, projectsURL, func(req *http.Request) (*http.Response, error) {
+			if req.URL.Query().Get("page") != "1" {
+				return httpmock.NewJsonResponse(http.StatusOK, []*gitlab.Project{})
+			}
+			query = req.URL.Query()
+			return projects(req)
+		})
+
+		// Act
+		usages, err := report.Run(ctx, client, opts...)
+
+		// Assert
+		testutils.NoError(testutils.Require(t), err)
+		testutils.Equal(t, "20", query.Get("min_access_level")) // reporter
+		testutils.Equal(t, "true", query.Get("statistics"))
+		testutils.Equal(t, "false", query.Get("simple"))
+		testutils.Equal(t, 1, len(usages))
+		testutils.Equal(t, report.Usage{
+			ProjectID:        projectID,
+			ProjectPath:      "project_path",
+			StorageSize:      300,
+			RepositorySize:   100,
+			JobArtifactsSize: 200,
+		}, usages[0])
+		testutils.Equal(t, 0, httpmock.GetCallCountInfo()["GET "+fmt.Sprintf(projectURL, projectID)])
+	})
+}
+
+func TestReadUsage(t *testing.T) {
+	httpmock.Activate()
+	t.Cleanup(httpmock.DeactivateAndReset)
+
+	// setup mock client
+	client, err := gitlab.NewClient("",
+		gitlab.WithHTTPClient(&http.Client{Transport: httpmock.DefaultTransport}),
+		gitlab.WithoutRetries(),
+	)
+	testutils.NoError(testutils.Require(t), err)
+
+	project := models.Project{ID: 7, PathWithNamespace: "project_path"}
+
+	t.Run("error_get_project", func(t *testing.T) {
+		// Arrange
+		t.Cleanup(httpmock.Reset)
+
+		var buf strings.Builder
+		ctx := context.WithValue(t.Context(), engine.LoggerKey, engine.NewTestLogger(&buf))
+
+		httpmock.RegisterResponder(http.MethodGet, fmt.Sprintf(projectURL, project.ID),
+			httpmock.NewStringResponder(http.StatusInternalServerError, "error"))
+
+		failures := &engine.Failures{}
+		ctx = context.WithValue(ctx, engine.FailuresKey, failures)
+
+		// Act
+		_, ok := report.ReadUsage(ctx, client, project)
+
+		// Assert
+		testutils.False(t, ok)
+		testutils.Equal(t, 1, failures.Len())
+		testutils.Contains(t, buf.String(), "failed to retrieve project statistics")
+		testutils.Contains(t, buf.String(), "project_id=7")
+	})
+
+	t.Run("success_listed_statistics", func(t *testing.T) {
+		// Arrange
+		t.Cleanup(httpmock.Reset)
+
+		project := project
+		project.Statistics = &models.Statistics{StorageSize: 300}
+
+		// Act
+		usage, ok := report.ReadUsage(t.Context(), client, project)
+
+		// Assert
+		testutils.True(t, ok)
+		testutils.Equal(t, report.Usage{ProjectID: project.ID, ProjectPath: project.PathWithNamespace, StorageSize: 300}, usage)
+		testutils.Equal(t, 0, httpmock.GetTotalCallCount())
+	})
+
+	t.Run("success_no_statistics", func(t *testing.T) {
+		// Arrange
+		t.Cleanup(httpmock.Reset)
+
+		httpmock.RegisterResponder(http.MethodGet, fmt.Sprintf(projectURL, project.ID),
+			httpmock.NewJsonResponderOrPanic(http.StatusOK, gitlab.Project{ID: project.ID, PathWithNamespace: project.PathWithNamespace}))
+
+		// Act
+		usage, ok := report.ReadUsage(t.Context(), client, project)
+
+		// Assert
+		testutils.True(t, ok)
+		testutils.Equal(t, report.Usage{ProjectID: project.ID, ProjectPath: project.PathWithNamespace}, usage)
+	})
+}
@@ -0,0 +1,204 @@
+package report
+
+import (
+	"cmp"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/samber/lo"
+
+	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/artifacts/engine"
+	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/artifacts/models"
+)
+
+// Usage represents the storage usage of a project, all sizes being in bytes.
+type Usage struct {
+	ProjectID             int64  `json:"project_id"`
+	ProjectPath           string `json:"project_path"`
+	StorageSize           int64  `json:"storage_size"`
+	RepositorySize        int64  `json:"repository_size"`
+	JobArtifactsSize      int64  `json:"job_artifacts_size"`
+	PipelineArtifactsSize int64  `json:"pipeline_artifacts_size"`
+	ContainerRegistrySize int64  `json:"container_registry_size"`
+	PackagesSize          int64  `json:"packages_size"`
+	LFSObjectsSize        int64  `json:"lfs_objects_size"`
+	WikiSize              int64  `json:"wiki_size"`
+	SnippetsSize          int64  `json:"snippets_size"`
+	UploadsSize           int64  `json:"uploads_size"`
+}
+
+// UsageFromProject returns the storage usage of input project from its statistics.
+//
+// All sizes are zero when the project has no statistics.
+func UsageFromProject(project models.Project) Usage {
+	statistics := lo.FromPtr(project.Statistics)
+	return Usage{
+		ProjectID:             project.ID,
+		ProjectPath:           project.PathWithNamespace,
+		StorageSize:           statistics.StorageSize,
+		RepositorySize:        statistics.RepositorySize,
+		JobArtifactsSize:      statistics.JobArtifactsSize,
+		PipelineArtifactsSize: statistics.PipelineArtifactsSize,
+		ContainerRegistrySize: statistics.ContainerRegistrySize,
+		PackagesSize:          statistics.PackagesSize,
+		LFSObjectsSize:        statistics.LFSObjectsSize,
+		WikiSize:              statistics.WikiSize,
+		SnippetsSize:          statistics.SnippetsSize,
+		UploadsSize:           statistics.UploadsSize,
+	}
+}
+
+// column represents a size column of storage usage reports.
+type column struct {
+	// header is the column header in table and CSV reports.
+	header string
+
+	// key is the sort key associated to the column.
+	key string
+
+	// size returns the column value of a Usage.
+	size func(Usage) int64
+}
+
+// columns are all size columns of storage usage reports, in the same order as Usage fields.
+var columns = []column{
+	{"storage_size", "storage", func(u Usage) int64 { return u.StorageSize }},
+	{"repository_size", "repository", func(u Usage) int64 { return u.RepositorySize }},
+	{"job_artifacts_size", "artifacts", func(u Usage) int64 { return u.JobArtifactsSize }},
+	{"pipeline_artifacts_size", "pipeline-artifacts", func(u Usage) int64 { return u.PipelineArtifactsSize }},
+	{"container_registry_size", "registry", func(u Usage) int64 { return u.ContainerRegistrySize }},
+	{"packages_size", "packages", func(u Usage) int64 { return u.PackagesSize }},
+	{"lfs_objects_size", "lfs", func(u Usage) int64 { return u.LFSObjectsSize }},
+	{"wiki_size", "wiki", func(u Usage) int64 { return u.WikiSize }},
+	{"snippets_size", "snippets", func(u Usage) int64 { return u.SnippetsSize }},
+	{"uploads_size", "uploads", func(u Usage) int64 { return u.UploadsSize }},
+}
+
+// SortKeyPath is the sort key to sort storage usages by project path.
+const SortKeyPath = "path"
+
+// SortKeys returns all valid sort keys of storage usages.
+func SortKeys() []string {
+	keys := make([]string, 0, len(columns)+1)
+	for _, column := range columns {
+		keys = append(keys, column.key)
+	}
+	return append(keys, SortKeyPath)
+}
+
+// Sort sorts input storage usages by the input key.
+//
+// Usages are sorted by descending size for size keys (e.g. 'storage' or 'artifacts')
+// and by ascending project path for 'path' key, project path being used to sort usages with the same size.
+func Sort(usages []Usage, key string) error {
+	if key == SortKeyPath {
+		slices.SortStableFunc(usages, func(x, y Usage) int { return cmp.Compare(x.ProjectPath, y.ProjectPath) })
+		return nil
+	}
+
+	index := slices.IndexFunc(columns, func(c column) bool { return c.key == key })
+	if index < 0 {
+		return fmt.Errorf("invalid sort key '%s'", key)
+	}
+	size := columns[index].size
+	slices.SortStableFunc(usages, func(x, y Usage) int {
+		return cmp.Or(cmp.Compare(size(y), size(x)), cmp.Compare(x.ProjectPath, y.ProjectPath))
+	})
+	return nil
+}
+
+// Format represents the output format of storage usage reports.
+type Format string
+
+const (
+	// FormatCSV writes storage usages as CSV with a header line, sizes being in bytes.
+	FormatCSV Format = "csv"
+
+	// FormatJSON writes storage usages as a JSON array, sizes being in bytes.
+	FormatJSON Format = "json"
+
+	// FormatTable writes storage usages as a human readable table, sizes being rounded with binary units.
+	FormatTable Format = "table"
+)
+
+// Write writes input storage usages into w with the input format.
+func Write(w io.Writer, format Format, usages []Usage) error {
+	switch format {
+	case FormatCSV:
+		return writeCSV(w, usages)
+	case FormatJSON:
+		return writeJSON(w, usages)
+	case FormatTable:
+		return writeTable(w, usages)
+	default:
+		return fmt.Errorf("invalid format '%s'", format)
+	}
+}
+
+// writeCSV writes input storage usages as CSV into w.
+func writeCSV(w io.Writer, usages []Usage) error {
+	writer := csv.NewWriter(w)
+
+	header := []string{"project_id", "project_path"}
+	for _, column := range columns {
+		header = append(header, column.header)
+	}
+	_ = writer.Write(header) // error is retrieved with writer.Error once flushed
+
+	for _, usage := range usages {
+		record := []string{strconv.FormatInt(usage.ProjectID, 10), usage.ProjectPath}
+		for _, column := range columns {
+			record = append(record, strconv.FormatInt(column.size(usage), 10))
+		}
+		_ = writer.Write(record)
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("write csv: %w", err)
+	}
+	return nil
+}
+
+// writeJSON writes input storage usages as a JSON array into w.
+func writeJSON(w io.Writer, usages []Usage) error {
+	if usages == nil {
+		usages = []Usage{} // avoid writing null when there's no project
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(usages); err != nil {
+		return fmt.Errorf("write json: %w", err)
+	}
+	return nil
+}
+
+// writeTable writes input storage usages as a human readable table into w.
+func writeTable(w io.Writer, usages []Usage) error {
+	writer := tabwriter.NewWriter(w, 0, 0, 3, ' ', tabwriter.AlignRight)
+
+	_, _ = fmt.Fprint(writer, "PROJECT\t")
+	for _, column := range columns {
+		_, _ = fmt.Fprintf(writer, "%s\t", column.key)
+	}
+	_, _ = fmt.Fprintln(writer)
+
+	for _, usage := range usages {
+		_, _ = fmt.Fprintf(writer, "%s\t", usage.ProjectPath)
+		for _, column := range columns {
+			_, _ = fmt.Fprintf(writer, "%s\t", engine.Size(column.size(usage)).Human())
+		}
+		_, _ = fmt.Fprintln(writer)
+	}
+
+	if err := writer.Flush(); err != nil {
+		return fmt.Errorf("write table: %w", err)
+	}
+	return nil
+}
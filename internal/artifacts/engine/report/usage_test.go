@@ -0,0 +1,145 @@
+package report_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/artifacts/engine/report"
+	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/testutils"
+)
+
+func TestSort(t *testing.T) {
+	usages := func() []report.Usage {
+		return []report.Usage{
+			{ProjectPath: "b", StorageSize: 10, JobArtifactsSize: 5},
+			{ProjectPath: "c", StorageSize: 30, JobArtifactsSize: 1},
+			{ProjectPath: "a", StorageSize: 10, JobArtifactsSize: 20},
+		}
+	}
+	paths := func(usages []report.Usage) string {
+		var paths []string
+		for _, usage := range usages {
+			paths = append(paths, usage.ProjectPath)
+		}
+		return strings.Join(paths, ",")
+	}
+
+	t.Run("error_invalid_key", func(t *testing.T) {
+		// Act
+		err := report.Sort(usages(), "invalid")
+
+		// Assert
+		testutils.Error(testutils.Require(t), err)
+		testutils.Contains(t, err.Error(), "invalid sort key 'invalid'")
+	})
+
+	t.Run("success_storage", func(t *testing.T) {
+		// Arrange
+		usages := usages()
+
+		// Act
+		err := report.Sort(usages, "storage")
+
+		// Assert
+		testutils.NoError(testutils.Require(t), err)
+		testutils.Equal(t, "c,a,b", paths(usages)) // a and b have the same size
+	})
+
+	t.Run("success_artifacts", func(t *testing.T) {
+		// Arrange
+		usages := usages()
+
+		// Act
+		err := report.Sort(usages, "artifacts")
+
+		// Assert
+		testutils.NoError(testutils.Require(t), err)
+		testutils.Equal(t, "a,b,c", paths(usages))
+	})
+
+	t.Run("success_path", func(t *testing.T) {
+		// Arrange
+		usages := []report.Usage{{ProjectPath: "c"}, {ProjectPath: "a"}, {ProjectPath: "b"}}
+
+		// Act
+		err := report.Sort(usages, report.SortKeyPath)
+
+		// Assert
+		testutils.NoError(testutils.Require(t), err)
+		testutils.Equal(t, "a,b,c", paths(usages))
+	})
+}
+
+func TestWrite(t *testing.T) {
+	usages := []report.Usage{{ProjectID: 7, ProjectPath: "group/project", StorageSize: 3 << 29, JobArtifactsSize: 1 << 30, WikiSize: 512}}
+
+	t.Run("error_invalid_format", func(t *testing.T) {
+		// Arrange
+		var buf strings.Builder
+
+		// Act
+		err := report.Write(&buf, "xml", usages)
+
+		// Assert
+		testutils.Error(testutils.Require(t), err)
+		testutils.Contains(t, err.Error(), "invalid format 'xml'")
+	})
+
+	t.Run("success_csv", func(t *testing.T) {
+		// Arrange
+		var buf strings.Builder
+
+		// Act
+		err := report.Write(&buf, report.FormatCSV, usages)
+
+		// Assert
+		testutils.NoError(testutils.Require(t), err)
+		expected := "project_id,project_path,storage_size,repository_size,job_artifacts_size,pipeline_artifacts_size,container_registry_size,packages_size,lfs_objects_size,wiki_size,snippets_size,uploads_size\n" +
+			"7,group/project,1610612736,0,1073741824,0,0,0,0,512,0,0\n"
+		testutils.Equal(t, expected, buf.String())
+	})
+
+	t.Run("success_json", func(t *testing.T) {
+		// Arrange
+		var buf strings.Builder
+
+		// Act
+		err := report.Write(&buf, report.FormatJSON, usages)
+
+		// Assert
+		testutils.NoError(testutils.Require(t), err)
+		testutils.Contains(t, buf.String(), `"project_path": "group/project"`)
+		testutils.Contains(t, buf.String(), `"job_artifacts_size": 1073741824`)
+	})
+
+	t.Run("success_json_empty", func(t *testing.T) {
+		// Arrange
+		var buf strings.Builder
+
+		// Act
+		err := report.Write(&buf, report.FormatJSON, nil)
+
+		// Assert
+		testutils.NoError(testutils.Require(t), err)
+		testutils.Equal(t, "[]\n", buf.String())
+	})
+
+	t.Run("success_table", func(t *testing.T) {
+		// Arrange
+		var buf strings.Builder
+
+		// Act
+		err := report.Write(&buf, report.FormatTable, usages)
+
+		// Assert
+		testutils.NoError(testutils.Require(t), err)
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		testutils.Equal(t, 2, len(lines))
+		testutils.Contains(t, lines[0], "PROJECT")
+		testutils.Contains(t, lines[0], "artifacts")
+		testutils.Contains(t, lines[1], "group/project")
+		testutils.Contains(t, lines[1], "1.5GiB")
+		testutils.Contains(t, lines[1], "1.0GiB")
+		testutils.Contains(t, lines[1], "512B")
+	})
+}
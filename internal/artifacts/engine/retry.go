@@ -0,0 +1,157 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
+
+	gitlab "gitlab.com/gitlab-org/api/client-go/v2"
+
+	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/artifacts/models"
+)
+
+// maxRetryDelay is the maximum delay between two attempts, whatever the retry policy base delay and attempt number.
+const maxRetryDelay = time.Minute
+
+// RetryPolicy represents the way failed gitlab api calls (list and delete ones) are retried.
+//
+// A call is retried when it failed with a network error or one of StatusCodes,
+// waiting BaseDelay * 2^(attempt-1) (with a random jitter) between each attempt.
+type RetryPolicy struct {
+	// BaseDelay is the delay before the first retry, doubled for each next retry.
+	BaseDelay time.Duration
+
+	// Jitter is the ratio (between 0 and 1) of random variation applied to delays,
+	// it avoids concurrent failed calls to be retried all at once.
+	Jitter float64
+
+	// MaxAttempts is the maximum number of attempts of a call (including the first one), 1 disables retries.
+	MaxAttempts int
+
+	// StatusCodes is the list of http status codes for which a call is retried.
+	StatusCodes []int
+}
+
+// DefaultRetryPolicy returns the default retry policy,
+// retrying up to 3 times rate limited (429) and server errors (500, 502, 503 and 504) calls.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		BaseDelay:   time.Second,
+		Jitter:      0.2,
+		MaxAttempts: 4,
+		StatusCodes: []int{
+			http.StatusTooManyRequests,
+			http.StatusInternalServerError,
+			http.StatusBadGateway,
+			http.StatusServiceUnavailable,
+			http.StatusGatewayTimeout,
+		},
+	}
+}
+
+// validate returns an error when the retry policy has invalid values.
+func (p RetryPolicy) validate() error {
+	var errs []error
+	if p.MaxAttempts < 1 {
+		errs = append(errs, fmt.Errorf("invalid max attempts '%d'", p.MaxAttempts))
+	}
+	if p.BaseDelay < 0 {
+		errs = append(errs, fmt.Errorf("invalid base delay '%d'", p.BaseDelay))
+	}
+	if p.Jitter < 0 || p.Jitter > 1 {
+		errs = append(errs, fmt.Errorf("invalid jitter '%g'", p.Jitter))
+	}
+	for _, code := range p.StatusCodes {
+		if code < 100 || code > 599 {
+			errs = append(errs, fmt.Errorf("invalid status code '%d'", code))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Retryable returns truthy if input error (returned by a gitlab api call) can be retried.
+//
+// Network (transport) errors are always retryable, while gitlab api errors are only retryable when their status code is one of the policy ones.
+// Context errors (canceled or deadline exceeded) and any other error are never retryable.
+func (p RetryPolicy) Retryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var response *gitlab.ErrorResponse
+	if errors.As(err, &response) && response.Response != nil {
+		return slices.Contains(p.StatusCodes, response.Response.StatusCode)
+	}
+	var status *models.StatusError
+	if errors.As(err, &status) {
+		return slices.Contains(p.StatusCodes, status.StatusCode)
+	}
+
+	// http client wraps all transport errors (connection refused, reset, timeout, etc.) into an url.Error
+	var transport *url.Error
+	return errors.As(err, &transport)
+}
+
+// Delay returns the delay to wait before input attempt (starting at 1 for the first retry).
+func (p RetryPolicy) Delay(attempt int) time.Duration {
+	delay := p.BaseDelay << min(attempt-1, 16)
+	if delay < 0 || delay > maxRetryDelay { // overflow or too long
+		delay = maxRetryDelay
+	}
+	if p.Jitter > 0 {
+		delay += time.Duration((rand.Float64()*2 - 1) * p.Jitter * float64(delay)) //nolint:gosec
+	}
+	return delay
+}
+
+type retryPolicyKeyType string
+
+// RetryPolicyKey is the context key for the retry policy.
+const RetryPolicyKey retryPolicyKeyType = "retry_policy"
+
+// GetRetryPolicy returns the context retry policy.
+//
+// By default calls aren't retried, but it can be set with WithRetryPolicy run option.
+func GetRetryPolicy(ctx context.Context) RetryPolicy {
+	policy, ok := ctx.Value(RetryPolicyKey).(RetryPolicy)
+	if !ok {
+		return RetryPolicy{MaxAttempts: 1}
+	}
+	return policy
+}
+
+// Retry calls fn (a gitlab api call identified by operation, e.g. 'list_project_jobs') until it succeeds,
+// it fails with a non retryable error or the context retry policy max attempts is reached.
+//
+// Each retry is logged with the context logger. The last error is returned when all attempts failed.
+func Retry(ctx context.Context, operation string, fn func() error) error {
+	policy := GetRetryPolicy(ctx)
+
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if attempt >= policy.MaxAttempts || !policy.Retryable(err) {
+			return err
+		}
+
+		delay := policy.Delay(attempt)
+		GetLogger(ctx).Warn("retrying gitlab api call",
+			"attempt", attempt+1,
+			"delay", delay,
+			"error", err,
+			"max_attempts", policy.MaxAttempts,
+			"operation", operation)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		}
+	}
+}
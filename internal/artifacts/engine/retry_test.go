@@ -0,0 +1,146 @@
+package engine_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	gitlab "gitlab.com/gitlab-org/api/client-go/v2"
+
+	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/artifacts/engine"
+	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/artifacts/models"
+	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/testutils"
+)
+
+func TestRetryable(t *testing.T) {
+	policy := engine.DefaultRetryPolicy()
+	errorResponse := func(status int) error {
+		return &gitlab.ErrorResponse{Response: &http.Response{StatusCode: status, Request: &http.Request{}}}
+	}
+
+	t.Run("success_network_error", func(t *testing.T) {
+		testutils.True(t, policy.Retryable(networkError()))
+	})
+
+	t.Run("success_retryable_status_code", func(t *testing.T) {
+		testutils.True(t, policy.Retryable(errorResponse(http.StatusServiceUnavailable)))
+		testutils.True(t, policy.Retryable(errorResponse(http.StatusTooManyRequests)))
+		testutils.True(t, policy.Retryable(&models.StatusError{StatusCode: http.StatusBadGateway}))
+	})
+
+	t.Run("success_not_retryable", func(t *testing.T) {
+		testutils.False(t, policy.Retryable(nil))
+		testutils.False(t, policy.Retryable(errorResponse(http.StatusForbidden)))
+		testutils.False(t, policy.Retryable(&models.StatusError{StatusCode: http.StatusNotModified}))
+		testutils.False(t, policy.Retryable(errors.New("an error")))
+		testutils.False(t, policy.Retryable(context.Canceled))
+	})
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	t.Run("success_exponential", func(t *testing.T) {
+		// Arrange
+		policy := engine.RetryPolicy{BaseDelay: time.Second}
+
+		// Act & Assert
+		testutils.Equal(t, time.Second, policy.Delay(1))
+		testutils.Equal(t, 2*time.Second, policy.Delay(2))
+		testutils.Equal(t, 4*time.Second, policy.Delay(3))
+		testutils.Equal(t, time.Minute, policy.Delay(10)) // capped
+	})
+
+	t.Run("success_jitter", func(t *testing.T) {
+		// Arrange
+		policy := engine.RetryPolicy{BaseDelay: time.Second, Jitter: 0.5}
+
+		// Act
+		delay := policy.Delay(1)
+
+		// Assert
+		testutils.True(t, delay >= 500*time.Millisecond && delay <= 1500*time.Millisecond)
+	})
+}
+
+func TestRetry(t *testing.T) {
+	policy := engine.RetryPolicy{MaxAttempts: 3, StatusCodes: []int{http.StatusBadGateway}}
+
+	t.Run("success_no_policy", func(t *testing.T) {
+		// Arrange
+		var calls int
+
+		// Act
+		err := engine.Retry(t.Context(), "test", func() error {
+			calls++
+			return errors.New("an error")
+		})
+
+		// Assert
+		testutils.Error(t, err)
+		testutils.Equal(t, 1, calls)
+	})
+
+	t.Run("success_after_retries", func(t *testing.T) {
+		// Arrange
+		var buf strings.Builder
+		ctx := context.WithValue(t.Context(), engine.LoggerKey, engine.NewTestLogger(&buf))
+		ctx = context.WithValue(ctx, engine.RetryPolicyKey, policy)
+
+		var calls int
+
+		// Act
+		err := engine.Retry(ctx, "test", func() error {
+			calls++
+			if calls < 3 {
+				return networkError()
+			}
+			return nil
+		})
+
+		// Assert
+		testutils.NoError(t, err)
+		testutils.Equal(t, 3, calls)
+		testutils.Equal(t, 2, strings.Count(buf.String(), "retrying gitlab api call"))
+	})
+
+	t.Run("error_max_attempts", func(t *testing.T) {
+		// Arrange
+		ctx := context.WithValue(t.Context(), engine.RetryPolicyKey, policy)
+		var calls int
+
+		// Act
+		err := engine.Retry(ctx, "test", func() error {
+			calls++
+			return networkError()
+		})
+
+		// Assert
+		testutils.Error(testutils.Require(t), err)
+		testutils.Contains(t, err.Error(), "connection reset by peer")
+		testutils.Equal(t, 3, calls)
+	})
+
+	t.Run("error_not_retryable", func(t *testing.T) {
+		// Arrange
+		ctx := context.WithValue(t.Context(), engine.RetryPolicyKey, policy)
+		var calls int
+
+		// Act
+		err := engine.Retry(ctx, "test", func() error {
+			calls++
+			return &gitlab.ErrorResponse{Response: &http.Response{StatusCode: http.StatusNotFound, Request: &http.Request{}}}
+		})
+
+		// Assert
+		testutils.Error(t, err)
+		testutils.Equal(t, 1, calls)
+	})
+}
+
+// networkError returns an error similar to the one returned by http client on a transport failure.
+func networkError() error {
+	return &url.Error{Op: http.MethodGet, URL: "https://gitlab.com/api/v4/projects", Err: errors.New("connection reset by peer")}
+}
@@ -6,11 +6,73 @@ import (
 	"fmt"
 	"regexp"
 	"time"
+
+	gitlab "gitlab.com/gitlab-org/api/client-go/v2"
+
+	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/artifacts/models"
 )
 
 // RunOption is the signature function for artifact cleanup feature options.
 type RunOption func(RunOptions) RunOptions
 
+// ArchivedMode represents the way archived projects are considered for cleaning.
+type ArchivedMode string
+
+const (
+	// ArchivedExclude never cleans archived projects.
+	ArchivedExclude ArchivedMode = "exclude"
+
+	// ArchivedInclude cleans archived projects along with other projects.
+	ArchivedInclude ArchivedMode = "include"
+
+	// ArchivedOnly only cleans archived projects.
+	ArchivedOnly ArchivedMode = "only"
+)
+
+// validate returns an error when the archived mode isn't a known one.
+func (m ArchivedMode) validate() error {
+	switch m {
+	case ArchivedExclude, ArchivedInclude, ArchivedOnly:
+		return nil
+	default:
+		return fmt.Errorf("invalid archived mode '%s'", m)
+	}
+}
+
+// KeepLatestBy represents the way latest successful jobs' artifacts are kept, whatever their age.
+type KeepLatestBy string
+
+const (
+	// KeepLatestNone doesn't keep any latest successful jobs' artifacts.
+	KeepLatestNone KeepLatestBy = "none"
+
+	// KeepLatestRef keeps artifacts of the most recent successful pipeline of each ref.
+	KeepLatestRef KeepLatestBy = "ref"
+
+	// KeepLatestRefName keeps artifacts of the most recent successful job of each ref and job name.
+	KeepLatestRefName KeepLatestBy = "ref-name"
+)
+
+// validate returns an error when the keep latest mode isn't a known one.
+func (m KeepLatestBy) validate() error {
+	switch m {
+	case KeepLatestNone, KeepLatestRef, KeepLatestRefName:
+		return nil
+	default:
+		return fmt.Errorf("invalid keep latest mode '%s'", m)
+	}
+}
+
+// validateVisibility returns an error when the visibility isn't a known gitlab visibility level.
+func validateVisibility(visibility string) error {
+	switch visibility {
+	case "private", "internal", "public":
+		return nil
+	default:
+		return fmt.Errorf("invalid visibility '%s'", visibility)
+	}
+}
+
 // WithLogger sets the logger in run options.
 //
 // This logger can be accessed later with engine.GetLogger(context.Context) function.
@@ -23,6 +85,42 @@ func WithLogger(logger Logger) RunOption {
 	}
 }
 
+// WithArchivedMode sets the way archived projects are considered for cleaning in run options.
+//
+// Default mode is 'exclude'.
+func WithArchivedMode(mode ArchivedMode) RunOption {
+	return func(o RunOptions) RunOptions {
+		o.ArchivedMode = mode
+		return o
+	}
+}
+
+// WithArchivedOnlyThresholdDuration sets the duration threshold of archived projects with 'only' archived mode in run options.
+//
+// It takes precedence over threshold duration (and policies ones) for archived projects,
+// since their storage is rarely needed anymore.
+//
+// When not provided, archived projects are cleaned with the same threshold duration as other projects.
+func WithArchivedOnlyThresholdDuration(thresholdDuration time.Duration) RunOption {
+	return func(o RunOptions) RunOptions {
+		o.ArchivedOnlyThresholdDuration = thresholdDuration
+		return o
+	}
+}
+
+// WithIncludeArchivedThresholdDuration sets the duration threshold of archived projects with 'include' archived mode in run options.
+//
+// It takes precedence over threshold duration (and policies ones) for archived projects,
+// since their storage is rarely needed anymore.
+//
+// When not provided, archived projects are cleaned with the same threshold duration as other projects.
+func WithIncludeArchivedThresholdDuration(thresholdDuration time.Duration) RunOption {
+	return func(o RunOptions) RunOptions {
+		o.IncludeArchivedThresholdDuration = thresholdDuration
+		return o
+	}
+}
+
 // WithDryRun sets the dry-run mode in run options.
 //
 // When running in dry run, no actual cleaning of artifacts will be performed.
@@ -33,6 +131,160 @@ func WithDryRun(dryRun bool) RunOption {
 	}
 }
 
+// WithExcludePaths sets the excluded paths (regexps or raw paths) in run options.
+//
+// A path must be a valid regexp (or else NewRunOptions will return an error).
+//
+// Excluded paths always win over paths, a project matching any of them will never be cleaned.
+func WithExcludePaths(paths ...string) RunOption {
+	return func(o RunOptions) RunOptions {
+		o.ExcludePaths = paths
+		return o
+	}
+}
+
+// WithGroups sets the groups (IDs or paths with namespace) in run options.
+//
+// When provided, only projects of those groups and their subgroups will be read during artifacts.Run function,
+// instead of all projects the token is a maintainer of. Paths are still used to filter those projects,
+// but all of them are cleaned when no path is provided.
+func WithGroups(groups ...string) RunOption {
+	return func(o RunOptions) RunOptions {
+		o.Groups = groups
+		return o
+	}
+}
+
+// WithForceRescan sets the force rescan flag of a resumed run in run options.
+//
+// When enabled, projects completed in a previous run are processed again instead of being skipped.
+func WithForceRescan(forceRescan bool) RunOption {
+	return func(o RunOptions) RunOptions {
+		o.ForceRescan = forceRescan
+		return o
+	}
+}
+
+// WithGracePeriod sets the grace period of in-flight deletions in run options.
+//
+// When a run is canceled (e.g. on SIGINT or SIGTERM), no new project nor deletion is started,
+// but in-flight deletions are given this grace period to finish before being canceled too.
+//
+// By default, in-flight deletions are canceled with the run.
+func WithGracePeriod(gracePeriod time.Duration) RunOption {
+	return func(o RunOptions) RunOptions {
+		o.GracePeriod = gracePeriod
+		return o
+	}
+}
+
+// WithIdleDuration sets the minimum idle duration of projects in run options.
+//
+// When provided, only projects without any activity since current execution time minus this duration will be cleaned.
+// It can be useful to clean idle projects with a more aggressive threshold duration.
+func WithIdleDuration(idleDuration time.Duration) RunOption {
+	return func(o RunOptions) RunOptions {
+		o.IdleDuration = idleDuration
+		return o
+	}
+}
+
+// WithJobConcurrency sets the maximum number of jobs (or other project items, e.g. registry tags) processed concurrently
+// for each project in run options.
+//
+// Items of all projects are processed in a pool of project concurrency times job concurrency routines,
+// as such a project with a lot of items (e.g. a monorepo) can't starve other projects.
+//
+// Default job concurrency is 100.
+func WithJobConcurrency(concurrency int) RunOption {
+	return func(o RunOptions) RunOptions {
+		o.JobConcurrency = concurrency
+		return o
+	}
+}
+
+// WithKeepLatest sets the keep 'latest' tag rule in run options.
+//
+// When enabled, container registry 'latest' tags will never be deleted, whatever their age.
+func WithKeepLatest(keepLatest bool) RunOption {
+	return func(o RunOptions) RunOptions {
+		o.KeepLatest = keepLatest
+		return o
+	}
+}
+
+// WithKeepLatestJobsBy sets the keep latest successful jobs' artifacts mode in run options.
+//
+// Examples:
+//
+//	Given the keep latest mode is 'ref'
+//	And the most recent successful pipeline on 'main' ref was created a year ago
+//	Then artifacts of all its jobs will not be deleted, whatever the threshold duration
+//
+//	Given the keep latest mode is 'ref-name'
+//	And the most recent successful 'build' job on 'main' ref was created a year ago
+//	Then its artifacts will not be deleted, whatever the threshold duration
+//
+// Default mode is 'none'.
+func WithKeepLatestJobsBy(mode KeepLatestBy) RunOption {
+	return func(o RunOptions) RunOptions {
+		o.KeepLatestJobs = mode
+		return o
+	}
+}
+
+// WithKeepSemver sets the keep semantic version tags rule in run options.
+//
+// When enabled, container registry tags named after a semantic version (e.g. 'v1.2.3' or '1.2.3-rc.1')
+// will never be deleted, whatever their age.
+func WithKeepSemver(keepSemver bool) RunOption {
+	return func(o RunOptions) RunOptions {
+		o.KeepSemver = keepSemver
+		return o
+	}
+}
+
+// WithKeepVersions sets the number of most recent versions to keep per package in run options.
+//
+// Those versions will never be deleted, whatever their age.
+// Versions are grouped by package type and name (e.g. 'maven/com/example/app').
+func WithKeepVersions(keepVersions int) RunOption {
+	return func(o RunOptions) RunOptions {
+		o.KeepVersions = keepVersions
+		return o
+	}
+}
+
+// WithMaxArtifactsSize sets the storage budget of each project jobs' artifacts in run options.
+//
+// When provided, the largest (and then oldest) jobs' artifacts are deleted only until the project
+// artifacts size fits in that budget. Threshold duration is still applied as a floor,
+// no job younger than it is cleaned, even if the budget isn't met.
+//
+// Examples:
+//
+//	Given a project with 7GiB of artifacts
+//	And the max artifacts size is 5GiB
+//	And jobs older than the threshold duration have 1GiB, 3GiB and 2GiB of artifacts
+//	Then only the 3GiB job's artifacts will be deleted
+func WithMaxArtifactsSize(size Size) RunOption {
+	return func(o RunOptions) RunOptions {
+		o.MaxArtifactsSize = size
+		return o
+	}
+}
+
+// WithMinAccessLevel sets the minimum access level the token must have on read projects in run options.
+//
+// Default access level is maintainer since it's required to delete anything (artifacts, tags, etc.),
+// read-only runs (e.g. storage usage reports) can lower it to reporter.
+func WithMinAccessLevel(level gitlab.AccessLevelValue) RunOption {
+	return func(o RunOptions) RunOptions {
+		o.MinAccessLevel = level
+		return o
+	}
+}
+
 // WithPaths sets the paths (regexps or raw paths) in run options.
 //
 // A path must be a valid regexp (or else NewRunOptions will return an error).
@@ -45,6 +297,105 @@ func WithPaths(paths ...string) RunOption {
 	}
 }
 
+// WithProjectConcurrency sets the maximum number of projects processed concurrently in run options.
+//
+// Default project concurrency is 10.
+func WithProjectConcurrency(concurrency int) RunOption {
+	return func(o RunOptions) RunOptions {
+		o.ProjectConcurrency = concurrency
+		return o
+	}
+}
+
+// WithProtectedThresholdDuration sets the duration threshold of jobs ran on protected branches and tags in run options.
+//
+// It takes precedence over all other threshold durations for those jobs.
+//
+// When not provided, artifacts of jobs ran on protected branches and tags are never deleted.
+func WithProtectedThresholdDuration(thresholdDuration time.Duration) RunOption {
+	return func(o RunOptions) RunOptions {
+		o.ProtectedThresholdDuration = thresholdDuration
+		return o
+	}
+}
+
+// WithReport sets whether the cleanup outcome of every processed project and matched job must be kept in run options.
+//
+// When disabled, only run totals are kept to avoid holding all matched jobs in memory during large runs.
+func WithReport(report bool) RunOption {
+	return func(o RunOptions) RunOptions {
+		o.Report = report
+		return o
+	}
+}
+
+// WithResume sets the resume flag in run options.
+//
+// When enabled, the run resumes from its state file (see WithStateFile), skipping completed projects
+// and listing projects jobs from their last listed page instead of the first one.
+func WithResume(resume bool) RunOption {
+	return func(o RunOptions) RunOptions {
+		o.Resume = resume
+		return o
+	}
+}
+
+// WithRetryPolicy sets the retry policy of failed gitlab api calls in run options.
+//
+// Retries are logged with the context logger and list calls are retried on the failed page,
+// as such a transient failure doesn't skip the remaining pages.
+//
+// When not provided, failed calls aren't retried. See DefaultRetryPolicy for sensible values.
+func WithRetryPolicy(policy RetryPolicy) RunOption {
+	return func(o RunOptions) RunOptions {
+		o.RetryPolicy = policy
+		return o
+	}
+}
+
+// WithStateFile sets the state file path in run options.
+//
+// When provided, the run progress (completed projects and last listed jobs page of each project)
+// is recorded into this JSON file, allowing a crashed or interrupted run to be resumed with WithResume.
+//
+// In dry run mode, the state file is only read and the run progress isn't recorded.
+func WithStateFile(path string) RunOption {
+	return func(o RunOptions) RunOptions {
+		o.StateFile = path
+		return o
+	}
+}
+
+// WithStateScope sets the scope of the run progress in its state file in run options.
+//
+// It allows multiple runs (e.g. configuration rules) to share the same state file.
+func WithStateScope(scope string) RunOption {
+	return func(o RunOptions) RunOptions {
+		o.StateScope = scope
+		return o
+	}
+}
+
+// WithStatistics sets whether projects storage statistics must be read along with projects in run options.
+//
+// Statistics are requested with projects listing when possible (they can't with groups projects listing).
+func WithStatistics(statistics bool) RunOption {
+	return func(o RunOptions) RunOptions {
+		o.Statistics = statistics
+		return o
+	}
+}
+
+// WithTopics sets the required topics of projects in run options.
+//
+// When provided, only projects having all those topics will be cleaned.
+func WithTopics(topics ...string) RunOption {
+	return func(o RunOptions) RunOptions {
+		o.Topics = topics
+		return o
+	}
+}
+
 // WithThresholdDuration sets the duration threshold in run options.
 //
 // If the creation date of a job is less than current execution time
@@ -73,24 +424,152 @@ func WithThresholdDuration(thresholdDuration time.Duration) RunOption {
 	}
 }
 
+// WithVisibilities sets the visibility levels (private, internal or public) of projects in run options.
+//
+// When provided, only projects having one of those visibility levels will be cleaned.
+func WithVisibilities(visibilities ...string) RunOption {
+	return func(o RunOptions) RunOptions {
+		o.Visibilities = visibilities
+		return o
+	}
+}
+
 // RunOptions contains all available options for artifact cleanup feature.
 type RunOptions struct {
+	// ArchivedMode is the way archived projects are considered for cleaning.
+	ArchivedMode ArchivedMode
+
+	// ArchivedOnlyThresholdDuration is the duration threshold of archived projects with 'only' archived mode.
+	//
+	// See WithArchivedOnlyThresholdDuration option for more information.
+	ArchivedOnlyThresholdDuration time.Duration
+
 	// DryRun is a flag to enable dry-run mode.
 	DryRun bool
 
+	// ExcludePaths is a list of paths (regexps or raw paths) to exclude projects from cleaning.
+	//
+	// It takes precedence over Paths.
+	ExcludePaths []string
+
+	// ForceRescan is a flag to process again projects completed in a previous run when resuming.
+	ForceRescan bool
+
+	// GracePeriod is the duration given to in-flight deletions to finish once the run is canceled.
+	//
+	// See WithGracePeriod option for more information.
+	GracePeriod time.Duration
+
+	// Groups is a list of groups (IDs or paths with namespace) to read projects from.
+	//
+	// See WithGroups option for more information.
+	Groups []string
+
+	// IdleDuration is the minimum duration elapsed since projects last activity.
+	//
+	// See WithIdleDuration option for more information.
+	IdleDuration time.Duration
+
+	// IncludeArchivedThresholdDuration is the duration threshold of archived projects with 'include' archived mode.
+	//
+	// See WithIncludeArchivedThresholdDuration option for more information.
+	IncludeArchivedThresholdDuration time.Duration
+
+	// JobConcurrency is the maximum number of jobs (or other project items) processed concurrently for each project.
+	//
+	// See WithJobConcurrency option for more information.
+	JobConcurrency int
+
+	// JobFilters represents filters on jobs to consider for artifacts cleaning.
+	//
+	// See WithJobFilters option for more information.
+	JobFilters JobFilters
+
+	// KeepLatest is a flag to never delete container registry 'latest' tags.
+	KeepLatest bool
+
+	// KeepLatestJobs is the way latest successful jobs' artifacts are kept.
+	//
+	// See WithKeepLatestJobsBy option for more information.
+	KeepLatestJobs KeepLatestBy
+
+	// KeepSemver is a flag to never delete container registry tags named after a semantic version.
+	KeepSemver bool
+
+	// KeepVersions is the number of most recent versions to never delete per package.
+	KeepVersions int
+
+	// MaxArtifactsSize is the storage budget of each project jobs' artifacts.
+	//
+	// See WithMaxArtifactsSize option for more information.
+	MaxArtifactsSize Size
+
+	// MinAccessLevel is the minimum access level the token must have on read projects.
+	//
+	// See WithMinAccessLevel option for more information.
+	MinAccessLevel gitlab.AccessLevelValue
+
+	// Policies is the ordered list of retention policies.
+	//
+	// See WithPolicies option for more information.
+	Policies []Policy
+
 	// Paths is a list of paths (regexps or raw paths) to filter projects to clean.
 	//
 	// It can be useful to only clean specific projects
 	// in case given token / developer is maintainer of a lot of projects.
 	Paths []string
 
+	// ProjectConcurrency is the maximum number of projects processed concurrently.
+	ProjectConcurrency int
+
+	// ProtectedThresholdDuration is the duration threshold of jobs ran on protected branches and tags.
+	//
+	// See WithProtectedThresholdDuration option for more information.
+	ProtectedThresholdDuration time.Duration
+
+	// Report is a flag to keep the cleanup outcome of every processed project and matched job.
+	//
+	// See WithReport option for more information.
+	Report bool
+
+	// Resume is a flag to resume the run from its state file.
+	//
+	// See WithResume option for more information.
+	Resume bool
+
+	// RetryPolicy is the retry policy of failed gitlab api calls.
+	//
+	// See WithRetryPolicy option for more information.
+	RetryPolicy RetryPolicy
+
+	// Statistics is a flag to read projects storage statistics along with projects.
+	//
+	// See WithStatistics option for more information.
+	Statistics bool
+
+	// StateFile is the path of the file where the run progress is recorded.
+	//
+	// See WithStateFile option for more information.
+	StateFile string
+
+	// StateScope is the scope of the run progress in its state file.
+	StateScope string
+
 	// ThresholdDuration is the duration threshold.
 	//
 	// See WithThresholdDuration option for more information.
 	ThresholdDuration time.Duration
 
-	logger  Logger
-	regexps []*regexp.Regexp
+	// Topics is a list of topics projects must all have to be cleaned.
+	Topics []string
+
+	// Visibilities is a list of visibility levels projects must have one of to be cleaned.
+	Visibilities []string
+
+	excludeRegexps []*regexp.Regexp
+	logger         Logger
+	regexps        []*regexp.Regexp
 }
 
 // NewRunOptions creates a new RunOptions instance with the given options.
@@ -108,13 +587,99 @@ func NewRunOptions(opts ...RunOption) (RunOptions, error) {
 		}
 		ro.regexps = append(ro.regexps, reg)
 	}
+	for _, path := range ro.ExcludePaths {
+		reg, err := regexp.Compile(path)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid regexp '%s': %w", path, err))
+		}
+		ro.excludeRegexps = append(ro.excludeRegexps, reg)
+	}
 
 	if ro.logger == nil {
 		ro.logger = &noopLogger{}
 	}
+	if ro.MinAccessLevel == 0 {
+		ro.MinAccessLevel = gitlab.MaintainerPermissions
+	}
+	if ro.KeepLatestJobs == "" {
+		ro.KeepLatestJobs = KeepLatestNone
+	}
+	if err := ro.KeepLatestJobs.validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if ro.ArchivedMode == "" {
+		ro.ArchivedMode = ArchivedExclude
+	}
+	if err := ro.ArchivedMode.validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if ro.ArchivedOnlyThresholdDuration < 0 {
+		errs = append(errs, fmt.Errorf("invalid archived only threshold duration '%d'", ro.ArchivedOnlyThresholdDuration))
+	}
+	if ro.IncludeArchivedThresholdDuration < 0 {
+		errs = append(errs, fmt.Errorf("invalid include archived threshold duration '%d'", ro.IncludeArchivedThresholdDuration))
+	}
+	if ro.ProtectedThresholdDuration < 0 {
+		errs = append(errs, fmt.Errorf("invalid protected threshold duration '%d'", ro.ProtectedThresholdDuration))
+	}
+	if ro.IdleDuration < 0 {
+		errs = append(errs, fmt.Errorf("invalid idle duration '%d'", ro.IdleDuration))
+	}
+	if ro.GracePeriod < 0 {
+		errs = append(errs, fmt.Errorf("invalid grace period '%d'", ro.GracePeriod))
+	}
+	for _, visibility := range ro.Visibilities {
+		if err := validateVisibility(visibility); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	jobFilters, err := ro.JobFilters.compile()
+	if err != nil {
+		errs = append(errs, fmt.Errorf("invalid job filters: %w", err))
+	}
+	ro.JobFilters = jobFilters
+	if ro.MaxArtifactsSize < 0 {
+		errs = append(errs, fmt.Errorf("invalid max artifacts size '%d'", ro.MaxArtifactsSize))
+	}
+	if ro.KeepVersions < 0 {
+		errs = append(errs, fmt.Errorf("invalid keep versions '%d'", ro.KeepVersions))
+	}
+	if ro.ProjectConcurrency == 0 {
+		ro.ProjectConcurrency = 10
+	}
+	if ro.ProjectConcurrency < 0 {
+		errs = append(errs, fmt.Errorf("invalid project concurrency '%d'", ro.ProjectConcurrency))
+	}
+	if ro.JobConcurrency == 0 {
+		ro.JobConcurrency = 100
+	}
+	if ro.JobConcurrency < 0 {
+		errs = append(errs, fmt.Errorf("invalid job concurrency '%d'", ro.JobConcurrency))
+	}
+	if ro.RetryPolicy.MaxAttempts == 0 {
+		ro.RetryPolicy.MaxAttempts = 1
+	}
+	if err := ro.RetryPolicy.validate(); err != nil {
+		errs = append(errs, fmt.Errorf("invalid retry policy: %w", err))
+	}
+	if ro.Resume && ro.StateFile == "" {
+		errs = append(errs, errors.New("resume requires a state file"))
+	}
+	if ro.ForceRescan && !ro.Resume {
+		errs = append(errs, errors.New("force rescan requires resume"))
+	}
 	if ro.ThresholdDuration <= 0 {
 		errs = append(errs, fmt.Errorf("invalid threshold duration '%d'", ro.ThresholdDuration))
 	}
+	policies := make([]Policy, 0, len(ro.Policies))
+	for i, policy := range ro.Policies {
+		compiled, err := policy.compile(ro.ThresholdDuration)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid policy #%d: %w", i+1, err))
+		}
+		policies = append(policies, compiled)
+	}
+	ro.Policies = policies
 
 	return ro, errors.Join(errs...)
 }
@@ -122,9 +687,40 @@ func NewRunOptions(opts ...RunOption) (RunOptions, error) {
 // Context returns a new context with various options saved in it.
 func (ro RunOptions) Context(parent context.Context) context.Context {
 	ctx := context.WithValue(parent, LoggerKey, ro.logger)
+	ctx = context.WithValue(ctx, RetryPolicyKey, ro.RetryPolicy)
 	return ctx
 }
 
+// ExcludeRegexps returns the compiled regexps from options excluded paths.
+func (ro RunOptions) ExcludeRegexps() []*regexp.Regexp {
+	return ro.excludeRegexps
+}
+
+// PoolSizes returns the routines pools sizes of a run, one for projects and one for their jobs (or other items).
+func (ro RunOptions) PoolSizes() []int {
+	return []int{ro.ProjectConcurrency, ro.ProjectConcurrency * ro.JobConcurrency}
+}
+
+// ProjectThreshold returns the duration threshold to apply on input project.
+//
+// Archived projects threshold of run options archived mode takes precedence over the threshold of the policy matching the project path
+// (run options threshold duration when no policy matches).
+func (ro RunOptions) ProjectThreshold(project models.Project) time.Duration {
+	if project.Archived {
+		var archived time.Duration
+		switch ro.ArchivedMode {
+		case ArchivedInclude:
+			archived = ro.IncludeArchivedThresholdDuration
+		case ArchivedOnly:
+			archived = ro.ArchivedOnlyThresholdDuration
+		}
+		if archived > 0 {
+			return archived
+		}
+	}
+	return ro.Policy(project.PathWithNamespace).ThresholdDuration
+}
+
 // Regexps returns the compiled regexps from options paths.
 func (ro RunOptions) Regexps() []*regexp.Regexp {
 	return ro.regexps
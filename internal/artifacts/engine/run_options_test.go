@@ -1,10 +1,12 @@
 package engine_test
 
 import (
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/artifacts/engine"
+	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/artifacts/models"
 	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/testutils"
 )
 
@@ -21,6 +23,187 @@ func TestRunOptions(t *testing.T) {
 		testutils.Contains(t, err.Error(), `invalid regexp '/\/\'`)
 	})
 
+	t.Run("error_invalid_exclude_regexps", func(t *testing.T) {
+		// Arrange
+		opts := []engine.RunOption{
+			engine.WithExcludePaths(`^valid$`, `/\/\`),
+			engine.WithThresholdDuration(time.Hour),
+		}
+
+		// Act
+		_, err := engine.NewRunOptions(opts...)
+
+		// Assert
+		testutils.Error(testutils.Require(t), err)
+		testutils.Contains(t, err.Error(), `invalid regexp '/\/\'`)
+	})
+
+	t.Run("error_invalid_project_filters", func(t *testing.T) {
+		// Arrange
+		opts := []engine.RunOption{
+			engine.WithIdleDuration(-time.Hour),
+			engine.WithThresholdDuration(time.Hour),
+			engine.WithVisibilities("private", "secret"),
+		}
+
+		// Act
+		_, err := engine.NewRunOptions(opts...)
+
+		// Assert
+		testutils.Error(testutils.Require(t), err)
+		testutils.Contains(t, err.Error(), "invalid idle duration")
+		testutils.Contains(t, err.Error(), "invalid visibility 'secret'")
+		testutils.NotContains(t, err.Error(), "invalid visibility 'private'")
+	})
+
+	t.Run("error_invalid_archived", func(t *testing.T) {
+		// Arrange
+		opts := []engine.RunOption{
+			engine.WithArchivedMode("invalid"),
+			engine.WithArchivedOnlyThresholdDuration(-time.Hour),
+			engine.WithIncludeArchivedThresholdDuration(-time.Hour),
+			engine.WithThresholdDuration(time.Hour),
+		}
+
+		// Act
+		_, err := engine.NewRunOptions(opts...)
+
+		// Assert
+		testutils.Error(testutils.Require(t), err)
+		testutils.Contains(t, err.Error(), "invalid archived mode 'invalid'")
+		testutils.Contains(t, err.Error(), "invalid archived only threshold duration")
+		testutils.Contains(t, err.Error(), "invalid include archived threshold duration")
+	})
+
+	t.Run("error_invalid_keep_latest_by", func(t *testing.T) {
+		// Arrange
+		opts := []engine.RunOption{
+			engine.WithKeepLatestJobsBy("invalid"),
+			engine.WithThresholdDuration(time.Hour),
+		}
+
+		// Act
+		_, err := engine.NewRunOptions(opts...)
+
+		// Assert
+		testutils.Error(testutils.Require(t), err)
+		testutils.Contains(t, err.Error(), "invalid keep latest mode 'invalid'")
+	})
+
+	t.Run("error_invalid_keep_versions", func(t *testing.T) {
+		// Arrange
+		opts := []engine.RunOption{
+			engine.WithKeepVersions(-1),
+			engine.WithThresholdDuration(time.Hour),
+		}
+
+		// Act
+		_, err := engine.NewRunOptions(opts...)
+
+		// Assert
+		testutils.Error(testutils.Require(t), err)
+		testutils.Contains(t, err.Error(), "invalid keep versions '-1'")
+	})
+
+	t.Run("error_invalid_job_filters", func(t *testing.T) {
+		// Arrange
+		opts := []engine.RunOption{
+			engine.WithJobFilters(engine.JobFilters{ExcludeStages: []string{"^deploy("}, Statuses: []string{"done"}}),
+			engine.WithThresholdDuration(time.Hour),
+		}
+
+		// Act
+		_, err := engine.NewRunOptions(opts...)
+
+		// Assert
+		testutils.Error(testutils.Require(t), err)
+		testutils.Contains(t, err.Error(), "invalid job filters")
+		testutils.Contains(t, err.Error(), "invalid regexp '^deploy('")
+		testutils.Contains(t, err.Error(), "invalid job status 'done'")
+	})
+
+	t.Run("error_resume_without_state_file", func(t *testing.T) {
+		// Arrange
+		opts := []engine.RunOption{
+			engine.WithResume(true),
+			engine.WithThresholdDuration(time.Hour),
+		}
+
+		// Act
+		_, err := engine.NewRunOptions(opts...)
+
+		// Assert
+		testutils.Error(testutils.Require(t), err)
+		testutils.Contains(t, err.Error(), "resume requires a state file")
+	})
+
+	t.Run("error_force_rescan_without_resume", func(t *testing.T) {
+		// Arrange
+		opts := []engine.RunOption{
+			engine.WithForceRescan(true),
+			engine.WithStateFile("state.json"),
+			engine.WithThresholdDuration(time.Hour),
+		}
+
+		// Act
+		_, err := engine.NewRunOptions(opts...)
+
+		// Assert
+		testutils.Error(testutils.Require(t), err)
+		testutils.Contains(t, err.Error(), "force rescan requires resume")
+	})
+
+	t.Run("error_invalid_grace_period", func(t *testing.T) {
+		// Arrange
+		opts := []engine.RunOption{
+			engine.WithGracePeriod(-time.Second),
+			engine.WithThresholdDuration(time.Hour),
+		}
+
+		// Act
+		_, err := engine.NewRunOptions(opts...)
+
+		// Assert
+		testutils.Error(testutils.Require(t), err)
+		testutils.Contains(t, err.Error(), "invalid grace period '-1000000000'")
+	})
+
+	t.Run("error_invalid_concurrency", func(t *testing.T) {
+		// Arrange
+		opts := []engine.RunOption{
+			engine.WithJobConcurrency(-1),
+			engine.WithProjectConcurrency(-2),
+			engine.WithThresholdDuration(time.Hour),
+		}
+
+		// Act
+		_, err := engine.NewRunOptions(opts...)
+
+		// Assert
+		testutils.Error(testutils.Require(t), err)
+		testutils.Contains(t, err.Error(), "invalid project concurrency '-2'")
+		testutils.Contains(t, err.Error(), "invalid job concurrency '-1'")
+	})
+
+	t.Run("error_invalid_retry_policy", func(t *testing.T) {
+		// Arrange
+		opts := []engine.RunOption{
+			engine.WithRetryPolicy(engine.RetryPolicy{BaseDelay: -time.Second, Jitter: 2, MaxAttempts: -1, StatusCodes: []int{42}}),
+			engine.WithThresholdDuration(time.Hour),
+		}
+
+		// Act
+		_, err := engine.NewRunOptions(opts...)
+
+		// Assert
+		testutils.Error(testutils.Require(t), err)
+		testutils.Contains(t, err.Error(), "invalid retry policy")
+		testutils.Contains(t, err.Error(), "invalid max attempts '-1'")
+		testutils.Contains(t, err.Error(), "invalid base delay '-1000000000'")
+		testutils.Contains(t, err.Error(), "invalid jitter '2'")
+		testutils.Contains(t, err.Error(), "invalid status code '42'")
+	})
+
 	t.Run("success_defaults", func(t *testing.T) {
 		// Arrange
 		opts := []engine.RunOption{
@@ -33,6 +216,47 @@ func TestRunOptions(t *testing.T) {
 		// Assert
 		testutils.NoError(testutils.Require(t), err)
 		testutils.Equal(t, 12*time.Hour, runOptions.ThresholdDuration)
+		testutils.Equal(t, engine.KeepLatestNone, runOptions.KeepLatestJobs)
+		testutils.Equal(t, engine.ArchivedExclude, runOptions.ArchivedMode)
+		testutils.Equal(t, "failed,success", strings.Join(runOptions.JobFilters.Statuses, ","))
 		testutils.NotNil(t, engine.GetLogger(runOptions.Context(t.Context())))
+		testutils.Equal(t, 1, engine.GetRetryPolicy(runOptions.Context(t.Context())).MaxAttempts)
+		poolSizes := runOptions.PoolSizes()
+		testutils.Equal(testutils.Require(t), 2, len(poolSizes))
+		testutils.Equal(t, 10, poolSizes[0])
+		testutils.Equal(t, 1000, poolSizes[1])
+	})
+	t.Run("success_project_threshold", func(t *testing.T) {
+		// Arrange
+		archivedOpts := []engine.RunOption{
+			engine.WithArchivedOnlyThresholdDuration(time.Hour),
+			engine.WithIncludeArchivedThresholdDuration(6 * time.Hour),
+			engine.WithThresholdDuration(12 * time.Hour),
+		}
+		withArchived, err := engine.NewRunOptions(append(archivedOpts, engine.WithArchivedMode(engine.ArchivedOnly))...)
+		testutils.NoError(testutils.Require(t), err)
+		withIncluded, err := engine.NewRunOptions(append(archivedOpts, engine.WithArchivedMode(engine.ArchivedInclude))...)
+		testutils.NoError(testutils.Require(t), err)
+		withoutArchived, err := engine.NewRunOptions(engine.WithThresholdDuration(12 * time.Hour))
+		testutils.NoError(testutils.Require(t), err)
+
+		withPolicy, err := engine.NewRunOptions(
+			engine.WithPolicies(engine.Policy{Paths: []string{"^releases/"}, ThresholdDuration: 24 * time.Hour}),
+			engine.WithThresholdDuration(12*time.Hour))
+		testutils.NoError(testutils.Require(t), err)
+
+		// Act
+		archived := withArchived.ProjectThreshold(models.Project{Archived: true, PathWithNamespace: "group/project"})
+		included := withIncluded.ProjectThreshold(models.Project{Archived: true, PathWithNamespace: "group/project"})
+		active := withIncluded.ProjectThreshold(models.Project{PathWithNamespace: "group/project"})
+		fallback := withoutArchived.ProjectThreshold(models.Project{Archived: true, PathWithNamespace: "group/project"})
+		policy := withPolicy.ProjectThreshold(models.Project{PathWithNamespace: "releases/project"})
+
+		// Assert
+		testutils.Equal(t, time.Hour, archived)
+		testutils.Equal(t, 6*time.Hour, included)
+		testutils.Equal(t, 12*time.Hour, active)
+		testutils.Equal(t, 12*time.Hour, fallback)
+		testutils.Equal(t, 24*time.Hour, policy)
 	})
 }
@@ -0,0 +1,109 @@
+package engine
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// Size represents a storage size in bytes.
+//
+// It can be parsed from human readable values with decimal (e.g. '500MB') or binary (e.g. '5GiB') units.
+type Size int64
+
+// sizeUnits are the units a Size can be parsed from (case insensitive), ordered from the largest to the smallest one.
+var sizeUnits = []struct {
+	name   string
+	bytes  int64
+	binary bool
+}{
+	{"TiB", 1 << 40, true},
+	{"TB", 1e12, false},
+	{"GiB", 1 << 30, true},
+	{"GB", 1e9, false},
+	{"MiB", 1 << 20, true},
+	{"MB", 1e6, false},
+	{"KiB", 1 << 10, true},
+	{"KB", 1e3, false},
+	{"B", 1, false},
+}
+
+var sizeRegexp = regexp.MustCompile(`^(\d+(?:\.\d+)?)\s*([a-zA-Z]*)$`)
+
+// ParseSize parses a storage size (e.g. '5GiB', '500MB', '1.5GB' or '1024').
+//
+// A value without unit is considered to be in bytes.
+func ParseSize(value string) (Size, error) {
+	matches := sizeRegexp.FindStringSubmatch(strings.TrimSpace(value))
+	if matches == nil {
+		return 0, fmt.Errorf("invalid size '%s'", value)
+	}
+
+	number, err := strconv.ParseFloat(matches[1], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size '%s': %w", value, err)
+	}
+	if matches[2] == "" {
+		matches[2] = "B"
+	}
+	for _, unit := range sizeUnits {
+		if strings.EqualFold(unit.name, matches[2]) {
+			bytes := number * float64(unit.bytes)
+			if bytes > math.MaxInt64 {
+				return 0, fmt.Errorf("invalid size '%s': too large", value)
+			}
+			return Size(bytes), nil
+		}
+	}
+	return 0, fmt.Errorf("invalid size '%s': unknown unit '%s'", value, matches[2])
+}
+
+// String returns the size with the largest unit it's a multiple of (e.g. '5GiB').
+func (s Size) String() string {
+	if s == 0 {
+		return "0" // without unit to be considered as a zero value in command line flags usage
+	}
+	for _, unit := range sizeUnits {
+		if int64(s)%unit.bytes == 0 {
+			return strconv.FormatInt(int64(s)/unit.bytes, 10) + unit.name
+		}
+	}
+	return strconv.FormatInt(int64(s), 10) + "B"
+}
+
+// Human returns the size rounded to one decimal with the largest binary unit it's greater than (e.g. '1.5GiB').
+func (s Size) Human() string {
+	for _, unit := range sizeUnits {
+		if unit.binary && int64(s) >= unit.bytes {
+			return strconv.FormatFloat(float64(s)/float64(unit.bytes), 'f', 1, 64) + unit.name
+		}
+	}
+	return strconv.FormatInt(int64(s), 10) + "B"
+}
+
+// Set parses and sets the input value, it allows Size to be used as a command line flag value.
+func (s *Size) Set(value string) error {
+	size, err := ParseSize(value)
+	if err != nil {
+		return err
+	}
+	*s = size
+	return nil
+}
+
+// Type returns the type name of Size when used as a command line flag value.
+func (*Size) Type() string {
+	return "size"
+}
+
+// UnmarshalYAML parses the input node value, it allows Size to be decoded from configuration files.
+func (s *Size) UnmarshalYAML(node *yaml.Node) error {
+	if err := s.Set(node.Value); err != nil {
+		return fmt.Errorf("line %d: %w", node.Line, err)
+	}
+	return nil
+}
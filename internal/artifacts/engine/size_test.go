@@ -0,0 +1,84 @@
+package engine_test
+
+import (
+	"testing"
+
+	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/artifacts/engine"
+	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/testutils"
+)
+
+func TestParseSize(t *testing.T) {
+	t.Run("error_invalid", func(t *testing.T) {
+		for _, value := range []string{"", "-5GiB", "GiB", "5 parsecs", "99999999TiB"} {
+			t.Run(value, func(t *testing.T) {
+				// Act
+				_, err := engine.ParseSize(value)
+
+				// Assert
+				testutils.Error(testutils.Require(t), err)
+				testutils.Contains(t, err.Error(), "invalid size")
+			})
+		}
+	})
+
+	t.Run("success", func(t *testing.T) {
+		for value, expected := range map[string]engine.Size{
+			"1024":   1024,
+			"500MB":  500_000_000,
+			"1.5GB":  1_500_000_000,
+			"5GiB":   5 << 30,
+			"2 tib":  2 << 40,
+			"512KiB": 512 << 10,
+		} {
+			t.Run(value, func(t *testing.T) {
+				// Act
+				size, err := engine.ParseSize(value)
+
+				// Assert
+				testutils.NoError(testutils.Require(t), err)
+				testutils.Equal(t, expected, size)
+			})
+		}
+	})
+}
+
+func TestSizeString(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		for expected, size := range map[string]engine.Size{
+			"0":      0,
+			"1KB":    1000,
+			"1025B":  1025,
+			"5GiB":   5 << 30,
+			"500MB":  500_000_000,
+			"512KiB": 512 << 10,
+		} {
+			t.Run(expected, func(t *testing.T) {
+				// Act
+				value := size.String()
+
+				// Assert
+				testutils.Equal(t, expected, value)
+			})
+		}
+	})
+}
+
+func TestSizeHuman(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		for expected, size := range map[string]engine.Size{
+			"0B":     0,
+			"1023B":  1023,
+			"1.0KiB": 1024,
+			"1.5GiB": 3 << 29,
+			"2.0TiB": 2 << 40,
+		} {
+			t.Run(expected, func(t *testing.T) {
+				// Act
+				value := size.Human()
+
+				// Assert
+				testutils.Equal(t, expected, value)
+			})
+		}
+	})
+}
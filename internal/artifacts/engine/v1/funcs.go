@@ -54,10 +54,19 @@ func ReadProjects(ctx context.Context, client *gitlab.Client, runOptions engine.
 			for _, gitlab := range projects {
 				project := models.ProjectFromGitLab(gitlab)
 				// confirm that project is inside cleanup slice
+				if exclude := project.FirstMatch(runOptions.ExcludeRegexps()...); exclude != nil {
+					logger.Info("skipping project cleaning",
+						"project_id", project.ID,
+						"project_path", project.PathWithNamespace,
+						"reason", "excluded",
+						"exclude_path", exclude.String())
+					continue
+				}
 				if !project.Matches(runOptions.Regexps()...) {
 					logger.Info("skipping project cleaning",
 						"project_id", project.ID,
-						"project_path", project.PathWithNamespace)
+						"project_path", project.PathWithNamespace,
+						"reason", "no matching path")
 					continue
 				}
 				tasks <- ReadJobs(ctx, client, project, runOptions)
@@ -106,7 +115,7 @@ func ReadJobs(ctx context.Context, client *gitlab.Client, project models.Project
 			for _, gitlab := range jobs {
 				job := models.JobFromGitLab(project.ID, gitlab)
 				// check that the job needs to be cleaned up
-				if job.NeedCleanup(runOptions.ThresholdDuration) {
+				if job.NeedCleanup(runOptions.ThresholdDuration, runOptions.JobFilters.Filter()) {
 					funcs <- DeleteArtifacts(ctx, client, job, runOptions)
 				}
 			}
@@ -129,7 +138,7 @@ func DeleteArtifacts(ctx context.Context, client *gitlab.Client, job models.Job,
 			return
 		}
 
-		if err := job.DeleteArtifacts(ctx, client); err != nil {
+		if _, err := job.DeleteArtifacts(ctx, client); err != nil {
 			logger.Warn("failed to delete job's artifacts",
 				"error", err,
 				"job", job.ID,
@@ -23,7 +23,7 @@ func Run(parent context.Context, client *gitlab.Client, opts ...engine.RunOption
 
 	pooler, err := pooling.NewPoolerBuilder().
 		// initialize two pools of routines, one for projects and one for jobs
-		SetSizes(10, 1000).
+		SetSizes(uint(ro.ProjectConcurrency), uint(ro.ProjectConcurrency*ro.JobConcurrency)). //nolint:gosec // validated as positive by run options
 		SetOptions(ants.WithLogger(engine.GetLogger(ctx))).
 		Build()
 	if err != nil {
@@ -0,0 +1,97 @@
+package artifacts
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/fogfactory/pipe"
+	"github.com/panjf2000/ants/v2"
+	gitlab "gitlab.com/gitlab-org/api/client-go/v2"
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/artifacts/engine"
+	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/tracing"
+)
+
+// Cleaner represents the specific steps of a cleaning command other than artifacts one (e.g. registry tags cleaning),
+// all other steps (projects reading, logs, summary, etc.) being shared with RunCleaner.
+//
+// T is the type of cleaned items (e.g. models.Tag).
+type Cleaner[T any] struct {
+	// Name is the cleaning command name (e.g. 'registry'), naming its run span.
+	Name string
+
+	// CleanedKey is the key of the number of cleaned items in project execution logs (e.g. 'tags_cleaned').
+	CleanedKey string
+
+	// Read returns the function to send all items needing cleanup of a given Project into pipe processing.
+	Read func(ctx context.Context, client *gitlab.Client, runOptions engine.RunOptions) pipe.Split[Project, T]
+
+	// Delete returns the function to delete a specific item.
+	Delete func(ctx context.Context, client *gitlab.Client, runOptions engine.RunOptions) pipe.Process[T]
+
+	// Cleaned returns truthy if the item was deleted.
+	Cleaned func(item T) bool
+}
+
+// RunCleaner retrieves gitlab projects and filters the one not appropriate with options (paths regexps).
+//
+// For every appropriate project, it will read items with cleaner Read function and delete them with cleaner Delete function.
+// It returns the Summary of all processed projects (number of cleaned items)
+// along with all errors which occurred during the run (e.g. failed deletions), joined together.
+// Those errors don't stop the run, as such the Summary is still relevant when an error is returned.
+//
+// When input context is canceled, no new project nor deletion is started and in-flight deletions
+// are given run options grace period to finish. The partial Summary is then returned with an interruption error.
+func RunCleaner[T any](parent context.Context, client *gitlab.Client, cleaner Cleaner[T], opts ...engine.RunOption) (Summary, error) {
+	ro, err := engine.NewRunOptions(opts...)
+	if err != nil {
+		return Summary{}, fmt.Errorf("new run options: %w", err)
+	}
+	failures := &engine.Failures{}
+	ctx := context.WithValue(ro.Context(parent), engine.FailuresKey, failures)
+
+	ctx, span := tracing.Start(ctx, cleaner.Name+".run")
+	defer span.End()
+
+	pools, err := pipe.NewPoolsWithOptions(ro.PoolSizes(), ants.WithLogger(engine.GetLogger(ctx)))
+	if err != nil {
+		return Summary{}, fmt.Errorf("pools initialization: %w", err)
+	}
+	defer pools.Release()
+
+	totals := &summarizer{}
+	piping := NewPipeProjectBuilder[T]().
+		Concurrency(ro.JobConcurrency).
+		Processor(StartProject(ctx)).
+		Split(cleaner.Read(ctx, client, ro)).
+		Processor(cleaner.Delete(ctx, client, ro)).
+		Merge(CountCleaned(cleaner.Cleaned)).
+		Processor(StopProjectWith(ctx, func(p Project) []any { return []any{cleaner.CleanedKey, p.ItemsCleaned} })).
+		Processor(totals.Observe).
+		Build()
+
+	projects := ReadProjects(ctx, client, ro)
+	pipe.Run(pools, projects, piping)
+	failures.Add(engine.Interrupted(ctx))
+
+	summary := totals.summary
+	summary.Failures = failures.Len()
+	span.SetAttributes(
+		attribute.Int64("items_cleaned", int64(summary.ItemsCleaned)),
+		attribute.Int64("projects", int64(summary.Projects)))
+	tracing.SetError(span, failures.Err())
+	return summary, failures.Err()
+}
+
+// CountCleaned returns the function to merge all Project's items and count the cleaned ones into Project ItemsCleaned.
+func CountCleaned[T any](cleaned func(item T) bool) pipe.Merge[Project, T] {
+	return func(project Project, out <-chan T) Project {
+		for item := range out {
+			if cleaned(item) {
+				project.ItemsCleaned++
+			}
+		}
+		return project
+	}
+}
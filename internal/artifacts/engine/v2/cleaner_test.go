@@ -0,0 +1,116 @@
+package artifacts_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fogfactory/pipe"
+	"github.com/jarcoal/httpmock"
+	gitlab "gitlab.com/gitlab-org/api/client-go/v2"
+
+	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/artifacts/engine"
+	artifacts "github.com/kilianpaquier/gitlab-storage-cleaner/internal/artifacts/engine/v2"
+	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/testutils"
+)
+
+// item is a fake cleaned item, deleted when its identifier is even.
+type item struct {
+	Cleaned bool
+	ID      int
+}
+
+func TestRunCleaner(t *testing.T) {
+	ctx := t.Context()
+
+	httpmock.Activate()
+	t.Cleanup(httpmock.DeactivateAndReset)
+
+	// setup mock client
+	client, err := gitlab.NewClient("",
+		gitlab.WithHTTPClient(&http.Client{Transport: httpmock.DefaultTransport}),
+		gitlab.WithoutRetries(),
+	)
+	testutils.NoError(testutils.Require(t), err)
+
+	cleaner := artifacts.Cleaner[item]{
+		Name:       "test",
+		CleanedKey: "items_cleaned",
+		Read: func(context.Context, *gitlab.Client, engine.RunOptions) pipe.Split[artifacts.Project, item] {
+			return func(_ artifacts.Project, in chan<- item) {
+				for id := range 5 {
+					in <- item{ID: id}
+				}
+			}
+		},
+		Delete: func(ctx context.Context, _ *gitlab.Client, _ engine.RunOptions) pipe.Process[item] {
+			return func(item item) item {
+				if item.ID%2 != 0 {
+					engine.GetFailures(ctx).Add(errors.New("odd item"))
+					return item
+				}
+				item.Cleaned = true
+				return item
+			}
+		},
+		Cleaned: func(item item) bool { return item.Cleaned },
+	}
+
+	t.Run("success_e2e", func(t *testing.T) {
+		// Arrange
+		t.Cleanup(httpmock.Reset)
+
+		httpmock.RegisterResponder(http.MethodGet, projectsURL,
+			httpmock.NewJsonResponderOrPanic(http.StatusOK, []*gitlab.Project{
+				{ID: 7, PathWithNamespace: "project_path"},
+				{ID: 8, PathWithNamespace: "not_matching"},
+			}).Then(httpmock.NewJsonResponderOrPanic(http.StatusOK, []*gitlab.Project{})))
+
+		var buf strings.Builder
+		opts := []engine.RunOption{
+			engine.WithLogger(engine.NewTestLogger(&buf)),
+			engine.WithPaths("^project_path$"),
+			engine.WithThresholdDuration(time.Hour),
+		}
+
+		// Act
+		summary, err := artifacts.RunCleaner(ctx, client, cleaner, opts...)
+
+		// Assert
+		testutils.Error(testutils.Require(t), err)
+		testutils.Contains(t, err.Error(), "2 failure(s) during run")
+		testutils.Equal(t, 2, summary.Failures)
+		testutils.Equal(t, 3, summary.ItemsCleaned)
+		testutils.Equal(t, 1, summary.Projects)
+		testutils.Contains(t, buf.String(), "items_cleaned=3")
+	})
+
+	t.Run("error_run_options", func(t *testing.T) {
+		// Act
+		_, err := artifacts.RunCleaner(ctx, client, cleaner)
+
+		// Assert
+		testutils.Error(testutils.Require(t), err)
+		testutils.Contains(t, err.Error(), "new run options")
+	})
+}
+
+func TestCountCleaned(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		// Arrange
+		items := make(chan item, 3)
+		items <- item{Cleaned: true}
+		items <- item{}
+		items <- item{Cleaned: true}
+		close(items)
+
+		// Act
+		project := artifacts.CountCleaned(func(item item) bool { return item.Cleaned })(artifacts.Project{}, items)
+
+		// Assert
+		testutils.Equal(t, 2, project.ItemsCleaned)
+	})
+}
@@ -2,90 +2,331 @@ package artifacts
 
 import (
 	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/fogfactory/pipe"
 	"github.com/samber/lo"
 	gitlab "gitlab.com/gitlab-org/api/client-go/v2"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/artifacts/engine"
 	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/artifacts/models"
+	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/metrics"
+	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/tracing"
 )
 
 // ReadProjects reads all projects from gitlab api and send them into the output channel.
-// The output channel is closed once all projects were sent into it.
+// The output channel is closed once all projects were sent into it or once input context is canceled.
+//
+// When groups are provided in run options, only projects of those groups (and their subgroups) are read,
+// otherwise all projects the token is a member of (with at least run options minimum access level) are read.
+//
+// Projects completed in a previous run (according to context checkpoint) aren't sent.
+// Skipped projects are given with their skip reason to the context summarizer, if any, to be part of run report.
 func ReadProjects(ctx context.Context, client *gitlab.Client, runOptions engine.RunOptions) <-chan Project {
 	logger := engine.GetLogger(ctx)
+	checkpoint := engine.GetCheckpoint(ctx)
+	totals := getSummarizer(ctx)
 
 	// un-buffered channel to avoid too many pages in memory
 	tasks := make(chan Project)
 
+	// all projects of provided groups are cleaned when no paths are provided
+	matchAll := len(runOptions.Groups) > 0 && len(runOptions.Paths) == 0
+	filter := models.ProjectFilter{
+		IdleDuration: runOptions.IdleDuration,
+		Topics:       runOptions.Topics,
+		Visibilities: runOptions.Visibilities,
+	}
+
+	skip := func(project models.Project, reason string, args ...any) {
+		logger.Info("skipping project cleaning", append([]any{
+			"project_id", project.ID,
+			"project_path", project.PathWithNamespace,
+			"reason", reason,
+		}, args...)...)
+		totals.Skip(project, reason)
+	}
+
+	send := func(project models.Project) {
+		if exclude := project.FirstMatch(runOptions.ExcludeRegexps()...); exclude != nil {
+			skip(project, "excluded", "exclude_path", exclude.String())
+			return
+		}
+		if !matchAll && !project.Matches(runOptions.Regexps()...) {
+			skip(project, "no matching path")
+			return
+		}
+		// some filters may not have been applied server side (group projects, multiple visibilities, etc.)
+		if !project.MatchesFilter(filter) {
+			skip(project, "no matching metadata")
+			return
+		}
+		if checkpoint.Completed(project.ID) {
+			skip(project, "completed in a previous run")
+			return
+		}
+		select {
+		case tasks <- Project{Project: project}:
+		case <-ctx.Done():
+		}
+	}
+
+	go func() {
+		defer close(tasks)
+
+		if len(runOptions.Groups) == 0 {
+			readProjects(ctx, client, runOptions, send)
+			return
+		}
+
+		// groups may be nested or share projects, a project must only be sent once
+		seen := make(map[int64]struct{})
+		for _, group := range runOptions.Groups {
+			if ctx.Err() != nil {
+				return // run canceled
+			}
+			readGroupProjects(ctx, client, runOptions, group, func(project models.Project) {
+				if _, ok := seen[project.ID]; ok {
+					return
+				}
+				seen[project.ID] = struct{}{}
+				send(project)
+			})
+		}
+	}()
+
+	return tasks
+}
+
+// readProjects reads all projects the token is a member of (with at least run options minimum access level)
+// and calls send for each one of them.
+//
+// Projects metadata filters from run options are provided to gitlab api when possible.
+func readProjects(ctx context.Context, client *gitlab.Client, runOptions engine.RunOptions, send func(models.Project)) {
+	logger := engine.GetLogger(ctx)
+
 	opts := &gitlab.ListProjectsOptions{
 		ListOptions: gitlab.ListOptions{
 			Page:    1,
 			PerPage: 100,
 		},
 
-		Archived:             lo.ToPtr(false),
+		Archived:             archived(runOptions.ArchivedMode),
 		IncludePendingDelete: lo.ToPtr(false),
 		Membership:           lo.ToPtr(true),
-		// only maintainers can cleanup job artifacts
-		MinAccessLevel: lo.ToPtr(gitlab.MaintainerPermissions),
-		Simple:         lo.ToPtr(true),
+		MinAccessLevel:       lo.ToPtr(runOptions.MinAccessLevel),
+		Simple:               simple(runOptions),
+		Topic:                topic(runOptions.Topics),
+		Visibility:           visibility(runOptions.Visibilities),
+	}
+	if runOptions.IdleDuration > 0 {
+		opts.LastActivityBefore = lo.ToPtr(time.Now().Add(-runOptions.IdleDuration))
+	}
+	if runOptions.Statistics {
+		opts.Statistics = lo.ToPtr(true)
 	}
 
-	go func() {
-		defer close(tasks)
-		for {
-			// retrieve next page of projects
-			projects, _, err := client.Projects.ListProjects(opts, gitlab.WithContext(ctx))
-			if err != nil {
-				logger.Warn("failed to retrieve projects", "error", err)
-				break
-			}
-			opts.Page++
+	for {
+		// retrieve next page of projects
+		projects, err := Fetch(ctx, "list_projects", func(ctx context.Context) ([]*gitlab.Project, *gitlab.Response, error) {
+			return client.Projects.ListProjects(opts, gitlab.WithContext(ctx))
+		}, attribute.Int64("page", opts.Page))
+		if ctx.Err() != nil {
+			return // run canceled
+		}
+		if err != nil {
+			logger.Warn("failed to retrieve projects", "error", err)
+			engine.GetFailures(ctx).Add(fmt.Errorf("list projects: %w", err))
+			return
+		}
+		opts.Page++
 
-			// stop infinite loop
-			if len(projects) == 0 {
-				break
-			}
+		// stop infinite loop
+		if len(projects) == 0 {
+			return
+		}
 
-			// send all projects for cleanup and iterate to next page
-			for _, gitlab := range projects {
-				project := models.ProjectFromGitLab(gitlab)
+		// send all projects for cleanup and iterate to next page
+		metrics.ProjectsScanned.Add(float64(len(projects)))
+		for _, gitlab := range projects {
+			send(models.ProjectFromGitLab(gitlab))
+		}
+	}
+}
 
-				if !project.Matches(runOptions.Regexps()...) {
-					logger.Info("skipping project cleaning",
-						"project_id", project.ID,
-						"project_path", project.PathWithNamespace)
-					continue
-				}
-				tasks <- Project{Project: project}
-			}
+// readGroupProjects reads all projects of input group (ID or path) and its subgroups
+// the token is a member of (with at least run options minimum access level) and calls send for each one of them.
+//
+// Projects metadata filters from run options are provided to gitlab api when possible.
+func readGroupProjects(ctx context.Context, client *gitlab.Client, runOptions engine.RunOptions, group string, send func(models.Project)) {
+	logger := engine.GetLogger(ctx)
+
+	opts := &gitlab.ListGroupProjectsOptions{
+		ListOptions: gitlab.ListOptions{
+			Page:    1,
+			PerPage: 100,
+		},
+
+		Archived:         archived(runOptions.ArchivedMode),
+		IncludeSubGroups: lo.ToPtr(true),
+		MinAccessLevel:   lo.ToPtr(runOptions.MinAccessLevel),
+		Simple:           simple(runOptions),
+		Topic:            topic(runOptions.Topics),
+		Visibility:       visibility(runOptions.Visibilities),
+	}
+
+	for {
+		// retrieve next page of group projects
+		projects, err := Fetch(ctx, "list_group_projects", func(ctx context.Context) ([]*gitlab.Project, *gitlab.Response, error) {
+			return client.Groups.ListGroupProjects(group, opts, gitlab.WithContext(ctx))
+		}, attribute.String("group", group), attribute.Int64("page", opts.Page))
+		if ctx.Err() != nil {
+			return // run canceled
 		}
-	}()
+		if err != nil {
+			logger.Warn("failed to retrieve group projects", "error", err, "group", group)
+			engine.GetFailures(ctx).Add(fmt.Errorf("list group '%s' projects: %w", group, err))
+			return
+		}
+		opts.Page++
 
-	return tasks
+		// stop infinite loop
+		if len(projects) == 0 {
+			return
+		}
+
+		// send all projects for cleanup and iterate to next page
+		metrics.ProjectsScanned.Add(float64(len(projects)))
+		for _, gitlab := range projects {
+			send(models.ProjectFromGitLab(gitlab))
+		}
+	}
+}
+
+// archived returns the gitlab api archived filter associated to input archived mode.
+func archived(mode engine.ArchivedMode) *bool {
+	switch mode {
+	case engine.ArchivedInclude:
+		return nil
+	case engine.ArchivedOnly:
+		return lo.ToPtr(true)
+	default:
+		return lo.ToPtr(false)
+	}
+}
+
+// simple returns truthy if gitlab api simple view of projects can be used,
+// it doesn't include projects visibility, archived status and statistics.
+func simple(runOptions engine.RunOptions) *bool {
+	return lo.ToPtr(len(runOptions.Visibilities) == 0 && runOptions.ArchivedMode == engine.ArchivedExclude && !runOptions.Statistics)
+}
+
+// topic returns the gitlab api topic filter associated to input topics (all of them are required).
+func topic(topics []string) *string {
+	if len(topics) == 0 {
+		return nil
+	}
+	return lo.ToPtr(strings.Join(topics, ","))
+}
+
+// visibility returns the gitlab api visibility filter associated to input visibilities,
+// only one visibility can be filtered by gitlab api.
+func visibility(visibilities []string) *gitlab.VisibilityValue {
+	if len(visibilities) != 1 {
+		return nil
+	}
+	return lo.ToPtr(gitlab.VisibilityValue(visibilities[0]))
 }
 
 // ReadJobs returns the function to send all Jobs of a given Project into pipe processing.
+//
+// Jobs ran on protected branches and tags are only sent when a protected threshold duration is provided.
+// In case those protected branches and tags can't be retrieved, no job is sent at all.
+//
+// No more job is sent once input context is canceled.
+//
+// The last listed page of jobs is recorded in context checkpoint, as such a resumed run doesn't list again all jobs of a project.
+// Jobs are still listed from the first page when all of them must be read (keep latest, keep count or storage budget).
 func ReadJobs(ctx context.Context, client *gitlab.Client, runOptions engine.RunOptions) pipe.Split[Project, models.Job] {
 	logger := engine.GetLogger(ctx)
+	checkpoint := engine.GetCheckpoint(ctx)
 	return func(project Project, in chan<- models.Job) {
+		// gitlab api calls spans are children of project span
+		ctx := trace.ContextWithSpan(ctx, project.span)
+
 		opts := &gitlab.ListJobsOptions{
 			ListOptions: gitlab.ListOptions{
 				Page:    1,
 				PerPage: 100,
 			},
-			Scope: &[]gitlab.BuildStateValue{"failed", "success"},
+			Scope: lo.ToPtr(lo.Map(runOptions.JobFilters.Statuses, func(status string, _ int) gitlab.BuildStateValue {
+				return gitlab.BuildStateValue(status)
+			})),
+		}
+
+		policy := runOptions.Policy(project.PathWithNamespace)
+		if policy.Name != "" {
+			logger.Debug("applying retention policy",
+				"policy", policy.Name,
+				"project_id", project.ID,
+				"project_path", project.PathWithNamespace)
+		}
+
+		// protected refs are read once since most jobs are ran on the same few refs
+		protected, err := ReadProtectedRefs(ctx, client, project.ID)
+		if ctx.Err() != nil {
+			return // run canceled
+		}
+		if err != nil {
+			logger.Warn("failed to retrieve project protected branches and tags",
+				"error", err,
+				"project_id", project.ID,
+				"project_path", project.PathWithNamespace)
+			engine.GetFailures(ctx).Add(fmt.Errorf("project '%s': read protected branches and tags: %w", project.PathWithNamespace, err))
+			checkpoint.Fail(project.ID)
+			return
+		}
+
+		threshold := runOptions.ProjectThreshold(project.Project)
+
+		// jobs are listed from the most recent to the oldest one
+		latest := newLatestJobs(runOptions.KeepLatestJobs)
+		var kept int
+
+		// with a storage budget, jobs to clean are only sent once all of them are read
+		budget := newStorageBudget(runOptions.MaxArtifactsSize)
+		if budget != nil {
+			// project artifacts size accounts for all jobs, statuses are filtered afterwards
+			opts.Scope = nil
+		}
+
+		if runOptions.Resume && runOptions.KeepLatestJobs == engine.KeepLatestNone && policy.KeepCount == 0 && budget == nil {
+			opts.Page = resumePage(checkpoint.JobsPage(project.ID), int64(runOptions.JobConcurrency), opts.PerPage)
 		}
 
 		for {
-			jobs, _, err := client.Jobs.ListProjectJobs(project.ID, opts)
+			jobs, err := Fetch(ctx, "list_project_jobs", func(ctx context.Context) ([]*gitlab.Job, *gitlab.Response, error) {
+				return client.Jobs.ListProjectJobs(project.ID, opts, gitlab.WithContext(ctx))
+			}, attribute.Int64("project.id", project.ID), attribute.Int64("page", opts.Page))
+			if ctx.Err() != nil {
+				return // run canceled
+			}
 			if err != nil {
 				logger.Warn("failed to retrieve project jobs",
 					"error", err,
 					"project_id", project.ID,
 					"project_path", project.PathWithNamespace)
+				engine.GetFailures(ctx).Add(fmt.Errorf("project '%s': list jobs: %w", project.PathWithNamespace, err))
+				checkpoint.Fail(project.ID)
+				if budget != nil {
+					// project artifacts size is unknown, as such no job can be cleaned
+					return
+				}
 				break
 			}
 
@@ -97,48 +338,274 @@ func ReadJobs(ctx context.Context, client *gitlab.Client, runOptions engine.RunO
 
 			for _, gitlab := range jobs {
 				job := models.JobFromGitLab(project.ID, gitlab)
+				budget.Add(job)
+				if budget != nil && !slices.Contains(runOptions.JobFilters.Statuses, job.Status) {
+					continue
+				}
+				if !policy.MatchesJob(job.Name) {
+					continue
+				}
+				if latest.Keep(job) {
+					logger.Debug("keeping latest successful job's artifacts",
+						"job_id", job.ID,
+						"pipeline_id", job.PipelineID,
+						"project_id", project.ID,
+						"ref", job.Ref)
+					continue
+				}
+
+				// protected refs threshold takes precedence over all other ones
+				jobThreshold := threshold
+				if job.Protected = protected.Matches(job); job.Protected {
+					if runOptions.ProtectedThresholdDuration == 0 {
+						logger.Debug("keeping protected branch or tag job's artifacts",
+							"job_id", job.ID,
+							"project_id", project.ID,
+							"ref", job.Ref)
+						continue
+					}
+					jobThreshold = runOptions.ProtectedThresholdDuration
+				}
+
 				// check that the job needs cleanup before sending it
-				if job.NeedCleanup(runOptions.ThresholdDuration) {
-					in <- job
+				if !job.NeedCleanup(jobThreshold, runOptions.JobFilters.Filter()) {
+					continue
+				}
+				if !job.MatchesArtifactTypes(runOptions.JobFilters.ArtifactTypes...) {
+					logger.Debug("keeping job's artifacts with types not allowed for deletion",
+						"artifact_types", strings.Join(job.ArtifactTypes(), ","),
+						"job_id", job.ID,
+						"project_id", project.ID)
+					continue
 				}
+
+				// only jobs which would be cleaned count toward the policy keep count
+				if kept < policy.KeepCount {
+					kept++
+					logger.Debug("keeping most recent job's artifacts",
+						"job_id", job.ID,
+						"project_id", project.ID)
+					continue
+				}
+				if budget != nil {
+					budget.Candidate(job)
+					continue
+				}
+				metrics.JobsMatched.Inc()
+				select {
+				case in <- job:
+				case <-ctx.Done():
+					return // run canceled
+				}
+			}
+			checkpoint.SetJobsPage(project.ID, opts.Page-1)
+		}
+
+		if budget == nil {
+			return
+		}
+		jobs := budget.Exceeding()
+		if len(jobs) > 0 {
+			logger.Info("project artifacts exceed storage budget",
+				"artifacts_size", engine.Size(budget.size),
+				"jobs", len(jobs),
+				"max_artifacts_size", runOptions.MaxArtifactsSize,
+				"project_id", project.ID,
+				"project_path", project.PathWithNamespace)
+		}
+		for _, job := range jobs {
+			metrics.JobsMatched.Inc()
+			select {
+			case in <- job:
+			case <-ctx.Done():
+				return // run canceled
 			}
 		}
 	}
 }
 
+// resumePage returns the jobs page to resume listing from, given the last listed page of a previous run.
+//
+// Jobs of the last listed pages may still have been in flight (up to job concurrency per project)
+// when the previous run stopped, as such those pages are listed again.
+func resumePage(listed, concurrency, perPage int64) int64 {
+	if listed == 0 {
+		return 1
+	}
+	inflight := (concurrency + perPage - 1) / perPage
+	return max(listed-inflight, 1)
+}
+
+// ReadProtectedRefs returns all protected branches and tags of a given project.
+func ReadProtectedRefs(ctx context.Context, client *gitlab.Client, projectID int64) (models.ProtectedRefs, error) {
+	branches, err := ReadProtectedBranches(ctx, client, projectID)
+	if err != nil {
+		return models.ProtectedRefs{}, err
+	}
+
+	tags, err := readProtectedTags(ctx, client, projectID)
+	if err != nil {
+		return models.ProtectedRefs{}, err
+	}
+	return models.ProtectedRefs{Branches: branches, Tags: tags}, nil
+}
+
+// ReadProtectedBranches returns all protected branches of a given project.
+func ReadProtectedBranches(ctx context.Context, client *gitlab.Client, projectID int64) (models.ProtectedBranches, error) {
+	opts := &gitlab.ListProtectedBranchesOptions{
+		ListOptions: gitlab.ListOptions{
+			Page:    1,
+			PerPage: 100,
+		},
+	}
+
+	var protected []*gitlab.ProtectedBranch
+	for {
+		branches, err := Fetch(ctx, "list_protected_branches", func(ctx context.Context) ([]*gitlab.ProtectedBranch, *gitlab.Response, error) {
+			return client.ProtectedBranches.ListProtectedBranches(projectID, opts, gitlab.WithContext(ctx))
+		}, attribute.Int64("project.id", projectID), attribute.Int64("page", opts.Page))
+		if err != nil {
+			return nil, err
+		}
+
+		// stop infinite loop
+		if len(branches) == 0 {
+			break
+		}
+		opts.Page++
+
+		protected = append(protected, branches...)
+	}
+	return models.ProtectedBranchesFromGitLab(protected...), nil
+}
+
+// readProtectedTags returns all protected tags of a given project.
+func readProtectedTags(ctx context.Context, client *gitlab.Client, projectID int64) (models.ProtectedTags, error) {
+	opts := &gitlab.ListProtectedTagsOptions{
+		ListOptions: gitlab.ListOptions{
+			Page:    1,
+			PerPage: 100,
+		},
+	}
+
+	var protected []*gitlab.ProtectedTag
+	for {
+		tags, err := Fetch(ctx, "list_protected_tags", func(ctx context.Context) ([]*gitlab.ProtectedTag, *gitlab.Response, error) {
+			return client.ProtectedTags.ListProtectedTags(projectID, opts, gitlab.WithContext(ctx))
+		}, attribute.Int64("project.id", projectID), attribute.Int64("page", opts.Page))
+		if err != nil {
+			return nil, err
+		}
+
+		// stop infinite loop
+		if len(tags) == 0 {
+			break
+		}
+		opts.Page++
+
+		protected = append(protected, tags...)
+	}
+	return models.ProtectedTagsFromGitLab(protected...), nil
+}
+
+// Fetch calls input gitlab api list call (identified by operation, e.g. 'list_project_jobs')
+// and returns the read page, the call being retried according to context retry policy (see Call).
+func Fetch[T any](ctx context.Context, operation string, list func(ctx context.Context) ([]T, *gitlab.Response, error), attributes ...attribute.KeyValue) ([]T, error) {
+	var page []T
+	err := Call(ctx, operation, func(ctx context.Context) (response *gitlab.Response, err error) {
+		page, response, err = list(ctx)
+		return response, err
+	}, attributes...)
+	return page, err
+}
+
+// Call calls input gitlab api call (identified by operation, e.g. 'delete_job_artifacts'),
+// the call being retried according to context retry policy.
+//
+// Each attempt is observed with metrics and traced with input attributes as a child of context span,
+// fn being given the attempt span context to propagate it to gitlab.
+func Call(ctx context.Context, operation string, fn func(ctx context.Context) (*gitlab.Response, error), attributes ...attribute.KeyValue) error {
+	return engine.Retry(ctx, operation, func() error {
+		ctx, span := tracing.Start(ctx, "gitlab."+operation, attributes...)
+		defer span.End()
+
+		start := time.Now()
+		response, err := fn(ctx)
+		metrics.ObserveRequest(operation, start, response, err)
+		tracing.SetError(span, err)
+		return err
+	})
+}
+
 // DeleteArtifacts returns the function to delete a specific job artifacts.
+//
+// Once input context is canceled, deletions not yet started are skipped
+// while in-flight ones are given run options grace period to finish.
 func DeleteArtifacts(ctx context.Context, client *gitlab.Client, opts engine.RunOptions) pipe.Process[models.Job] {
 	return func(job models.Job) models.Job {
 		logger := engine.GetLogger(ctx)
 
+		if ctx.Err() != nil {
+			logger.Debug("run interrupted, skipping job's artifacts deletion",
+				"job_id", job.ID,
+				"project_id", job.ProjectID)
+			job.Interrupted = true
+			return job
+		}
+
 		if opts.DryRun {
 			logger.Info("running in dry run mode, skipping job's artifacts deletion",
+				"artifact_types", strings.Join(job.ArtifactTypes(), ","),
 				"job_id", job.ID,
 				"project_id", job.ProjectID)
 			return job
 		}
 
-		if err := job.DeleteArtifacts(ctx, client); err != nil {
+		graceCtx, cancel := engine.GraceContext(ctx, opts.GracePeriod)
+		defer cancel()
+
+		err := Call(graceCtx, "delete_job_artifacts", func(ctx context.Context) (*gitlab.Response, error) {
+			return job.DeleteArtifacts(ctx, client)
+		}, attribute.Int64("project.id", job.ProjectID), attribute.Int64("job.id", job.ID))
+		if err != nil {
 			logger.Warn("failed to delete job's artifacts",
 				"error", err,
 				"job_id", job.ID,
 				"project_id", job.ProjectID)
+			engine.GetFailures(ctx).Add(fmt.Errorf("project %d: delete job %d artifacts: %w", job.ProjectID, job.ID, err))
+			job.Error = err
 			return job
 		}
 
+		metrics.ArtifactsDeleted.Inc()
+		metrics.ArtifactsDeletedBytes.Add(float64(job.ArtifactsSize()))
 		job.Cleaned = true
 		return job
 	}
 }
 
 // ObserveCleanup merges all Project's jobs and returns the project.
-func ObserveCleanup(project Project, out <-chan models.Job) Project {
-	for job := range out {
-		if job.Cleaned {
-			project.JobsCleaned++
+//
+// Jobs cleanup outcome is only kept in the project when a report is enabled in run options.
+func ObserveCleanup(runOptions engine.RunOptions) pipe.Merge[Project, models.Job] {
+	return func(project Project, out <-chan models.Job) Project {
+		for job := range out {
+			if runOptions.Report {
+				project.Jobs = append(project.Jobs, JobReportFrom(job, runOptions.DryRun))
+			}
+			if job.Error != nil {
+				project.JobsFailed++
+			}
+			if job.Cleaned {
+				project.BytesCleaned += job.ArtifactsSize()
+				project.JobsCleaned++
+				for _, fileType := range job.ArtifactTypes() {
+					if !slices.Contains(project.ArtifactTypes, fileType) {
+						project.ArtifactTypes = append(project.ArtifactTypes, fileType)
+					}
+				}
+			}
 		}
+		return project
 	}
-	return project
 }
-
-var _ pipe.Merge[Project, models.Job] = ObserveCleanup // ensure interface is implemented
@@ -4,17 +4,23 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"net/url"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/jarcoal/httpmock"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
 	"github.com/samber/lo"
 	gitlab "gitlab.com/gitlab-org/api/client-go/v2"
 
 	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/artifacts/engine"
 	artifacts "github.com/kilianpaquier/gitlab-storage-cleaner/internal/artifacts/engine/v2"
 	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/artifacts/models"
+	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/metrics"
 	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/testutils"
 )
 
@@ -53,6 +59,53 @@ func TestReadProjects(t *testing.T) {
 		testutils.Contains(t, logs, "failed to retrieve projects")
 	})
 
+	t.Run("success_canceled", func(t *testing.T) {
+		// Arrange
+		t.Cleanup(httpmock.Reset)
+		httpmock.RegisterResponder(http.MethodGet, projectsURL,
+			httpmock.NewJsonResponderOrPanic(http.StatusOK, []*gitlab.Project{{ID: 7, PathWithNamespace: "hey_one"}}))
+
+		var buf strings.Builder
+		ctx, cancel := context.WithCancel(context.WithValue(ctx, engine.LoggerKey, engine.NewTestLogger(&buf)))
+		cancel()
+
+		// Act
+		projects := artifacts.ReadProjects(ctx, client, runOptions)
+
+		// Assert
+		// verify channel first because it will block until its closed
+		testutils.Equal(t, 0, len(lo.ChannelToSlice(projects)))
+		testutils.NotContains(t, buf.String(), "failed to retrieve projects")
+	})
+
+	t.Run("success_skip_completed", func(t *testing.T) {
+		// Arrange
+		t.Cleanup(httpmock.Reset)
+		httpmock.RegisterResponder(http.MethodGet, projectsURL,
+			httpmock.NewJsonResponderOrPanic(http.StatusOK, []*gitlab.Project{
+				{ID: 7, PathWithNamespace: "hey_one"},
+				{ID: 8, PathWithNamespace: "hey_two"},
+			}).Then(httpmock.NewJsonResponderOrPanic(http.StatusOK, []*gitlab.Project{})))
+
+		ro, err := engine.NewRunOptions(engine.WithPaths("^hey_.*$"), engine.WithStateFile(filepath.Join(t.TempDir(), "state.json")), engine.WithThresholdDuration(time.Hour))
+		testutils.NoError(testutils.Require(t), err)
+		checkpoint, err := ro.OpenCheckpoint()
+		testutils.NoError(testutils.Require(t), err)
+		checkpoint.Complete(7)
+
+		var buf strings.Builder
+		ctx := context.WithValue(ctx, engine.LoggerKey, engine.NewTestLogger(&buf))
+		ctx = context.WithValue(ctx, engine.CheckpointKey, checkpoint)
+
+		// Act
+		projects := artifacts.ReadProjects(ctx, client, ro)
+
+		// Assert
+		// verify channel first because it will block until its closed
+		testutils.Equal(t, 1, len(lo.ChannelToSlice(projects)))
+		testutils.Contains(t, buf.String(), "skipping project cleaning project_id=7 project_path=hey_one reason=completed in a previous run")
+	})
+
 	t.Run("success_populate_channel", func(t *testing.T) {
 		// Arrange
 		t.Cleanup(httpmock.Reset)
@@ -73,7 +126,191 @@ func TestReadProjects(t *testing.T) {
 		// Assert
 		// verify channel first because it will block until its closed
 		testutils.Equal(t, 3, len(lo.ChannelToSlice(projects)))
-		testutils.Contains(t, buf.String(), "skipping project cleaning project_id=9 project_path=two_hey")
+		testutils.Contains(t, buf.String(), "skipping project cleaning project_id=9 project_path=two_hey reason=no matching path")
+	})
+
+	t.Run("success_exclude_paths", func(t *testing.T) {
+		// Arrange
+		runOptions, err := engine.NewRunOptions(
+			engine.WithExcludePaths("^hey_.*_prod$"),
+			engine.WithPaths("^hey_.*$"),
+			engine.WithThresholdDuration(168*time.Hour))
+		testutils.NoError(testutils.Require(t), err)
+
+		t.Cleanup(httpmock.Reset)
+		httpmock.RegisterResponder(http.MethodGet, projectsURL,
+			httpmock.NewJsonResponderOrPanic(http.StatusOK, []*gitlab.Project{
+				{ID: 7, PathWithNamespace: "hey_one"},
+				{ID: 8, PathWithNamespace: "hey_one_prod"},
+			}).Then(httpmock.NewJsonResponderOrPanic(http.StatusOK, []*gitlab.Project{})))
+
+		var buf strings.Builder
+		ctx := context.WithValue(ctx, engine.LoggerKey, engine.NewTestLogger(&buf))
+
+		// Act
+		projects := artifacts.ReadProjects(ctx, client, runOptions)
+
+		// Assert
+		// verify channel first because it will block until its closed
+		result := lo.ChannelToSlice(projects)
+		testutils.Equal(testutils.Require(t), 1, len(result))
+		testutils.Equal(t, int64(7), result[0].ID)
+		testutils.Contains(t, buf.String(), "skipping project cleaning project_id=8 project_path=hey_one_prod reason=excluded exclude_path=^hey_.*_prod$")
+	})
+
+	t.Run("success_metadata_filters", func(t *testing.T) {
+		// Arrange
+		runOptions, err := engine.NewRunOptions(
+			engine.WithIdleDuration(24*time.Hour),
+			engine.WithPaths(".*"),
+			engine.WithThresholdDuration(168*time.Hour),
+			engine.WithTopics("go", "cli"),
+			engine.WithVisibilities("private"))
+		testutils.NoError(testutils.Require(t), err)
+
+		idle := lo.ToPtr(time.Now().Add(-48 * time.Hour))
+		var query url.Values
+
+		t.Cleanup(httpmock.Reset)
+		httpmock.RegisterResponder(http.MethodGet, projectsURL, func(req *http.Request) (*http.Response, error) {
+			if req.URL.Query().Get("page") != "1" {
+				return httpmock.NewJsonResponse(http.StatusOK, []*gitlab.Project{})
+			}
+			query = req.URL.Query()
+			return httpmock.NewJsonResponse(http.StatusOK, []*gitlab.Project{
+				{ID: 7, PathWithNamespace: "one", LastActivityAt: idle, Topics: []string{"cli", "go"}, Visibility: gitlab.PrivateVisibility},
+				{ID: 8, PathWithNamespace: "two", LastActivityAt: idle, Topics: []string{"go"}, Visibility: gitlab.PrivateVisibility},
+			})
+		})
+
+		var buf strings.Builder
+		ctx := context.WithValue(ctx, engine.LoggerKey, engine.NewTestLogger(&buf))
+
+		// Act
+		projects := artifacts.ReadProjects(ctx, client, runOptions)
+
+		// Assert
+		// verify channel first because it will block until its closed
+		result := lo.ChannelToSlice(projects)
+		testutils.Equal(testutils.Require(t), 1, len(result))
+		testutils.Equal(t, int64(7), result[0].ID)
+		testutils.Contains(t, buf.String(), "skipping project cleaning project_id=8 project_path=two reason=no matching metadata")
+
+		testutils.Equal(t, "go,cli", query.Get("topic"))
+		testutils.Equal(t, "private", query.Get("visibility"))
+		testutils.Equal(t, "false", query.Get("simple"))
+		testutils.True(t, query.Get("last_activity_before") != "")
+	})
+
+	t.Run("success_archived_modes", func(t *testing.T) {
+		for mode, expected := range map[engine.ArchivedMode]string{
+			engine.ArchivedExclude: "false",
+			engine.ArchivedInclude: "",
+			engine.ArchivedOnly:    "true",
+		} {
+			t.Run(string(mode), func(t *testing.T) {
+				// Arrange
+				runOptions, err := engine.NewRunOptions(engine.WithArchivedMode(mode), engine.WithPaths(".*"), engine.WithThresholdDuration(168*time.Hour))
+				testutils.NoError(testutils.Require(t), err)
+
+				var query url.Values
+
+				t.Cleanup(httpmock.Reset)
+				httpmock.RegisterResponder(http.MethodGet, projectsURL, func(req *http.Request) (*http.Response, error) {
+					if req.URL.Query().Get("page") != "1" {
+						return httpmock.NewJsonResponse(http.StatusOK, []*gitlab.Project{})
+					}
+					query = req.URL.Query()
+					return httpmock.NewJsonResponse(http.StatusOK, []*gitlab.Project{{ID: 7, Archived: true}})
+				})
+
+				// Act
+				projects := artifacts.ReadProjects(ctx, client, runOptions)
+
+				// Assert
+				// verify channel first because it will block until its closed
+				result := lo.ChannelToSlice(projects)
+				testutils.Equal(testutils.Require(t), 1, len(result))
+				testutils.True(t, result[0].Archived)
+				testutils.Equal(t, expected, query.Get("archived"))
+				testutils.Equal(t, strconv.FormatBool(mode == engine.ArchivedExclude), query.Get("simple"))
+			})
+		}
+	})
+
+	t.Run("error_list_group_projects", func(t *testing.T) {
+		// Arrange
+		runOptions, err := engine.NewRunOptions(engine.WithGroups("1"), engine.WithThresholdDuration(168*time.Hour))
+		testutils.NoError(testutils.Require(t), err)
+
+		t.Cleanup(httpmock.Reset)
+		httpmock.RegisterResponder(http.MethodGet, fmt.Sprintf(groupProjectsURL, "1"),
+			httpmock.NewStringResponder(http.StatusInternalServerError, "an error"))
+
+		var buf strings.Builder
+		ctx := context.WithValue(ctx, engine.LoggerKey, engine.NewTestLogger(&buf))
+
+		// Act
+		projects := artifacts.ReadProjects(ctx, client, runOptions)
+
+		// Assert
+		// verify channel first because it will block until its closed
+		testutils.Equal(t, 0, len(lo.ChannelToSlice(projects)))
+		logs := buf.String()
+		testutils.Contains(t, logs, "an error")
+		testutils.Contains(t, logs, "failed to retrieve group projects")
+		testutils.Contains(t, logs, "group=1")
+	})
+
+	t.Run("success_groups", func(t *testing.T) {
+		// Arrange
+		runOptions, err := engine.NewRunOptions(engine.WithGroups("1", "2"), engine.WithThresholdDuration(168*time.Hour))
+		testutils.NoError(testutils.Require(t), err)
+
+		t.Cleanup(httpmock.Reset)
+		httpmock.RegisterResponder(http.MethodGet, fmt.Sprintf(groupProjectsURL, "1"),
+			httpmock.NewJsonResponderOrPanic(http.StatusOK, []*gitlab.Project{
+				{ID: 7, PathWithNamespace: "group/one"},
+				{ID: 8, PathWithNamespace: "group/shared"},
+			}).Then(httpmock.NewJsonResponderOrPanic(http.StatusOK, []*gitlab.Project{})))
+		httpmock.RegisterResponder(http.MethodGet, fmt.Sprintf(groupProjectsURL, "2"),
+			httpmock.NewJsonResponderOrPanic(http.StatusOK, []*gitlab.Project{
+				{ID: 8, PathWithNamespace: "group/shared"},
+				{ID: 9, PathWithNamespace: "other/two"},
+			}).Then(httpmock.NewJsonResponderOrPanic(http.StatusOK, []*gitlab.Project{})))
+
+		// Act
+		projects := artifacts.ReadProjects(ctx, client, runOptions)
+
+		// Assert
+		// verify channel first because it will block until its closed
+		ids := lo.Map(lo.ChannelToSlice(projects), func(project artifacts.Project, _ int) int64 { return project.ID })
+		testutils.Equal(testutils.Require(t), 3, len(ids))
+		for i, id := range []int64{7, 8, 9} {
+			testutils.Equal(t, id, ids[i])
+		}
+	})
+
+	t.Run("success_groups_with_paths", func(t *testing.T) {
+		// Arrange
+		runOptions, err := engine.NewRunOptions(engine.WithGroups("1"), engine.WithPaths("^group/"), engine.WithThresholdDuration(168*time.Hour))
+		testutils.NoError(testutils.Require(t), err)
+
+		t.Cleanup(httpmock.Reset)
+		httpmock.RegisterResponder(http.MethodGet, fmt.Sprintf(groupProjectsURL, "1"),
+			httpmock.NewJsonResponderOrPanic(http.StatusOK, []*gitlab.Project{
+				{ID: 7, PathWithNamespace: "group/one"},
+				{ID: 9, PathWithNamespace: "other/two"},
+			}).Then(httpmock.NewJsonResponderOrPanic(http.StatusOK, []*gitlab.Project{})))
+
+		// Act
+		projects := artifacts.ReadProjects(ctx, client, runOptions)
+
+		// Assert
+		// verify channel first because it will block until its closed
+		result := lo.ChannelToSlice(projects)
+		testutils.Equal(testutils.Require(t), 1, len(result))
+		testutils.Equal(t, int64(7), result[0].ID)
 	})
 }
 
@@ -94,6 +331,7 @@ func TestReadJobs(t *testing.T) {
 	t.Run("error_list_jobs", func(t *testing.T) {
 		// Arrange
 		t.Cleanup(httpmock.Reset)
+		registerProtectedRefs(project.ID, nil, nil)
 		httpmock.RegisterResponder(http.MethodGet, fmt.Sprintf(jobsURL, project.ID),
 			httpmock.NewStringResponder(http.StatusInternalServerError, "an error"))
 
@@ -109,12 +347,32 @@ func TestReadJobs(t *testing.T) {
 		testutils.Contains(t, logs, "failed to retrieve project jobs")
 	})
 
+	t.Run("error_list_protected_refs", func(t *testing.T) {
+		// Arrange
+		t.Cleanup(httpmock.Reset)
+		httpmock.RegisterResponder(http.MethodGet, fmt.Sprintf(protectedBranchesURL, project.ID),
+			httpmock.NewStringResponder(http.StatusInternalServerError, "an error"))
+
+		var buf strings.Builder
+		ctx := context.WithValue(ctx, engine.LoggerKey, engine.NewTestLogger(&buf))
+
+		// Act
+		artifacts.ReadJobs(ctx, client, engine.RunOptions{})(project, nil)
+
+		// Assert
+		logs := buf.String()
+		testutils.Contains(t, logs, "an error")
+		testutils.Contains(t, logs, "failed to retrieve project protected branches and tags")
+		testutils.Equal(t, 1, httpmock.GetTotalCallCount()) // no job listing when protected refs are unknown
+	})
+
 	t.Run("success_populate_channel", func(t *testing.T) {
 		// Arrange
 		now := time.Now()
 		start := now.Add(-2 * time.Hour) // jobs are old and artifacts not cleaned yet
 
 		t.Cleanup(httpmock.Reset)
+		registerProtectedRefs(project.ID, nil, nil)
 		httpmock.RegisterResponder(http.MethodGet, fmt.Sprintf(jobsURL, project.ID),
 			httpmock.NewJsonResponderOrPanic(http.StatusOK, []*gitlab.Job{
 				{
@@ -141,8 +399,385 @@ func TestReadJobs(t *testing.T) {
 
 		// Assert
 		testutils.Equal(t, 2, len(jobs)) // two elements, one for each job
-		testutils.Equal(t, 2, httpmock.GetTotalCallCount())
+		testutils.Equal(t, 4, httpmock.GetTotalCallCount())
+	})
+
+	t.Run("success_retry_failed_page", func(t *testing.T) {
+		// Arrange
+		now := time.Now()
+		start := now.Add(-2 * time.Hour) // jobs are old and artifacts not cleaned yet
+
+		t.Cleanup(httpmock.Reset)
+		registerProtectedRefs(project.ID, nil, nil)
+		httpmock.RegisterResponder(http.MethodGet, fmt.Sprintf(jobsURL, project.ID),
+			httpmock.NewJsonResponderOrPanic(http.StatusOK, []*gitlab.Job{
+				{ID: 7, ArtifactsExpireAt: lo.ToPtr(now.Add(time.Hour)), CreatedAt: &start, Artifacts: []gitlab.JobArtifact{{}}},
+			}).
+				Then(httpmock.NewStringResponder(http.StatusServiceUnavailable, "an error")).
+				Then(httpmock.NewJsonResponderOrPanic(http.StatusOK, []*gitlab.Job{
+					{ID: 8, ArtifactsExpireAt: lo.ToPtr(now.Add(time.Hour)), CreatedAt: &start, Artifacts: []gitlab.JobArtifact{{}}},
+				})).
+				Then(httpmock.NewJsonResponderOrPanic(http.StatusOK, []*gitlab.Job{})))
+
+		var buf strings.Builder
+		ctx := context.WithValue(ctx, engine.LoggerKey, engine.NewTestLogger(&buf))
+		ctx = context.WithValue(ctx, engine.RetryPolicyKey, engine.RetryPolicy{MaxAttempts: 2, StatusCodes: []int{http.StatusServiceUnavailable}})
+
+		ro, _ := engine.NewRunOptions(engine.WithThresholdDuration(time.Second))
+
+		jobs := make(chan models.Job, 10)
+		t.Cleanup(func() { close(jobs) })
+
+		// Act
+		artifacts.ReadJobs(ctx, client, ro)(project, jobs)
+
+		// Assert
+		testutils.Equal(t, 2, len(jobs)) // second page was retried instead of skipped
+		testutils.Equal(t, 6, httpmock.GetTotalCallCount())
+		testutils.Contains(t, buf.String(), "retrying gitlab api call")
+		testutils.NotContains(t, buf.String(), "failed to retrieve project jobs")
 	})
+
+	t.Run("success_resume", func(t *testing.T) {
+		// Arrange
+		now := time.Now()
+		start := now.Add(-2 * time.Hour) // jobs are old and artifacts not cleaned yet
+
+		var pages []string
+		t.Cleanup(httpmock.Reset)
+		registerProtectedRefs(project.ID, nil, nil)
+		httpmock.RegisterResponder(http.MethodGet, fmt.Sprintf(jobsURL, project.ID), func(req *http.Request) (*http.Response, error) {
+			pages = append(pages, req.URL.Query().Get("page"))
+			if len(pages) > 1 {
+				return httpmock.NewJsonResponse(http.StatusOK, []*gitlab.Job{})
+			}
+			return httpmock.NewJsonResponse(http.StatusOK, []*gitlab.Job{
+				{ID: 7, ArtifactsExpireAt: lo.ToPtr(now.Add(time.Hour)), CreatedAt: &start, Artifacts: []gitlab.JobArtifact{{}}},
+			})
+		})
+
+		ro, err := engine.NewRunOptions(
+			engine.WithResume(true),
+			engine.WithStateFile(filepath.Join(t.TempDir(), "state.json")),
+			engine.WithThresholdDuration(time.Second))
+		testutils.NoError(testutils.Require(t), err)
+		checkpoint, err := ro.OpenCheckpoint()
+		testutils.NoError(testutils.Require(t), err)
+		checkpoint.SetJobsPage(project.ID, 5)
+		ctx := context.WithValue(ctx, engine.CheckpointKey, checkpoint)
+
+		jobs := make(chan models.Job, 10)
+		t.Cleanup(func() { close(jobs) })
+
+		// Act
+		artifacts.ReadJobs(ctx, client, ro)(project, jobs)
+
+		// Assert
+		testutils.Equal(t, 1, len(jobs))
+		testutils.Equal(t, "4,5", strings.Join(pages, ",")) // last listed page may still have been in flight
+		testutils.Equal(t, 4, checkpoint.JobsPage(project.ID))
+	})
+
+	t.Run("success_keep_latest_ref", func(t *testing.T) {
+		// Arrange
+		old := lo.ToPtr(time.Now().Add(-2 * time.Hour)) // all jobs are old
+
+		t.Cleanup(httpmock.Reset)
+		registerProtectedRefs(project.ID, nil, nil)
+		httpmock.RegisterResponder(http.MethodGet, fmt.Sprintf(jobsURL, project.ID),
+			httpmock.NewJsonResponderOrPanic(http.StatusOK, []*gitlab.Job{
+				{ID: 9, Ref: "main", Pipeline: gitlab.JobPipeline{ID: 3, Status: "failed"}, CreatedAt: old, Artifacts: []gitlab.JobArtifact{{}}},
+				{ID: 8, Ref: "main", Pipeline: gitlab.JobPipeline{ID: 2, Status: "success"}, CreatedAt: old, Artifacts: []gitlab.JobArtifact{{}}}, // kept
+				{ID: 7, Ref: "main", Pipeline: gitlab.JobPipeline{ID: 2, Status: "success"}, CreatedAt: old, Artifacts: []gitlab.JobArtifact{{}}}, // kept
+				{ID: 6, Ref: "main", Pipeline: gitlab.JobPipeline{ID: 1, Status: "success"}, CreatedAt: old, Artifacts: []gitlab.JobArtifact{{}}},
+				{ID: 5, Ref: "feat", Pipeline: gitlab.JobPipeline{ID: 1, Status: "success"}, CreatedAt: old, Artifacts: []gitlab.JobArtifact{{}}}, // kept
+			}).Then(httpmock.NewJsonResponderOrPanic(http.StatusOK, []*gitlab.Job{})))
+
+		ro, _ := engine.NewRunOptions(engine.WithKeepLatestJobsBy(engine.KeepLatestRef), engine.WithThresholdDuration(time.Hour))
+
+		var buf strings.Builder
+		ctx := context.WithValue(ctx, engine.LoggerKey, engine.NewTestLogger(&buf))
+
+		jobs := make(chan models.Job, 10)
+		t.Cleanup(func() { close(jobs) })
+
+		// Act
+		artifacts.ReadJobs(ctx, client, ro)(project, jobs)
+
+		// Assert
+		testutils.Equal(testutils.Require(t), 2, len(jobs))
+		testutils.Equal(t, int64(9), (<-jobs).ID)
+		testutils.Equal(t, int64(6), (<-jobs).ID)
+		testutils.Contains(t, buf.String(), "keeping latest successful job's artifacts job_id=8 pipeline_id=2 project_id=5 ref=main")
+	})
+
+	t.Run("success_keep_latest_ref_name", func(t *testing.T) {
+		// Arrange
+		old := lo.ToPtr(time.Now().Add(-2 * time.Hour)) // all jobs are old
+
+		t.Cleanup(httpmock.Reset)
+		registerProtectedRefs(project.ID, nil, nil)
+		httpmock.RegisterResponder(http.MethodGet, fmt.Sprintf(jobsURL, project.ID),
+			httpmock.NewJsonResponderOrPanic(http.StatusOK, []*gitlab.Job{
+				{ID: 9, Name: "build", Ref: "main", Status: "failed", CreatedAt: old, Artifacts: []gitlab.JobArtifact{{}}},
+				{ID: 8, Name: "test", Ref: "main", Status: "success", CreatedAt: old, Artifacts: []gitlab.JobArtifact{{}}},  // kept
+				{ID: 7, Name: "build", Ref: "main", Status: "success", CreatedAt: old, Artifacts: []gitlab.JobArtifact{{}}}, // kept
+				{ID: 6, Name: "build", Ref: "main", Status: "success", CreatedAt: old, Artifacts: []gitlab.JobArtifact{{}}},
+			}).Then(httpmock.NewJsonResponderOrPanic(http.StatusOK, []*gitlab.Job{})))
+
+		ro, _ := engine.NewRunOptions(engine.WithKeepLatestJobsBy(engine.KeepLatestRefName), engine.WithThresholdDuration(time.Hour))
+
+		jobs := make(chan models.Job, 10)
+		t.Cleanup(func() { close(jobs) })
+
+		// Act
+		artifacts.ReadJobs(ctx, client, ro)(project, jobs)
+
+		// Assert
+		testutils.Equal(testutils.Require(t), 2, len(jobs))
+		testutils.Equal(t, int64(9), (<-jobs).ID)
+		testutils.Equal(t, int64(6), (<-jobs).ID)
+	})
+
+	t.Run("success_policy", func(t *testing.T) {
+		// Arrange
+		project := artifacts.Project{Project: models.Project{ID: 5, PathWithNamespace: "releases/app"}}
+		recent := lo.ToPtr(time.Now().Add(-2 * time.Hour))
+		old := lo.ToPtr(time.Now().Add(-48 * time.Hour))
+
+		t.Cleanup(httpmock.Reset)
+		registerProtectedRefs(project.ID, nil, nil)
+		httpmock.RegisterResponder(http.MethodGet, fmt.Sprintf(jobsURL, project.ID),
+			httpmock.NewJsonResponderOrPanic(http.StatusOK, []*gitlab.Job{
+				{ID: 9, Name: "lint", CreatedAt: old, Artifacts: []gitlab.JobArtifact{{}}},     // filtered by name
+				{ID: 8, Name: "build", CreatedAt: recent, Artifacts: []gitlab.JobArtifact{{}}}, // before policy threshold, not counted
+				{ID: 7, Name: "build", CreatedAt: old, Artifacts: []gitlab.JobArtifact{{}}},    // kept by count
+				{ID: 6, Name: "build", CreatedAt: old, Artifacts: []gitlab.JobArtifact{{}}},
+			}).Then(httpmock.NewJsonResponderOrPanic(http.StatusOK, []*gitlab.Job{})))
+
+		ro, err := engine.NewRunOptions(
+			engine.WithPolicies(
+				engine.Policy{Paths: []string{"^sandboxes/"}, ThresholdDuration: time.Minute},
+				engine.Policy{Name: "releases", Paths: []string{"^releases/"}, JobNames: []string{"^build$"}, KeepCount: 1, ThresholdDuration: 24 * time.Hour},
+			),
+			engine.WithThresholdDuration(time.Hour))
+		testutils.NoError(testutils.Require(t), err)
+
+		var buf strings.Builder
+		ctx := context.WithValue(ctx, engine.LoggerKey, engine.NewTestLogger(&buf))
+
+		jobs := make(chan models.Job, 10)
+		t.Cleanup(func() { close(jobs) })
+
+		// Act
+		artifacts.ReadJobs(ctx, client, ro)(project, jobs)
+
+		// Assert
+		testutils.Equal(testutils.Require(t), 1, len(jobs))
+		testutils.Equal(t, int64(6), (<-jobs).ID)
+		logs := buf.String()
+		testutils.Contains(t, logs, "applying retention policy policy=releases project_id=5 project_path=releases/app")
+		testutils.Contains(t, logs, "keeping most recent job's artifacts job_id=7 project_id=5")
+	})
+
+	t.Run("success_archived_threshold", func(t *testing.T) {
+		// Arrange
+		project := artifacts.Project{Project: models.Project{Archived: true, ID: 5, PathWithNamespace: "releases/app"}}
+		created := lo.ToPtr(time.Now().Add(-2 * time.Hour))
+
+		t.Cleanup(httpmock.Reset)
+		registerProtectedRefs(project.ID, nil, nil)
+		httpmock.RegisterResponder(http.MethodGet, fmt.Sprintf(jobsURL, project.ID),
+			httpmock.NewJsonResponderOrPanic(http.StatusOK, []*gitlab.Job{
+				{ID: 9, CreatedAt: created, Artifacts: []gitlab.JobArtifact{{}}},
+			}).Then(httpmock.NewJsonResponderOrPanic(http.StatusOK, []*gitlab.Job{})))
+
+		ro, err := engine.NewRunOptions(
+			engine.WithArchivedMode(engine.ArchivedInclude),
+			engine.WithIncludeArchivedThresholdDuration(time.Hour),
+			engine.WithPolicies(engine.Policy{Paths: []string{"^releases/"}, ThresholdDuration: 24 * time.Hour}),
+			engine.WithThresholdDuration(24*time.Hour))
+		testutils.NoError(testutils.Require(t), err)
+
+		jobs := make(chan models.Job, 10)
+		t.Cleanup(func() { close(jobs) })
+
+		// Act
+		artifacts.ReadJobs(ctx, client, ro)(project, jobs)
+
+		// Assert
+		testutils.Equal(testutils.Require(t), 1, len(jobs))
+		testutils.Equal(t, int64(9), (<-jobs).ID)
+	})
+
+	t.Run("success_job_filters", func(t *testing.T) {
+		// Arrange
+		old := lo.ToPtr(time.Now().Add(-2 * time.Hour)) // all jobs are old
+
+		var query url.Values
+		t.Cleanup(httpmock.Reset)
+		registerProtectedRefs(project.ID, nil, nil)
+		httpmock.RegisterResponder(http.MethodGet, fmt.Sprintf(jobsURL, project.ID), func(req *http.Request) (*http.Response, error) {
+			if req.URL.Query().Get("page") != "1" {
+				return httpmock.NewJsonResponse(http.StatusOK, []*gitlab.Job{})
+			}
+			query = req.URL.Query()
+			return httpmock.NewJsonResponse(http.StatusOK, []*gitlab.Job{
+				{ID: 9, Name: "test:unit", Ref: "feat", Stage: "test", CreatedAt: old, Artifacts: []gitlab.JobArtifact{{}}},
+				{ID: 8, Name: "test:unit", Ref: "main", Stage: "test", CreatedAt: old, Artifacts: []gitlab.JobArtifact{{}}},    // excluded ref
+				{ID: 7, Name: "build:linux", Ref: "feat", Stage: "build", CreatedAt: old, Artifacts: []gitlab.JobArtifact{{}}}, // no matching name
+			})
+		})
+
+		ro, err := engine.NewRunOptions(
+			engine.WithJobFilters(engine.JobFilters{ExcludeRefs: []string{"^main$"}, Names: []string{"^test:"}, Statuses: []string{"canceled", "failed"}}),
+			engine.WithThresholdDuration(time.Hour))
+		testutils.NoError(testutils.Require(t), err)
+
+		jobs := make(chan models.Job, 10)
+		t.Cleanup(func() { close(jobs) })
+
+		// Act
+		artifacts.ReadJobs(ctx, client, ro)(project, jobs)
+
+		// Assert
+		testutils.Equal(t, "canceled,failed", strings.Join(query["scope[]"], ","))
+		testutils.Equal(testutils.Require(t), 1, len(jobs))
+		testutils.Equal(t, int64(9), (<-jobs).ID)
+	})
+
+	t.Run("success_artifact_types", func(t *testing.T) {
+		// Arrange
+		old := lo.ToPtr(time.Now().Add(-2 * time.Hour)) // all jobs are old
+
+		t.Cleanup(httpmock.Reset)
+		registerProtectedRefs(project.ID, nil, nil)
+		httpmock.RegisterResponder(http.MethodGet, fmt.Sprintf(jobsURL, project.ID),
+			httpmock.NewJsonResponderOrPanic(http.StatusOK, []*gitlab.Job{
+				{ID: 9, CreatedAt: old, Artifacts: []gitlab.JobArtifact{{FileType: "archive"}, {FileType: "metadata"}, {FileType: "trace"}}},
+				{ID: 8, CreatedAt: old, Artifacts: []gitlab.JobArtifact{{FileType: "archive"}, {FileType: "junit"}}}, // junit must be kept
+			}).Then(httpmock.NewJsonResponderOrPanic(http.StatusOK, []*gitlab.Job{})))
+
+		var buf strings.Builder
+		ctx := context.WithValue(ctx, engine.LoggerKey, engine.NewTestLogger(&buf))
+
+		ro, err := engine.NewRunOptions(
+			engine.WithJobFilters(engine.JobFilters{ArtifactTypes: []string{"archive"}}),
+			engine.WithThresholdDuration(time.Hour))
+		testutils.NoError(testutils.Require(t), err)
+
+		jobs := make(chan models.Job, 10)
+		t.Cleanup(func() { close(jobs) })
+
+		// Act
+		artifacts.ReadJobs(ctx, client, ro)(project, jobs)
+
+		// Assert
+		testutils.Equal(testutils.Require(t), 1, len(jobs))
+		testutils.Equal(t, int64(9), (<-jobs).ID)
+		testutils.Contains(t, buf.String(), "keeping job's artifacts with types not allowed for deletion artifact_types=archive,junit job_id=8")
+	})
+
+	t.Run("success_storage_budget", func(t *testing.T) {
+		// Arrange
+		now := time.Now()
+		artifact := func(size int64) []gitlab.JobArtifact {
+			return []gitlab.JobArtifact{{FileType: "archive", Size: size}, {FileType: "trace", Size: 100_000}} // trace isn't counted
+		}
+
+		t.Cleanup(httpmock.Reset)
+		registerProtectedRefs(project.ID, nil, nil)
+		httpmock.RegisterResponder(http.MethodGet, fmt.Sprintf(jobsURL, project.ID),
+			httpmock.NewJsonResponderOrPanic(http.StatusOK, []*gitlab.Job{
+				{ID: 10, Status: "success", CreatedAt: lo.ToPtr(now.Add(-10 * time.Minute)), Artifacts: artifact(2000)}, // too recent
+				{ID: 9, Status: "success", CreatedAt: lo.ToPtr(now.Add(-2 * time.Hour)), Artifacts: artifact(1000)},
+				{ID: 8, Status: "failed", CreatedAt: lo.ToPtr(now.Add(-2 * time.Hour)), Artifacts: artifact(3000)},
+				{ID: 7, Status: "success", CreatedAt: lo.ToPtr(now.Add(-2 * time.Hour)), Artifacts: artifact(2000)},
+				{ID: 6, Status: "success", CreatedAt: lo.ToPtr(now.Add(-3 * time.Hour)), Artifacts: artifact(2000)},
+				{ID: 5, Status: "success", CreatedAt: lo.ToPtr(now.Add(-3 * time.Hour)), Artifacts: artifact(4000), ArtifactsExpireAt: lo.ToPtr(now)}, // expired
+				{ID: 4, Status: "canceled", CreatedAt: lo.ToPtr(now.Add(-3 * time.Hour)), Artifacts: artifact(1000)},                                  // counted but not cleaned
+			}).Then(httpmock.NewJsonResponderOrPanic(http.StatusOK, []*gitlab.Job{})))
+
+		var buf strings.Builder
+		ctx := context.WithValue(ctx, engine.LoggerKey, engine.NewTestLogger(&buf))
+
+		ro, err := engine.NewRunOptions(
+			engine.WithMaxArtifactsSize(5000),
+			engine.WithThresholdDuration(time.Hour))
+		testutils.NoError(testutils.Require(t), err)
+
+		jobs := make(chan models.Job, 10)
+		t.Cleanup(func() { close(jobs) })
+
+		// Act
+		artifacts.ReadJobs(ctx, client, ro)(project, jobs)
+
+		// Assert
+		testutils.Equal(testutils.Require(t), 3, len(jobs))
+		testutils.Equal(t, int64(8), (<-jobs).ID) // largest one first
+		testutils.Equal(t, int64(6), (<-jobs).ID) // oldest one of same size first
+		testutils.Equal(t, int64(7), (<-jobs).ID)
+		testutils.Contains(t, buf.String(), "project artifacts exceed storage budget artifacts_size=11KB jobs=3 max_artifacts_size=5KB project_id=5")
+	})
+
+	t.Run("success_protected_refs", func(t *testing.T) {
+		// Arrange
+		old := lo.ToPtr(time.Now().Add(-48 * time.Hour)) // all jobs are two days old
+
+		for name, tc := range map[string]struct {
+			threshold time.Duration
+			expected  []int64
+		}{
+			"never":  {expected: []int64{9}},
+			"longer": {threshold: 72 * time.Hour, expected: []int64{9}},
+			"older":  {threshold: 24 * time.Hour, expected: []int64{9, 8, 7}},
+		} {
+			t.Run(name, func(t *testing.T) {
+				t.Cleanup(httpmock.Reset)
+				registerProtectedRefs(project.ID,
+					[]*gitlab.ProtectedBranch{{Name: "main"}},
+					[]*gitlab.ProtectedTag{{Name: "v*"}})
+				httpmock.RegisterResponder(http.MethodGet, fmt.Sprintf(jobsURL, project.ID),
+					httpmock.NewJsonResponderOrPanic(http.StatusOK, []*gitlab.Job{
+						{ID: 9, Ref: "feat", CreatedAt: old, Artifacts: []gitlab.JobArtifact{{}}},
+						{ID: 8, Ref: "main", CreatedAt: old, Artifacts: []gitlab.JobArtifact{{}}},              // protected branch
+						{ID: 7, Ref: "v1.0.0", Tag: true, CreatedAt: old, Artifacts: []gitlab.JobArtifact{{}}}, // protected tag
+					}).Then(httpmock.NewJsonResponderOrPanic(http.StatusOK, []*gitlab.Job{})))
+
+				ro, err := engine.NewRunOptions(
+					engine.WithProtectedThresholdDuration(tc.threshold),
+					engine.WithThresholdDuration(time.Hour))
+				testutils.NoError(testutils.Require(t), err)
+
+				jobs := make(chan models.Job, 10)
+
+				// Act
+				artifacts.ReadJobs(ctx, client, ro)(project, jobs)
+				close(jobs)
+
+				// Assert
+				var ids []int64
+				for job := range jobs {
+					testutils.Equal(t, job.ID != 9, job.Protected)
+					ids = append(ids, job.ID)
+				}
+				testutils.Equal(testutils.Require(t), len(tc.expected), len(ids))
+				for i, id := range tc.expected {
+					testutils.Equal(t, id, ids[i])
+				}
+			})
+		}
+	})
+}
+
+// registerProtectedRefs registers protected branches and tags endpoints mocks of input project.
+func registerProtectedRefs(projectID int64, branches []*gitlab.ProtectedBranch, tags []*gitlab.ProtectedTag) {
+	httpmock.RegisterResponder(http.MethodGet, fmt.Sprintf(protectedBranchesURL, projectID),
+		httpmock.NewJsonResponderOrPanic(http.StatusOK, branches).
+			Then(httpmock.NewJsonResponderOrPanic(http.StatusOK, []*gitlab.ProtectedBranch{})))
+	httpmock.RegisterResponder(http.MethodGet, fmt.Sprintf(protectedTagsURL, projectID),
+		httpmock.NewJsonResponderOrPanic(http.StatusOK, tags).
+			Then(httpmock.NewJsonResponderOrPanic(http.StatusOK, []*gitlab.ProtectedTag{})))
 }
 
 func TestDeleteArtifacts(t *testing.T) {
@@ -157,7 +792,7 @@ func TestDeleteArtifacts(t *testing.T) {
 		gitlab.WithoutRetries())
 	testutils.NoError(testutils.Require(t), err)
 
-	job := models.Job{ID: 7, ProjectID: 5}
+	job := models.Job{Artifacts: []models.Artifact{{FileType: "archive", Size: 100}, {FileType: "junit"}}, ID: 7, ProjectID: 5}
 
 	t.Run("success_dry_run", func(t *testing.T) {
 		// Arrange
@@ -169,7 +804,26 @@ func TestDeleteArtifacts(t *testing.T) {
 
 		// Assert
 		testutils.False(t, job.Cleaned)
-		testutils.Contains(t, buf.String(), "running in dry run mode, skipping job's artifacts deletion")
+		testutils.Contains(t, buf.String(), "running in dry run mode, skipping job's artifacts deletion artifact_types=archive,junit job_id=7")
+	})
+
+	t.Run("success_canceled", func(t *testing.T) {
+		// Arrange
+		t.Cleanup(httpmock.Reset)
+
+		var buf strings.Builder
+		ctx, cancel := context.WithCancel(context.WithValue(ctx, engine.LoggerKey, engine.NewTestLogger(&buf)))
+		cancel()
+
+		// Act
+		job := artifacts.DeleteArtifacts(ctx, client, engine.RunOptions{GracePeriod: time.Minute})(job)
+
+		// Assert
+		testutils.False(t, job.Cleaned)
+		testutils.True(t, job.Interrupted)
+		testutils.NoError(t, job.Error)
+		testutils.Equal(t, 0, httpmock.GetTotalCallCount())
+		testutils.Contains(t, buf.String(), "run interrupted, skipping job's artifacts deletion job_id=7")
 	})
 
 	t.Run("error_delete_artifacts", func(t *testing.T) {
@@ -186,6 +840,7 @@ func TestDeleteArtifacts(t *testing.T) {
 
 		// Assert
 		testutils.False(t, job.Cleaned)
+		testutils.Error(t, job.Error)
 		logs := buf.String()
 		testutils.Contains(t, logs, "an error")
 		testutils.Contains(t, logs, "failed to delete job's artifacts")
@@ -199,6 +854,7 @@ func TestDeleteArtifacts(t *testing.T) {
 
 		var buf strings.Builder
 		ctx := context.WithValue(ctx, engine.LoggerKey, engine.NewTestLogger(&buf))
+		deleted, bytes := counter(t, metrics.ArtifactsDeleted), counter(t, metrics.ArtifactsDeletedBytes)
 
 		// Act
 		job := artifacts.DeleteArtifacts(ctx, client, engine.RunOptions{})(job)
@@ -206,24 +862,52 @@ func TestDeleteArtifacts(t *testing.T) {
 		// Assert
 		testutils.Equal(t, "", buf.String())
 		testutils.True(t, job.Cleaned)
+		testutils.Equal(t, deleted+1, counter(t, metrics.ArtifactsDeleted))
+		testutils.Equal(t, bytes+float64(job.ArtifactsSize()), counter(t, metrics.ArtifactsDeletedBytes))
 	})
 }
 
+// counter returns the current value of input prometheus counter.
+func counter(t *testing.T, c prometheus.Counter) float64 {
+	t.Helper()
+	var metric dto.Metric
+	testutils.NoError(testutils.Require(t), c.Write(&metric))
+	return metric.GetCounter().GetValue()
+}
+
 func TestObserveCleanup(t *testing.T) {
+	t.Run("success_no_report", func(t *testing.T) {
+		// Arrange
+		jobs := make(chan models.Job, 10)
+		jobs <- models.Job{Artifacts: []models.Artifact{{FileType: "archive", Size: 100}}, Cleaned: true}
+		close(jobs)
+
+		// Act
+		project := artifacts.ObserveCleanup(engine.RunOptions{})(artifacts.Project{}, jobs)
+
+		// Assert
+		testutils.Equal(t, 1, project.JobsCleaned)
+		testutils.Equal(t, 100, project.BytesCleaned)
+		testutils.Equal(t, 0, len(project.Jobs))
+	})
+
 	t.Run("success", func(t *testing.T) {
 		// Arrange
 		jobs := make(chan models.Job, 10)
-		jobs <- models.Job{}
-		jobs <- models.Job{Cleaned: true}
-		jobs <- models.Job{Cleaned: true}
+		jobs <- models.Job{Artifacts: []models.Artifact{{FileType: "junit", Size: 1000}}}
+		jobs <- models.Job{Artifacts: []models.Artifact{{FileType: "archive", Size: 100}}, Cleaned: true}
+		jobs <- models.Job{Artifacts: []models.Artifact{{FileType: "archive", Size: 200}, {FileType: "cobertura", Size: 20}}, Cleaned: true}
 		close(jobs)
 
 		project := artifacts.Project{}
 
 		// Act
-		project = artifacts.ObserveCleanup(project, jobs)
+		project = artifacts.ObserveCleanup(engine.RunOptions{Report: true})(project, jobs)
 
 		// Assert
 		testutils.Equal(t, 2, project.JobsCleaned)
+		testutils.Equal(t, 320, project.BytesCleaned)
+		testutils.Equal(t, 3, len(project.Jobs))
+		testutils.Equal(t, "archive,cobertura", strings.Join(project.ArtifactTypes, ","))
 	})
 }
@@ -3,24 +3,34 @@ package artifacts
 import (
 	"github.com/fogfactory/pipe"
 	"github.com/samber/lo"
-
-	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/artifacts/models"
 )
 
 // PipeProjectBuilder is the pipe builder for Project structure.
-type PipeProjectBuilder struct {
-	preproc    []pipe.Process[Project]
-	dispatched *pipe.PoolProcess[Project]
-	postproc   []pipe.Process[Project]
+//
+// T is the type of Project children (e.g. models.Job) sent into pipe processing during split.
+type PipeProjectBuilder[T any] struct {
+	concurrency int
+	preproc     []pipe.Process[Project]
+	dispatched  *pipe.PoolProcess[Project]
+	postproc    []pipe.Process[Project]
 }
 
 // NewPipeProjectBuilder creates a new PipeProjectBuilder.
-func NewPipeProjectBuilder() *PipeProjectBuilder {
-	return &PipeProjectBuilder{}
+func NewPipeProjectBuilder[T any]() *PipeProjectBuilder[T] {
+	return &PipeProjectBuilder[T]{}
+}
+
+// Concurrency sets the maximum number of children processed concurrently for each Project, unlimited when zero.
+//
+// It must be called before Split and avoids a Project with a lot of children (e.g. a monorepo jobs)
+// to take all routines of children pool, starving other projects.
+func (b *PipeProjectBuilder[T]) Concurrency(concurrency int) *PipeProjectBuilder[T] {
+	b.concurrency = concurrency
+	return b
 }
 
 // Processor adds a processor to either the pre processors or post processors of Project structure.
-func (b *PipeProjectBuilder) Processor(proc pipe.Process[Project]) *PipeProjectBuilder {
+func (b *PipeProjectBuilder[T]) Processor(proc pipe.Process[Project]) *PipeProjectBuilder[T] {
 	if b.dispatched == nil {
 		b.preproc = append(b.preproc, proc)
 	} else {
@@ -29,13 +39,13 @@ func (b *PipeProjectBuilder) Processor(proc pipe.Process[Project]) *PipeProjectB
 	return b
 }
 
-// Split defines the split function to send Projects' jobs into pipe.
-func (b *PipeProjectBuilder) Split(split pipe.Split[Project, models.Job]) *PipeJobBuilder {
-	return &PipeJobBuilder{parent: b, split: split}
+// Split defines the split function to send Projects' children into pipe.
+func (b *PipeProjectBuilder[T]) Split(split pipe.Split[Project, T]) *PipeChildBuilder[T] {
+	return &PipeChildBuilder[T]{parent: b, split: split}
 }
 
 // Build builds the pipe processors of Project structure.
-func (b *PipeProjectBuilder) Build() pipe.PoolProcess[Project] {
+func (b *PipeProjectBuilder[T]) Build() pipe.PoolProcess[Project] {
 	if b.dispatched == nil {
 		panic("no dispatcher")
 	}
@@ -45,29 +55,72 @@ func (b *PipeProjectBuilder) Build() pipe.PoolProcess[Project] {
 	return pipe.Link(preprocs, *b.dispatched, postprocs)
 }
 
-// PipeJobBuilder is the builder for Job pipe processing.
-type PipeJobBuilder struct {
-	parent *PipeProjectBuilder
+// PipeChildBuilder is the builder for Project children (jobs, tags, etc.) pipe processing.
+type PipeChildBuilder[T any] struct {
+	parent *PipeProjectBuilder[T]
 
-	split pipe.Split[Project, models.Job]
-	procs []pipe.Process[models.Job]
+	split pipe.Split[Project, T]
+	procs []pipe.Process[T]
 }
 
-// Processor adds a processor to Job structure.
-func (b *PipeJobBuilder) Processor(proc pipe.Process[models.Job]) *PipeJobBuilder {
+// Processor adds a processor to children structure.
+func (b *PipeChildBuilder[T]) Processor(proc pipe.Process[T]) *PipeChildBuilder[T] {
 	b.procs = append(b.procs, proc)
 	return b
 }
 
-// Merge defines the merge function from Jobs' to their project.
+// Merge defines the merge function from children to their project.
 // It returns the parent PipeProjectBuilder.
-func (b *PipeJobBuilder) Merge(merge pipe.Merge[Project, models.Job]) *PipeProjectBuilder {
-	dispatcher, err := pipe.NewDispatch(b.split, merge)
+func (b *PipeChildBuilder[T]) Merge(merge pipe.Merge[Project, T]) *PipeProjectBuilder[T] {
+	split := b.split
+	concurrency := b.parent.concurrency
+	if concurrency > 0 {
+		split, merge = limitSplit(split), limitMerge(merge)
+	}
+
+	dispatcher, err := pipe.NewDispatch(split, merge)
 	if err != nil {
 		panic(err)
 	}
 
 	procs := pipe.Link(pipe.AsPoolProcesses(b.procs...)...)
-	b.parent.dispatched = lo.ToPtr(pipe.Wrap(procs, dispatcher))
+	wrapped := pipe.Wrap(procs, dispatcher)
+	b.parent.dispatched = lo.ToPtr(pipe.PoolProcess[Project](func(pools *pipe.Pools, p Project) Project {
+		if concurrency > 0 {
+			p.slots = make(chan struct{}, concurrency)
+		}
+		return wrapped(pools, p)
+	}))
 	return b.parent
 }
+
+// limitSplit returns a split sending children of input split only when a slot of their Project is available.
+func limitSplit[T any](split pipe.Split[Project, T]) pipe.Split[Project, T] {
+	return func(p Project, in chan<- T) {
+		children := make(chan T)
+		go func() {
+			defer close(children)
+			split(p, children)
+		}()
+
+		for child := range children {
+			p.slots <- struct{}{}
+			in <- child
+		}
+	}
+}
+
+// limitMerge returns a merge releasing a slot of the Project for each processed child before merging it with input merge.
+func limitMerge[T any](merge pipe.Merge[Project, T]) pipe.Merge[Project, T] {
+	return func(p Project, out <-chan T) Project {
+		processed := make(chan T)
+		go func() {
+			defer close(processed)
+			for child := range out {
+				<-p.slots
+				processed <- child
+			}
+		}()
+		return merge(p, processed)
+	}
+}
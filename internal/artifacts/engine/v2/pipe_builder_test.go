@@ -0,0 +1,69 @@
+package artifacts_test
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/fogfactory/pipe"
+
+	artifacts "github.com/kilianpaquier/gitlab-storage-cleaner/internal/artifacts/engine/v2"
+	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/artifacts/models"
+	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/testutils"
+)
+
+func TestPipeProjectBuilder(t *testing.T) {
+	t.Run("success_concurrency", func(t *testing.T) {
+		// Arrange
+		pools, err := pipe.NewPools(2, 100)
+		testutils.NoError(testutils.Require(t), err)
+		t.Cleanup(pools.Release)
+
+		var inflight, peak atomic.Int64
+		var mutex sync.Mutex
+		merged := map[int64]int{}
+
+		piping := artifacts.NewPipeProjectBuilder[models.Job]().
+			Concurrency(3).
+			Split(func(p artifacts.Project, in chan<- models.Job) {
+				for i := range 20 {
+					in <- models.Job{ID: int64(i), ProjectID: p.ID}
+				}
+			}).
+			Processor(func(job models.Job) models.Job {
+				current := inflight.Add(1)
+				defer inflight.Add(-1)
+				for {
+					previous := peak.Load()
+					if current <= previous || peak.CompareAndSwap(previous, current) {
+						break
+					}
+				}
+				time.Sleep(time.Millisecond)
+				return job
+			}).
+			Merge(func(p artifacts.Project, out <-chan models.Job) artifacts.Project {
+				count := 0
+				for range out {
+					count++
+				}
+				mutex.Lock()
+				defer mutex.Unlock()
+				merged[p.ID] = count
+				return p
+			}).
+			Build()
+
+		projects := make(chan artifacts.Project, 1)
+		projects <- artifacts.Project{Project: models.Project{ID: 1}}
+		close(projects)
+
+		// Act
+		pipe.Run(pools, projects, piping)
+
+		// Assert
+		testutils.Equal(t, 20, merged[1])
+		testutils.True(t, peak.Load() <= 3)
+	})
+}
@@ -0,0 +1,240 @@
+package artifacts
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/artifacts/engine"
+	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/artifacts/models"
+)
+
+// JobStatus is the cleanup outcome of a job matched for artifacts deletion.
+type JobStatus string
+
+const (
+	// JobDeleted is the status of a job whose artifacts were deleted.
+	JobDeleted JobStatus = "deleted"
+
+	// JobDryRun is the status of a job whose artifacts deletion was skipped because of dry run mode.
+	JobDryRun JobStatus = "dry-run"
+
+	// JobFailed is the status of a job whose artifacts deletion failed.
+	JobFailed JobStatus = "failed"
+
+	// JobInterrupted is the status of a job whose artifacts deletion wasn't attempted because the run was interrupted.
+	JobInterrupted JobStatus = "interrupted"
+)
+
+// JobReport represents the cleanup outcome of a job matched for artifacts deletion.
+type JobReport struct {
+	ArtifactsSize int64     `json:"artifacts_size"`
+	Error         string    `json:"error,omitempty"`
+	ID            int64     `json:"id"`
+	Name          string    `json:"name"`
+	Ref           string    `json:"ref"`
+	Stage         string    `json:"stage"`
+	Status        JobStatus `json:"status"`
+}
+
+// JobReportFrom returns the cleanup outcome of input job once it went through DeleteArtifacts
+// with input dry run mode.
+//
+// A job neither cleaned, failed nor skipped because of dry run mode is considered interrupted.
+func JobReportFrom(job models.Job, dryRun bool) JobReport {
+	report := JobReport{
+		ArtifactsSize: job.ArtifactsSize(),
+		ID:            job.ID,
+		Name:          job.Name,
+		Ref:           job.Ref,
+		Stage:         job.Stage,
+		Status:        JobInterrupted,
+	}
+	switch {
+	case job.Cleaned:
+		report.Status = JobDeleted
+	case job.Error != nil:
+		report.Status = JobFailed
+		report.Error = job.Error.Error()
+	case job.Interrupted:
+		report.Status = JobInterrupted
+	case dryRun:
+		report.Status = JobDryRun
+	}
+	return report
+}
+
+// ProjectReport represents the cleanup outcome of a processed project (i.e. matching run options paths and filters)
+// with all its jobs matched for artifacts deletion, or the reason a project was skipped.
+type ProjectReport struct {
+	BytesCleaned int64       `json:"bytes_cleaned"`
+	ID           int64       `json:"id"`
+	Jobs         []JobReport `json:"jobs"`
+	JobsCleaned  int         `json:"jobs_cleaned"`
+	JobsFailed   int         `json:"jobs_failed"`
+	Path         string      `json:"path"`
+
+	// Skipped is the reason the project was skipped (e.g. 'excluded' or 'completed in a previous run'), empty when it was processed.
+	Skipped string `json:"skipped,omitempty"`
+}
+
+// ProjectReportFrom returns the cleanup outcome of input project once it went through the whole pipe.
+func ProjectReportFrom(p Project) ProjectReport {
+	jobs := p.Jobs
+	if jobs == nil {
+		jobs = []JobReport{} // avoid writing null in JSON reports when there's no matched job
+	}
+	return ProjectReport{
+		BytesCleaned: p.BytesCleaned,
+		ID:           p.ID,
+		Jobs:         jobs,
+		JobsCleaned:  p.JobsCleaned,
+		JobsFailed:   p.JobsFailed,
+		Path:         p.PathWithNamespace,
+	}
+}
+
+// ReportFormat represents the output format of run reports.
+type ReportFormat string
+
+const (
+	// ReportCSV writes one line per matched job (or per project without any matched job, e.g. skipped projects).
+	ReportCSV ReportFormat = "csv"
+
+	// ReportJSON writes run totals with all visited projects and the matched jobs of processed ones.
+	ReportJSON ReportFormat = "json"
+
+	// ReportMarkdown writes run totals, a table of processed projects, a table of skipped projects and a table of matched jobs,
+	// to be pasted in merge requests or pipelines summaries.
+	ReportMarkdown ReportFormat = "markdown"
+)
+
+// ReportFormats returns all valid run reports output formats.
+func ReportFormats() []ReportFormat {
+	return []ReportFormat{ReportCSV, ReportJSON, ReportMarkdown}
+}
+
+// WriteReport writes the input run summary into w with the input format.
+func WriteReport(w io.Writer, format ReportFormat, summary Summary) error {
+	switch format {
+	case ReportCSV:
+		return writeReportCSV(w, summary)
+	case ReportJSON:
+		return writeReportJSON(w, summary)
+	case ReportMarkdown:
+		return writeReportMarkdown(w, summary)
+	default:
+		return fmt.Errorf("invalid report format '%s'", format)
+	}
+}
+
+// writeReportCSV writes the input run summary as CSV into w.
+func writeReportCSV(w io.Writer, summary Summary) error {
+	writer := csv.NewWriter(w)
+	_ = writer.Write([]string{"project_id", "project_path", "skipped", "job_id", "job_name", "job_ref", "job_stage", "artifacts_size", "status", "error"}) // error is retrieved with writer.Error once flushed
+
+	for _, project := range summary.Reports {
+		projectID := strconv.FormatInt(project.ID, 10)
+		if len(project.Jobs) == 0 {
+			_ = writer.Write([]string{projectID, project.Path, project.Skipped, "", "", "", "", "", "", ""})
+			continue
+		}
+		for _, job := range project.Jobs {
+			_ = writer.Write([]string{
+				projectID, project.Path, project.Skipped,
+				strconv.FormatInt(job.ID, 10), job.Name, job.Ref, job.Stage,
+				strconv.FormatInt(job.ArtifactsSize, 10), string(job.Status), job.Error,
+			})
+		}
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("write csv: %w", err)
+	}
+	return nil
+}
+
+// writeReportJSON writes the input run summary as JSON into w.
+func writeReportJSON(w io.Writer, summary Summary) error {
+	projects := summary.Reports
+	if projects == nil {
+		projects = []ProjectReport{} // avoid writing null when there's no project
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	err := encoder.Encode(struct {
+		BytesCleaned int64           `json:"bytes_cleaned"`
+		Failures     int             `json:"failures"`
+		JobsCleaned  int             `json:"jobs_cleaned"`
+		JobsFailed   int             `json:"jobs_failed"`
+		Projects     []ProjectReport `json:"projects"`
+	}{
+		BytesCleaned: summary.BytesCleaned,
+		Failures:     summary.Failures,
+		JobsCleaned:  summary.JobsCleaned,
+		JobsFailed:   summary.JobsFailed,
+		Projects:     projects,
+	})
+	if err != nil {
+		return fmt.Errorf("write json: %w", err)
+	}
+	return nil
+}
+
+// writeReportMarkdown writes the input run summary as Markdown into w.
+func writeReportMarkdown(w io.Writer, summary Summary) error {
+	var b strings.Builder
+
+	b.WriteString("## Artifacts cleaning report\n\n")
+	fmt.Fprintf(&b, "%d job(s) cleaned for %s in %d project(s).\n", summary.JobsCleaned, engine.Size(summary.BytesCleaned).Human(), summary.Projects)
+	if summary.Failures > 0 {
+		fmt.Fprintf(&b, "\n**%d failure(s) occurred during the run, including %d failed job(s) artifacts deletion.**\n", summary.Failures, summary.JobsFailed)
+	}
+
+	b.WriteString("\n### Projects\n\n")
+	b.WriteString("| Project | Jobs cleaned | Jobs failed | Size cleaned |\n")
+	b.WriteString("| ------- | ------------ | ----------- | ------------ |\n")
+	skipped := make([]ProjectReport, 0, len(summary.Reports))
+	for _, project := range summary.Reports {
+		if project.Skipped != "" {
+			skipped = append(skipped, project)
+			continue
+		}
+		fmt.Fprintf(&b, "| %s | %d | %d | %s |\n", markdownCell(project.Path), project.JobsCleaned, project.JobsFailed, engine.Size(project.BytesCleaned).Human())
+	}
+
+	if len(skipped) > 0 {
+		b.WriteString("\n### Skipped projects\n\n")
+		b.WriteString("| Project | Reason |\n")
+		b.WriteString("| ------- | ------ |\n")
+		for _, project := range skipped {
+			fmt.Fprintf(&b, "| %s | %s |\n", markdownCell(project.Path), markdownCell(project.Skipped))
+		}
+	}
+
+	b.WriteString("\n### Jobs\n\n")
+	b.WriteString("| Project | Job | Ref | Stage | Size | Status | Error |\n")
+	b.WriteString("| ------- | --- | --- | ----- | ---- | ------ | ----- |\n")
+	for _, project := range summary.Reports {
+		for _, job := range project.Jobs {
+			fmt.Fprintf(&b, "| %s | %s (%d) | %s | %s | %s | %s | %s |\n",
+				markdownCell(project.Path), markdownCell(job.Name), job.ID, markdownCell(job.Ref), markdownCell(job.Stage),
+				engine.Size(job.ArtifactsSize).Human(), job.Status, markdownCell(job.Error))
+		}
+	}
+
+	if _, err := io.WriteString(w, b.String()); err != nil {
+		return fmt.Errorf("write markdown: %w", err)
+	}
+	return nil
+}
+
+// markdownCell escapes the input value to be written in a Markdown table cell.
+func markdownCell(value string) string {
+	return strings.NewReplacer("|", `\|`, "\r", "", "\n", " ").Replace(value)
+}
@@ -0,0 +1,172 @@
+package artifacts_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	artifacts "github.com/kilianpaquier/gitlab-storage-cleaner/internal/artifacts/engine/v2"
+	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/artifacts/models"
+	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/testutils"
+)
+
+func TestJobReportFrom(t *testing.T) {
+	job := models.Job{ID: 7, Name: "build", Ref: "main", Stage: "build", Artifacts: []models.Artifact{{FileType: "archive", Size: 100}}}
+
+	t.Run("success_deleted", func(t *testing.T) {
+		// Arrange
+		job := job
+		job.Cleaned = true
+
+		// Act
+		report := artifacts.JobReportFrom(job, false)
+
+		// Assert
+		testutils.Equal(t, artifacts.JobReport{ArtifactsSize: 100, ID: 7, Name: "build", Ref: "main", Stage: "build", Status: artifacts.JobDeleted}, report)
+	})
+
+	t.Run("success_failed", func(t *testing.T) {
+		// Arrange
+		job := job
+		job.Error = errors.New("403 Forbidden")
+
+		// Act
+		report := artifacts.JobReportFrom(job, false)
+
+		// Assert
+		testutils.Equal(t, artifacts.JobFailed, report.Status)
+		testutils.Equal(t, "403 Forbidden", report.Error)
+	})
+
+	t.Run("success_dry_run", func(t *testing.T) {
+		// Act
+		report := artifacts.JobReportFrom(job, true)
+
+		// Assert
+		testutils.Equal(t, artifacts.JobDryRun, report.Status)
+		testutils.Equal(t, "", report.Error)
+	})
+
+	t.Run("success_interrupted", func(t *testing.T) {
+		// Arrange
+		job := job
+		job.Interrupted = true
+
+		// Act
+		report := artifacts.JobReportFrom(job, true)
+
+		// Assert
+		testutils.Equal(t, artifacts.JobInterrupted, report.Status)
+	})
+
+	t.Run("success_not_deleted", func(t *testing.T) {
+		// Act
+		report := artifacts.JobReportFrom(job, false)
+
+		// Assert
+		testutils.Equal(t, artifacts.JobInterrupted, report.Status)
+	})
+}
+
+func TestWriteReport(t *testing.T) {
+	summary := artifacts.Summary{
+		BytesCleaned: 1 << 20,
+		Failures:     1,
+		JobsCleaned:  1,
+		JobsFailed:   1,
+		Projects:     2,
+		Reports: []artifacts.ProjectReport{
+			{
+				BytesCleaned: 1 << 20,
+				ID:           7,
+				Jobs: []artifacts.JobReport{
+					{ArtifactsSize: 1 << 20, ID: 10, Name: "build", Ref: "main", Stage: "build", Status: artifacts.JobDeleted},
+					{ArtifactsSize: 512, ID: 11, Name: "test", Ref: "main", Stage: "test", Status: artifacts.JobFailed, Error: "403 | Forbidden"},
+				},
+				JobsCleaned: 1,
+				JobsFailed:  1,
+				Path:        "group/project",
+			},
+			{ID: 8, Jobs: []artifacts.JobReport{}, Path: "group/other"},
+			{ID: 9, Jobs: []artifacts.JobReport{}, Path: "group/excluded", Skipped: "excluded"},
+		},
+	}
+
+	t.Run("error_invalid_format", func(t *testing.T) {
+		// Arrange
+		var buf strings.Builder
+
+		// Act
+		err := artifacts.WriteReport(&buf, "xml", summary)
+
+		// Assert
+		testutils.Error(testutils.Require(t), err)
+		testutils.Contains(t, err.Error(), "invalid report format 'xml'")
+	})
+
+	t.Run("success_csv", func(t *testing.T) {
+		// Arrange
+		var buf strings.Builder
+
+		// Act
+		err := artifacts.WriteReport(&buf, artifacts.ReportCSV, summary)
+
+		// Assert
+		testutils.NoError(testutils.Require(t), err)
+		expected := "project_id,project_path,skipped,job_id,job_name,job_ref,job_stage,artifacts_size,status,error\n" +
+			"7,group/project,,10,build,main,build,1048576,deleted,\n" +
+			"7,group/project,,11,test,main,test,512,failed,403 | Forbidden\n" +
+			"8,group/other,,,,,,,,\n" +
+			"9,group/excluded,excluded,,,,,,,\n"
+		testutils.Equal(t, expected, buf.String())
+	})
+
+	t.Run("success_json", func(t *testing.T) {
+		// Arrange
+		var buf strings.Builder
+
+		// Act
+		err := artifacts.WriteReport(&buf, artifacts.ReportJSON, summary)
+
+		// Assert
+		testutils.NoError(testutils.Require(t), err)
+		report := buf.String()
+		testutils.Contains(t, report, `"bytes_cleaned": 1048576`)
+		testutils.Contains(t, report, `"jobs_failed": 1`)
+		testutils.Contains(t, report, `"path": "group/other"`)
+		testutils.Contains(t, report, `"status": "failed"`)
+		testutils.Contains(t, report, `"error": "403 | Forbidden"`)
+		testutils.Contains(t, report, `"skipped": "excluded"`)
+	})
+
+	t.Run("success_json_empty", func(t *testing.T) {
+		// Arrange
+		var buf strings.Builder
+
+		// Act
+		err := artifacts.WriteReport(&buf, artifacts.ReportJSON, artifacts.Summary{})
+
+		// Assert
+		testutils.NoError(testutils.Require(t), err)
+		testutils.Contains(t, buf.String(), `"projects": []`)
+	})
+
+	t.Run("success_markdown", func(t *testing.T) {
+		// Arrange
+		var buf strings.Builder
+
+		// Act
+		err := artifacts.WriteReport(&buf, artifacts.ReportMarkdown, summary)
+
+		// Assert
+		testutils.NoError(testutils.Require(t), err)
+		report := buf.String()
+		testutils.Contains(t, report, "1 job(s) cleaned for 1.0MiB in 2 project(s).")
+		testutils.Contains(t, report, "**1 failure(s) occurred during the run, including 1 failed job(s) artifacts deletion.**")
+		testutils.Contains(t, report, "| group/project | 1 | 1 | 1.0MiB |")
+		testutils.Contains(t, report, "| group/other | 0 | 0 | 0B |")
+		testutils.NotContains(t, report, "| group/excluded | 0 | 0 | 0B |")
+		testutils.Contains(t, report, "| group/excluded | excluded |")
+		testutils.Contains(t, report, "| group/project | test (11) | main | test | 512B | failed | 403 \\| Forbidden |")
+	})
+}
@@ -7,35 +7,71 @@ import (
 	"github.com/fogfactory/pipe"
 	"github.com/panjf2000/ants/v2"
 	gitlab "gitlab.com/gitlab-org/api/client-go/v2"
+	"go.opentelemetry.io/otel/attribute"
 
 	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/artifacts/engine"
+	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/artifacts/models"
+	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/tracing"
 )
 
 // Run retrieves gitlab projects and filters the one not appropriate with options (paths regexps).
 //
 // For every appropriate project, it will retrieve jobs and delete outdated artifacts according to input option threshold.
-func Run(parent context.Context, client *gitlab.Client, opts ...engine.RunOption) error {
+// It returns the Summary of all processed projects (e.g. number of cleaned jobs and bytes freed)
+// along with all errors which occurred during the run (e.g. failed artifacts deletions), joined together.
+// Those errors don't stop the run, as such the Summary is still relevant when an error is returned.
+//
+// When input context is canceled, no new project nor deletion is started and in-flight deletions
+// are given run options grace period to finish. The partial Summary is then returned with an interruption error.
+//
+// When a state file is provided in run options, the run progress is recorded into it and can be resumed by another run.
+func Run(parent context.Context, client *gitlab.Client, opts ...engine.RunOption) (Summary, error) {
 	ro, err := engine.NewRunOptions(opts...)
 	if err != nil {
-		return fmt.Errorf("new run options: %w", err)
+		return Summary{}, fmt.Errorf("new run options: %w", err)
+	}
+	checkpoint, err := ro.OpenCheckpoint()
+	if err != nil {
+		return Summary{}, fmt.Errorf("checkpoint initialization: %w", err)
 	}
-	ctx := ro.Context(parent)
 
-	pools, err := pipe.NewPoolsWithOptions([]int{10, 1000}, ants.WithLogger(engine.GetLogger(ctx)))
+	failures := &engine.Failures{}
+	ctx := context.WithValue(ro.Context(parent), engine.FailuresKey, failures)
+	ctx = context.WithValue(ctx, engine.CheckpointKey, checkpoint)
+
+	ctx, span := tracing.Start(ctx, "artifacts.run")
+	defer span.End()
+
+	pools, err := pipe.NewPoolsWithOptions(ro.PoolSizes(), ants.WithLogger(engine.GetLogger(ctx)))
 	if err != nil {
-		return fmt.Errorf("pools initialization: %w", err)
+		return Summary{}, fmt.Errorf("pools initialization: %w", err)
 	}
 	defer pools.Release()
 
-	piping := NewPipeProjectBuilder().
+	totals := &summarizer{report: ro.Report}
+	ctx = context.WithValue(ctx, summarizerKey, totals)
+	piping := NewPipeProjectBuilder[models.Job]().
+		Concurrency(ro.JobConcurrency).
 		Processor(StartProject(ctx)).
 		Split(ReadJobs(ctx, client, ro)).
 		Processor(DeleteArtifacts(ctx, client, ro)).
-		Merge(ObserveCleanup).
+		Merge(ObserveCleanup(ro)).
+		Processor(CompleteProject(ctx)).
 		Processor(StopProject(ctx)).
+		Processor(totals.Observe).
 		Build()
 
 	projects := ReadProjects(ctx, client, ro)
 	pipe.Run(pools, projects, piping)
-	return nil
+	failures.Add(engine.Interrupted(ctx))
+	failures.Add(checkpoint.Close())
+
+	summary := totals.summary
+	summary.Failures = failures.Len()
+	span.SetAttributes(
+		attribute.Int64("bytes_cleaned", summary.BytesCleaned),
+		attribute.Int64("jobs_cleaned", int64(summary.JobsCleaned)),
+		attribute.Int64("projects", int64(summary.Projects)))
+	tracing.SetError(span, failures.Err())
+	return summary, failures.Err()
 }
@@ -1,8 +1,10 @@
 package artifacts_test
 
 import (
+	"context"
 	"fmt"
 	"net/http"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
@@ -17,9 +19,13 @@ import (
 )
 
 const (
-	projectsURL  = "https://gitlab.com/api/v4/projects"
-	jobsURL      = "https://gitlab.com/api/v4/projects/%d/jobs"
-	artifactsURL = "https://gitlab.com/api/v4/projects/%d/jobs/%d/artifacts"
+	projectsURL      = "https://gitlab.com/api/v4/projects"
+	groupProjectsURL = "https://gitlab.com/api/v4/groups/%s/projects"
+	jobsURL          = "https://gitlab.com/api/v4/projects/%d/jobs"
+	artifactsURL     = "https://gitlab.com/api/v4/projects/%d/jobs/%d/artifacts"
+
+	protectedBranchesURL = "https://gitlab.com/api/v4/projects/%d/protected_branches"
+	protectedTagsURL     = "https://gitlab.com/api/v4/projects/%d/protected_tags"
 )
 
 func TestRun(t *testing.T) {
@@ -55,15 +61,18 @@ func TestRun(t *testing.T) {
 				{ID: 8, PathWithNamespace: "not_matching"},
 			}).Then(httpmock.NewJsonResponderOrPanic(http.StatusOK, []*gitlab.Project{})))
 
+		// protected branches and tags endpoints mocks
+		registerProtectedRefs(projectID, nil, nil)
+
 		// jobs endpoint mock
 		jobID := int64(10)
 		httpmock.RegisterResponder(http.MethodGet, fmt.Sprintf(jobsURL, projectID),
 			httpmock.NewJsonResponderOrPanic(http.StatusOK, []*gitlab.Job{
 				{
 					ID:                jobID,
-					Artifacts:         []gitlab.JobArtifact{{}},          // one artifact
-					ArtifactsExpireAt: lo.ToPtr(now.Add(time.Hour)),      // artifacts not expired
-					CreatedAt:         lo.ToPtr(now.Add(-2 * time.Hour)), // job is old
+					Artifacts:         []gitlab.JobArtifact{{Size: 1024}}, // one artifact
+					ArtifactsExpireAt: lo.ToPtr(now.Add(time.Hour)),       // artifacts not expired
+					CreatedAt:         lo.ToPtr(now.Add(-2 * time.Hour)),  // job is old
 				},
 				{
 					ID:                18,
@@ -89,10 +98,23 @@ func TestRun(t *testing.T) {
 		}
 
 		// Act
-		err := artifacts.Run(ctx, client, opts...)
+		summary, err := artifacts.Run(ctx, client, append(opts, engine.WithReport(true))...)
 
 		// Assert
 		testutils.NoError(testutils.Require(t), err)
+		testutils.Equal(t, 1024, summary.BytesCleaned)
+		testutils.Equal(t, 1, summary.JobsCleaned)
+		testutils.Equal(t, 1, summary.Projects)
+		testutils.Equal(t, 2, len(summary.Reports)) // skipped projects are reported too
+		processed, ok := lo.Find(summary.Reports, func(report artifacts.ProjectReport) bool { return report.ID == projectID })
+		testutils.True(testutils.Require(t), ok)
+		testutils.Equal(t, "", processed.Skipped)
+		testutils.Equal(t, 1, len(processed.Jobs))
+		testutils.Equal(t, artifacts.JobReport{ArtifactsSize: 1024, ID: jobID, Status: artifacts.JobDeleted}, processed.Jobs[0])
+		skipped, ok := lo.Find(summary.Reports, func(report artifacts.ProjectReport) bool { return report.ID == 8 })
+		testutils.True(testutils.Require(t), ok)
+		testutils.Equal(t, "not_matching", skipped.Path)
+		testutils.Equal(t, "no matching path", skipped.Skipped)
 		for k, v := range expectedCalls {
 			actual, ok := httpmock.GetCallCountInfo()[k]
 			testutils.True(t, ok)
@@ -101,8 +123,95 @@ func TestRun(t *testing.T) {
 		logs := buf.String()
 		testutils.Contains(t, logs, "starting project execution")
 		testutils.Contains(t, logs, "ending project execution")
+		testutils.Contains(t, logs, "bytes_cleaned=1024")
 		testutils.NotContains(t, logs, "failed to retrieve projects")
 		testutils.NotContains(t, logs, "failed to retrieve project jobs")
 		testutils.NotContains(t, logs, "failed to delete job's artifacts")
 	})
+	t.Run("error_failures", func(t *testing.T) {
+		// Arrange
+		t.Cleanup(httpmock.Reset)
+
+		// projects endpoint mock
+		projectID := int64(7)
+		httpmock.RegisterResponder(http.MethodGet, projectsURL,
+			httpmock.NewJsonResponderOrPanic(http.StatusOK, []*gitlab.Project{{ID: projectID, PathWithNamespace: "project_path"}}).
+				Then(httpmock.NewJsonResponderOrPanic(http.StatusOK, []*gitlab.Project{})))
+
+		// protected branches and tags endpoints mocks
+		registerProtectedRefs(projectID, nil, nil)
+
+		// jobs endpoint mock
+		jobID := int64(10)
+		httpmock.RegisterResponder(http.MethodGet, fmt.Sprintf(jobsURL, projectID),
+			httpmock.NewJsonResponderOrPanic(http.StatusOK, []*gitlab.Job{
+				{
+					ID:        jobID,
+					Artifacts: []gitlab.JobArtifact{{Size: 1024}},
+					CreatedAt: lo.ToPtr(now.Add(-2 * time.Hour)),
+				},
+			}).Then(httpmock.NewJsonResponderOrPanic(http.StatusOK, []*gitlab.Job{})))
+
+		// job deletion endpoint mock
+		httpmock.RegisterResponder(http.MethodDelete, fmt.Sprintf(artifactsURL, projectID, jobID),
+			httpmock.NewStringResponder(http.StatusForbidden, `{"message":"403 Forbidden"}`))
+
+		// Act
+		summary, err := artifacts.Run(ctx, client, opts...)
+
+		// Assert
+		testutils.Error(testutils.Require(t), err)
+		testutils.Contains(t, err.Error(), "1 failure(s) during run")
+		testutils.Contains(t, err.Error(), "project 7: delete job 10 artifacts")
+		testutils.Equal(t, 1, summary.Failures)
+		testutils.Equal(t, 0, summary.JobsCleaned)
+		testutils.Equal(t, 1, summary.JobsFailed)
+		testutils.Equal(t, 1, summary.Projects)
+		testutils.Equal(t, 0, len(summary.Reports)) // no report enabled
+	})
+
+	t.Run("success_resume", func(t *testing.T) {
+		// Arrange
+		t.Cleanup(httpmock.Reset)
+
+		projectID := int64(7)
+		httpmock.RegisterResponder(http.MethodGet, projectsURL,
+			httpmock.NewJsonResponderOrPanic(http.StatusOK, []*gitlab.Project{{ID: projectID, PathWithNamespace: "project_path"}}).
+				Then(httpmock.NewJsonResponderOrPanic(http.StatusOK, []*gitlab.Project{})).
+				Then(httpmock.NewJsonResponderOrPanic(http.StatusOK, []*gitlab.Project{{ID: projectID, PathWithNamespace: "project_path"}})).
+				Then(httpmock.NewJsonResponderOrPanic(http.StatusOK, []*gitlab.Project{})))
+		registerProtectedRefs(projectID, nil, nil)
+		httpmock.RegisterResponder(http.MethodGet, fmt.Sprintf(jobsURL, projectID),
+			httpmock.NewJsonResponderOrPanic(http.StatusOK, []*gitlab.Job{}))
+
+		stateFile := filepath.Join(t.TempDir(), "state.json")
+		summary, err := artifacts.Run(ctx, client, append(opts, engine.WithStateFile(stateFile))...)
+		testutils.NoError(testutils.Require(t), err)
+		testutils.Equal(testutils.Require(t), 1, summary.Projects)
+
+		// Act
+		summary, err = artifacts.Run(ctx, client, append(opts, engine.WithStateFile(stateFile), engine.WithResume(true))...)
+
+		// Assert
+		testutils.NoError(testutils.Require(t), err)
+		testutils.Equal(t, 0, summary.Projects) // completed in previous run
+		testutils.Contains(t, buf.String(), "completed in a previous run")
+	})
+
+	t.Run("error_interrupted", func(t *testing.T) {
+		// Arrange
+		t.Cleanup(httpmock.Reset)
+		ctx, cancel := context.WithCancel(ctx)
+		cancel()
+
+		// Act
+		summary, err := artifacts.Run(ctx, client, opts...)
+
+		// Assert
+		testutils.ErrorIs(testutils.Require(t), err, context.Canceled)
+		testutils.Contains(t, err.Error(), "run interrupted")
+		testutils.Equal(t, 0, summary.Projects)
+		testutils.Equal(t, 1, summary.Failures)
+		testutils.Equal(t, 0, httpmock.GetTotalCallCount())
+	})
 }
@@ -1,11 +1,20 @@
 package artifacts
 
 import (
+	"cmp"
 	"context"
+	"slices"
+	"strings"
+	"sync"
 	"time"
 
+	gitlab "gitlab.com/gitlab-org/api/client-go/v2"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
 	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/artifacts/engine"
 	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/artifacts/models"
+	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/tracing"
 )
 
 // Project represents a models.Project
@@ -14,14 +23,37 @@ import (
 type Project struct {
 	models.Project
 
+	// ArtifactTypes is the list of distinct file types of cleaned jobs' artifacts.
+	ArtifactTypes []string
+
+	// BytesCleaned is the size in bytes of cleaned jobs' artifacts.
+	BytesCleaned int64
+
+	// ItemsCleaned is the number of cleaned items with other cleaning commands (e.g. registry tags), see RunCleaner.
+	ItemsCleaned int
+
+	// Jobs is the cleanup outcome of every job matched for artifacts deletion,
+	// only kept when a report is enabled in run options (see engine.WithReport).
+	Jobs []JobReport
+
+	// JobsFailed is the number of jobs whose artifacts deletion failed.
+	JobsFailed int
+
 	executionStart    time.Time
 	executionDuration time.Duration
+
+	// slots limits the number of children processed concurrently, see PipeProjectBuilder.Concurrency
+	slots chan struct{}
+
+	// span traces project execution from StartProject to StopProject
+	span trace.Span
 }
 
 // StartProject starts the timer for Project execution and logs the Project start execution.
 func StartProject(ctx context.Context) func(Project) Project {
 	return func(p Project) Project {
 		p.executionStart = time.Now()
+		_, p.span = tracing.Start(ctx, "project", attribute.Int64("project.id", p.ID), attribute.String("project.path", p.PathWithNamespace))
 		engine.GetLogger(ctx).Info("starting project execution",
 			"project_id", p.ID,
 			"project_path", p.PathWithNamespace)
@@ -31,13 +63,227 @@ func StartProject(ctx context.Context) func(Project) Project {
 
 // StopProject stops the project timer execution and logs the Project execution result.
 func StopProject(ctx context.Context) func(Project) Project {
+	return StopProjectWith(ctx, func(p Project) []any {
+		return []any{
+			"artifact_types", strings.Join(p.ArtifactTypes, ","),
+			"bytes_cleaned", p.BytesCleaned,
+			"jobs_cleaned", p.JobsCleaned,
+			"jobs_failed", p.JobsFailed,
+		}
+	})
+}
+
+// CompleteProject records the Project as completed in context checkpoint
+// when all its jobs were processed without failure and the run wasn't canceled.
+// Nothing is recorded in dry run mode since the checkpoint is then read-only.
+func CompleteProject(ctx context.Context) func(Project) Project {
+	return func(p Project) Project {
+		if ctx.Err() == nil && p.JobsFailed == 0 {
+			engine.GetCheckpoint(ctx).Complete(p.ID)
+		}
+		return p
+	}
+}
+
+// StopProjectWith stops the project timer execution and logs the Project execution result
+// with the additional key values returned by result function (e.g. number of cleaned items).
+func StopProjectWith(ctx context.Context, result func(Project) []any) func(Project) Project {
 	return func(p Project) Project {
 		p.executionDuration = time.Since(p.executionStart)
-		engine.GetLogger(ctx).Info("ending project execution",
-			"execution_duration", p.executionDuration,
-			"jobs_cleaned", p.JobsCleaned,
+
+		keyvals := []any{"execution_duration", p.executionDuration}
+		keyvals = append(keyvals, result(p)...)
+		keyvals = append(keyvals,
 			"project_id", p.ID,
 			"project_path", p.PathWithNamespace)
+		engine.GetLogger(ctx).Info("ending project execution", keyvals...)
+		if p.span != nil {
+			p.span.End()
+		}
 		return p
 	}
 }
+
+// Summary represents the totals of a Run over all its projects.
+type Summary struct {
+	// BytesCleaned is the size in bytes of all cleaned jobs' artifacts.
+	BytesCleaned int64
+
+	// Failures is the number of errors which occurred during the run (e.g. projects or jobs listing, artifacts deletion).
+	Failures int
+
+	// ItemsCleaned is the number of cleaned items with other cleaning commands (e.g. registry tags), see RunCleaner.
+	ItemsCleaned int
+
+	// JobsCleaned is the number of jobs whose artifacts were cleaned.
+	JobsCleaned int
+
+	// JobsFailed is the number of jobs whose artifacts deletion failed.
+	JobsFailed int
+
+	// Projects is the number of projects processed.
+	Projects int
+
+	// Reports is the cleanup outcome of every processed project and the skip reason of every skipped one,
+	// only kept when a report is enabled in run options (see engine.WithReport).
+	Reports []ProjectReport
+}
+
+// Add returns the sum of the summary and the input one (e.g. to sum multiple runs totals).
+func (s Summary) Add(other Summary) Summary {
+	return Summary{
+		BytesCleaned: s.BytesCleaned + other.BytesCleaned,
+		Failures:     s.Failures + other.Failures,
+		ItemsCleaned: s.ItemsCleaned + other.ItemsCleaned,
+		JobsCleaned:  s.JobsCleaned + other.JobsCleaned,
+		JobsFailed:   s.JobsFailed + other.JobsFailed,
+		Projects:     s.Projects + other.Projects,
+		Reports:      append(slices.Clone(s.Reports), other.Reports...),
+	}
+}
+
+// summarizer sums projects results into a Summary, projects being processed concurrently.
+type summarizer struct {
+	mutex   sync.Mutex
+	report  bool
+	summary Summary
+}
+
+// Observe adds the input project results to the summary.
+func (s *summarizer) Observe(p Project) Project {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.summary.BytesCleaned += p.BytesCleaned
+	s.summary.ItemsCleaned += p.ItemsCleaned
+	s.summary.JobsCleaned += p.JobsCleaned
+	s.summary.JobsFailed += p.JobsFailed
+	s.summary.Projects++
+	if s.report {
+		s.summary.Reports = append(s.summary.Reports, ProjectReportFrom(p))
+	}
+	return p
+}
+
+// Skip adds the input project skipped with the input reason to the summary reports.
+//
+// It's a no-op on nil summarizer or when reports aren't kept.
+func (s *summarizer) Skip(project models.Project, reason string) {
+	if s == nil || !s.report {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.summary.Reports = append(s.summary.Reports, ProjectReport{
+		ID:      project.ID,
+		Jobs:    []JobReport{},
+		Path:    project.PathWithNamespace,
+		Skipped: reason,
+	})
+}
+
+type summarizerKeyType string
+
+// summarizerKey is the context key for run summarizer, to give it projects skipped while reading them.
+const summarizerKey summarizerKeyType = "summarizer"
+
+// getSummarizer returns the context summarizer.
+//
+// It returns nil when the context has no summarizer, in which case skipped projects aren't reported.
+func getSummarizer(ctx context.Context) *summarizer {
+	totals, _ := ctx.Value(summarizerKey).(*summarizer)
+	return totals
+}
+
+// latestJobs keeps track of latest successful jobs (or pipelines) according to an engine.KeepLatestBy.
+type latestJobs struct {
+	mode engine.KeepLatestBy
+
+	// kept is the identifier of the kept pipeline (with 'ref' mode) or kept job (with 'ref-name' mode) of each group
+	kept map[string]int64
+}
+
+// newLatestJobs creates a new latestJobs tracker for the input mode.
+func newLatestJobs(mode engine.KeepLatestBy) *latestJobs {
+	return &latestJobs{mode: mode, kept: map[string]int64{}}
+}
+
+// Keep returns truthy if the input job is one of the latest successful ones and its artifacts must be kept.
+//
+// Jobs must be given from the most recent to the oldest one since the first successful
+// job (or pipeline) of each group is considered as the latest one.
+func (l *latestJobs) Keep(job models.Job) bool {
+	var key, status string
+	var id int64
+	switch l.mode {
+	case engine.KeepLatestRef:
+		key, status, id = job.Ref, job.PipelineStatus, job.PipelineID
+	case engine.KeepLatestRefName:
+		key, status, id = job.Ref+"/"+job.Name, job.Status, job.ID
+	default:
+		return false
+	}
+
+	if kept, ok := l.kept[key]; ok {
+		return kept == id
+	}
+	if status != string(gitlab.Success) {
+		return false
+	}
+	l.kept[key] = id
+	return true
+}
+
+// storageBudget keeps track of a project jobs' artifacts size and candidates for cleanup
+// to only clean the largest ones until the project fits in the budget.
+type storageBudget struct {
+	budget     int64
+	candidates []models.Job
+
+	// size is the size in bytes of all (not expired) project jobs' artifacts
+	size int64
+}
+
+// newStorageBudget creates a new storageBudget for the input max size, nil is returned when there's no max size.
+func newStorageBudget(maxSize engine.Size) *storageBudget {
+	if maxSize == 0 {
+		return nil
+	}
+	return &storageBudget{budget: int64(maxSize)}
+}
+
+// Add adds the input job artifacts size to the project size (when not expired).
+//
+// It's a no-op on a nil storageBudget.
+func (b *storageBudget) Add(job models.Job) {
+	if b == nil || job.ArtifactsExpired() {
+		return
+	}
+	b.size += job.ArtifactsSize()
+}
+
+// Candidate adds the input job to the candidates for cleanup.
+func (b *storageBudget) Candidate(job models.Job) {
+	b.candidates = append(b.candidates, job)
+}
+
+// Exceeding returns the candidates jobs to clean for the project to fit in the budget,
+// the largest (and then oldest) jobs' artifacts first.
+//
+// The budget may not be met if candidates jobs' artifacts aren't large enough.
+func (b *storageBudget) Exceeding() []models.Job {
+	slices.SortStableFunc(b.candidates, func(x, y models.Job) int {
+		return cmp.Or(cmp.Compare(y.ArtifactsSize(), x.ArtifactsSize()), x.CreatedAt.Compare(y.CreatedAt))
+	})
+
+	var jobs []models.Job
+	size := b.size
+	for _, job := range b.candidates {
+		if size <= b.budget {
+			break
+		}
+		size -= job.ArtifactsSize()
+		jobs = append(jobs, job)
+	}
+	return jobs
+}
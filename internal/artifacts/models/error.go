@@ -0,0 +1,27 @@
+package models
+
+import (
+	"io"
+
+	gitlab "gitlab.com/gitlab-org/api/client-go/v2"
+)
+
+// StatusError is the error returned when a gitlab api call answered with an unexpected (not 2xx) http status code.
+type StatusError struct {
+	Body       string
+	Operation  string
+	StatusCode int
+}
+
+var _ error = (*StatusError)(nil) // ensure interface is implemented
+
+// Error returns the failed operation with gitlab response body.
+func (e *StatusError) Error() string {
+	return e.Operation + ": " + e.Body
+}
+
+// statusError returns the StatusError associated to input operation and gitlab response, reading its body.
+func statusError(operation string, response *gitlab.Response) *StatusError {
+	body, _ := io.ReadAll(response.Body)
+	return &StatusError{Body: string(body), Operation: operation, StatusCode: response.StatusCode}
+}
@@ -3,7 +3,8 @@ package models
 import (
 	"context"
 	"fmt"
-	"io"
+	"regexp"
+	"slices"
 	"time"
 
 	"github.com/samber/lo"
@@ -12,69 +13,196 @@ import (
 
 // Job is a simplified view of a gitlab job with only useful information for artifacts deletion feature.
 type Job struct {
-	ArtifactsCount    int
+	Artifacts         []Artifact
 	ArtifactsExpireAt time.Time
 	Cleaned           bool
 	CreatedAt         time.Time
+	Error             error
 	ID                int64
+	Interrupted       bool
+	Name              string
+	PipelineID        int64
+	PipelineStatus    string
 	ProjectID         int64
+	Protected         bool
+	Ref               string
+	Stage             string
+	Status            string
+	Tag               bool
+}
+
+// JobFilter represents include and exclude regexps on jobs name, stage and ref.
+//
+// A zero value filter matches all jobs.
+type JobFilter struct {
+	ExcludeNames  []*regexp.Regexp
+	ExcludeRefs   []*regexp.Regexp
+	ExcludeStages []*regexp.Regexp
+	Names         []*regexp.Regexp
+	Refs          []*regexp.Regexp
+	Stages        []*regexp.Regexp
+}
+
+// Matches returns truthy if the job matches the filter.
+//
+// For each of name, stage and ref, the job must match one of the include regexps (when there're some)
+// and must not match any of the exclude regexps.
+func (f JobFilter) Matches(job Job) bool {
+	return matches(job.Name, f.Names, f.ExcludeNames) &&
+		matches(job.Stage, f.Stages, f.ExcludeStages) &&
+		matches(job.Ref, f.Refs, f.ExcludeRefs)
+}
+
+// matches returns truthy if value matches one of includes regexps (or there're none) and none of excludes regexps.
+func matches(value string, includes, excludes []*regexp.Regexp) bool {
+	for _, r := range excludes {
+		if r.MatchString(value) {
+			return false
+		}
+	}
+	if len(includes) == 0 {
+		return true
+	}
+	for _, r := range includes {
+		if r.MatchString(value) {
+			return true
+		}
+	}
+	return false
 }
 
 // Artifact represents a simplified view of a gitlab artifact.
-type Artifact struct{}
+type Artifact struct {
+	FileType string
+	Filename string
+	Size     int64
+}
+
+// ArtifactTypes returns the distinct file types (e.g. 'archive', 'junit') of the job artifacts.
+func (j Job) ArtifactTypes() []string {
+	types := make([]string, 0, len(j.Artifacts))
+	for _, artifact := range j.Artifacts {
+		if !slices.Contains(types, artifact.FileType) {
+			types = append(types, artifact.FileType)
+		}
+	}
+	return types
+}
+
+// ArtifactsSize returns the size in bytes of the job artifacts, 'trace' artifacts excluded
+// since they're never deleted alongside other artifacts.
+func (j Job) ArtifactsSize() int64 {
+	var size int64
+	for _, artifact := range j.Artifacts {
+		if artifact.FileType != "trace" {
+			size += artifact.Size
+		}
+	}
+	return size
+}
+
+// ArtifactsExpired returns truthy if the job artifacts expiration date is defined and already passed,
+// meaning they were already (or will soon be) deleted by GitLab.
+func (j Job) ArtifactsExpired() bool {
+	return !j.ArtifactsExpireAt.IsZero() && j.ArtifactsExpireAt.Before(time.Now())
+}
+
+// MatchesArtifactTypes returns truthy if all the job artifacts have one of input file types,
+// all artifacts matching when no type is provided.
+//
+// GitLab can't delete job artifacts by type, as such a single artifact with another type keeps all of them.
+// 'trace' artifacts are ignored since they're never deleted alongside other artifacts
+// and 'metadata' artifacts are considered part of 'archive' ones.
+func (j Job) MatchesArtifactTypes(types ...string) bool {
+	if len(types) == 0 {
+		return true
+	}
+	for _, artifact := range j.Artifacts {
+		switch {
+		case artifact.FileType == "trace":
+		case artifact.FileType == "metadata" && slices.Contains(types, "archive"):
+		case !slices.Contains(types, artifact.FileType):
+			return false
+		}
+	}
+	return true
+}
 
 // NeedCleanup returns truthy if the job needs to be cleaned up.
 //
 // It returns true if (all conditions are met):
 //   - the job has artifacts
+//   - the job matches the filter (name, stage and ref)
 //   - the job creation date is undefined or before now minus the threshold
 //   - the job artifacts expiration date is defined and after now
 //
 // It returns false if (any condition is met):
 //   - the job has no artifacts
+//   - the job doesn't match the filter
 //   - the job creation date is defined and after now minus the threshold
 //   - the job artifacts expiration date is already passed
-func (j Job) NeedCleanup(threshold time.Duration) bool {
+func (j Job) NeedCleanup(threshold time.Duration, filter JobFilter) bool {
 	// don't clean job not having artifacts
-	if j.ArtifactsCount == 0 {
+	if len(j.Artifacts) == 0 {
+		return false
+	}
+
+	// don't clean job filtered out by name, stage or ref
+	if !filter.Matches(j) {
 		return false
 	}
-	now := time.Now()
 
 	// already cleaned up by GitLab
-	expired := !j.ArtifactsExpireAt.IsZero() && j.ArtifactsExpireAt.Before(now)
+	if j.ArtifactsExpired() {
+		return false
+	}
 
 	// creation issue or before threshold
-	old := j.CreatedAt.IsZero() || j.CreatedAt.Before(now.Add(-threshold))
-	return old && !expired
+	return j.CreatedAt.IsZero() || j.CreatedAt.Before(time.Now().Add(-threshold))
 }
 
 // DeleteArtifacts deletes the artifacts of the job.
 //
-// It returns an error if the deletion failed.
-func (j Job) DeleteArtifacts(ctx context.Context, client *gitlab.Client) error {
+// It returns an error if the deletion failed, alongside gitlab response when one was received.
+func (j Job) DeleteArtifacts(ctx context.Context, client *gitlab.Client) (*gitlab.Response, error) {
 	// call jobs artifacts deletion
 	response, err := client.Jobs.DeleteArtifacts(j.ProjectID, j.ID, gitlab.WithContext(ctx))
 	if err != nil {
-		return fmt.Errorf("delete artifacts: %w", err)
+		return response, fmt.Errorf("delete artifacts: %w", err)
 	}
 	defer response.Body.Close()
 
 	// handle http errors
 	if response.StatusCode/100 != 2 {
-		body, _ := io.ReadAll(response.Body)
-		return fmt.Errorf("delete artifacts: %s", body)
+		return response, statusError("delete artifacts", response)
 	}
-	return nil
+
+	return response, nil
 }
 
 // JobFromGitLab converts a GitLab job to its simplified view.
 func JobFromGitLab(projectID int64, job *gitlab.Job) Job {
 	return Job{
-		ArtifactsCount:    len(job.Artifacts),
+		Artifacts:         lo.Map(job.Artifacts, func(artifact gitlab.JobArtifact, _ int) Artifact { return ArtifactFromGitLab(artifact) }),
 		ArtifactsExpireAt: lo.FromPtr(job.ArtifactsExpireAt),
 		CreatedAt:         lo.FromPtr(job.CreatedAt),
 		ID:                job.ID,
+		Name:              job.Name,
+		PipelineID:        job.Pipeline.ID,
+		PipelineStatus:    job.Pipeline.Status,
 		ProjectID:         projectID,
+		Ref:               job.Ref,
+		Stage:             job.Stage,
+		Status:            job.Status,
+		Tag:               job.Tag,
+	}
+}
+
+// ArtifactFromGitLab converts a GitLab job artifact to its simplified view.
+func ArtifactFromGitLab(artifact gitlab.JobArtifact) Artifact {
+	return Artifact{
+		FileType: artifact.FileType,
+		Filename: artifact.Filename,
+		Size:     artifact.Size,
 	}
 }
@@ -1,8 +1,11 @@
 package models_test
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
+	"regexp"
+	"strings"
 	"testing"
 	"time"
 
@@ -20,7 +23,7 @@ func TestNeedCleanup(t *testing.T) {
 		job := models.Job{}
 
 		// Act
-		clean := job.NeedCleanup(0)
+		clean := job.NeedCleanup(0, models.JobFilter{})
 
 		// Assert
 		testutils.False(t, clean)
@@ -30,13 +33,13 @@ func TestNeedCleanup(t *testing.T) {
 		// Arrange
 		now := time.Now()
 		job := models.Job{
-			ArtifactsCount:    1,
+			Artifacts:         []models.Artifact{{FileType: "archive"}},
 			ArtifactsExpireAt: now.Add(-1 * time.Hour),
 			CreatedAt:         now.Add(-1 * time.Hour),
 		}
 
 		// Act
-		clean := job.NeedCleanup(0)
+		clean := job.NeedCleanup(0, models.JobFilter{})
 
 		// Assert
 		testutils.False(t, clean)
@@ -46,13 +49,25 @@ func TestNeedCleanup(t *testing.T) {
 		// Arrange
 		now := time.Now().Add(time.Hour)
 		job := models.Job{
-			ArtifactsCount:    1,
+			Artifacts:         []models.Artifact{{FileType: "archive"}},
 			ArtifactsExpireAt: now.Add(-1 * time.Minute),
 			CreatedAt:         now.Add(-5 * time.Minute),
 		}
 
 		// Act
-		clean := job.NeedCleanup(time.Hour)
+		clean := job.NeedCleanup(time.Hour, models.JobFilter{})
+
+		// Assert
+		testutils.False(t, clean)
+	})
+
+	t.Run("false_filtered_out", func(t *testing.T) {
+		// Arrange
+		job := models.Job{Artifacts: []models.Artifact{{FileType: "archive"}}, Name: "build:linux"}
+		filter := models.JobFilter{Names: []*regexp.Regexp{regexp.MustCompile("^test:")}}
+
+		// Act
+		clean := job.NeedCleanup(0, filter)
 
 		// Assert
 		testutils.False(t, clean)
@@ -60,16 +75,122 @@ func TestNeedCleanup(t *testing.T) {
 
 	t.Run("success_true_no_creation_date", func(t *testing.T) {
 		// Arrange
-		job := models.Job{ArtifactsCount: 1}
+		job := models.Job{Artifacts: []models.Artifact{{FileType: "archive"}}}
 
 		// Act
-		clean := job.NeedCleanup(0)
+		clean := job.NeedCleanup(0, models.JobFilter{})
 
 		// Assert
 		testutils.True(t, clean)
 	})
 }
 
+func TestJobFilter(t *testing.T) {
+	job := models.Job{Name: "test:coverage", Ref: "main", Stage: "test"}
+
+	t.Run("matches_empty_filter", func(t *testing.T) {
+		// Act
+		matches := models.JobFilter{}.Matches(job)
+
+		// Assert
+		testutils.True(t, matches)
+	})
+
+	t.Run("matches_includes", func(t *testing.T) {
+		// Arrange
+		filter := models.JobFilter{
+			Names:  []*regexp.Regexp{regexp.MustCompile("^build:"), regexp.MustCompile("^test:")},
+			Refs:   []*regexp.Regexp{regexp.MustCompile("^main$")},
+			Stages: []*regexp.Regexp{regexp.MustCompile("^test$")},
+		}
+
+		// Act
+		matches := filter.Matches(job)
+
+		// Assert
+		testutils.True(t, matches)
+	})
+
+	t.Run("no_matching_include", func(t *testing.T) {
+		// Arrange
+		filter := models.JobFilter{Stages: []*regexp.Regexp{regexp.MustCompile("^build$")}}
+
+		// Act
+		matches := filter.Matches(job)
+
+		// Assert
+		testutils.False(t, matches)
+	})
+
+	t.Run("exclude_wins", func(t *testing.T) {
+		// Arrange
+		filter := models.JobFilter{
+			ExcludeRefs: []*regexp.Regexp{regexp.MustCompile("^main$")},
+			Names:       []*regexp.Regexp{regexp.MustCompile("^test:")},
+		}
+
+		// Act
+		matches := filter.Matches(job)
+
+		// Assert
+		testutils.False(t, matches)
+	})
+}
+
+func TestMatchesArtifactTypes(t *testing.T) {
+	job := models.Job{Artifacts: []models.Artifact{{FileType: "archive"}, {FileType: "metadata"}, {FileType: "trace"}, {FileType: "junit"}}}
+
+	t.Run("matches_no_types", func(t *testing.T) {
+		// Act
+		matches := job.MatchesArtifactTypes()
+
+		// Assert
+		testutils.True(t, matches)
+	})
+
+	t.Run("matches_all_types", func(t *testing.T) {
+		// Act
+		matches := job.MatchesArtifactTypes("archive", "junit")
+
+		// Assert
+		testutils.True(t, matches) // trace and metadata artifacts are ignored
+	})
+
+	t.Run("no_matching_type", func(t *testing.T) {
+		// Act
+		matches := job.MatchesArtifactTypes("archive")
+
+		// Assert
+		testutils.False(t, matches)
+	})
+}
+
+func TestArtifactsSize(t *testing.T) {
+	t.Run("success_without_trace", func(t *testing.T) {
+		// Arrange
+		job := models.Job{Artifacts: []models.Artifact{{FileType: "archive", Size: 1024}, {FileType: "junit", Size: 256}, {FileType: "trace", Size: 512}}}
+
+		// Act
+		size := job.ArtifactsSize()
+
+		// Assert
+		testutils.Equal(t, 1280, size)
+	})
+}
+
+func TestArtifactTypes(t *testing.T) {
+	t.Run("success_distinct", func(t *testing.T) {
+		// Arrange
+		job := models.Job{Artifacts: []models.Artifact{{FileType: "archive"}, {FileType: "junit"}, {FileType: "junit"}}}
+
+		// Act
+		types := job.ArtifactTypes()
+
+		// Assert
+		testutils.Equal(t, "archive,junit", strings.Join(types, ","))
+	})
+}
+
 func TestDeleteArtifacts(t *testing.T) {
 	ctx := t.Context()
 
@@ -96,13 +217,29 @@ func TestDeleteArtifacts(t *testing.T) {
 			httpmock.NewStringResponder(http.StatusInternalServerError, "an error"))
 
 		// Act
-		err := job.DeleteArtifacts(ctx, client)
+		_, err := job.DeleteArtifacts(ctx, client)
 
 		// Assert
 		testutils.Error(testutils.Require(t), err)
 		testutils.Contains(t, err.Error(), "delete artifacts")
 	})
 
+	t.Run("error_unexpected_status", func(t *testing.T) {
+		// Arrange
+		t.Cleanup(httpmock.Reset)
+		httpmock.RegisterResponder(http.MethodDelete, url,
+			httpmock.NewStringResponder(http.StatusNotModified, ""))
+
+		// Act
+		_, err := job.DeleteArtifacts(ctx, client)
+
+		// Assert
+		var status *models.StatusError
+		testutils.True(testutils.Require(t), errors.As(err, &status))
+		testutils.Equal(t, http.StatusNotModified, status.StatusCode)
+		testutils.Contains(t, err.Error(), "delete artifacts")
+	})
+
 	t.Run("success_deletion", func(t *testing.T) {
 		// Arrange
 		t.Cleanup(httpmock.Reset)
@@ -110,7 +247,7 @@ func TestDeleteArtifacts(t *testing.T) {
 			httpmock.NewStringResponder(http.StatusNoContent, ""))
 
 		// Act
-		err := job.DeleteArtifacts(ctx, client)
+		_, err := job.DeleteArtifacts(ctx, client)
 
 		// Assert
 		testutils.NoError(testutils.Require(t), err)
@@ -127,20 +264,31 @@ func TestJobFromGitLab(t *testing.T) {
 			ID:                1,
 			CreatedAt:         lo.ToPtr(now),
 			ArtifactsExpireAt: lo.ToPtr(now.Add(time.Hour)),
-			Artifacts:         []gitlab.JobArtifact{{}},
-		}
-		expected := models.Job{
-			ArtifactsCount:    1,
-			ArtifactsExpireAt: now.Add(time.Hour),
-			CreatedAt:         now,
-			ID:                1,
-			ProjectID:         5,
+			Artifacts:         []gitlab.JobArtifact{{FileType: "archive", Filename: "artifacts.zip", Size: 1024}},
+			Name:              "build",
+			Pipeline:          gitlab.JobPipeline{ID: 3, Status: "success"},
+			Ref:               "main",
+			Stage:             "build",
+			Status:            "success",
+			Tag:               true,
 		}
 
 		// Act
-		project := models.JobFromGitLab(projectID, &gitlab)
+		job := models.JobFromGitLab(projectID, &gitlab)
 
 		// Assert
-		testutils.Equal(t, expected, project)
+		testutils.Equal(testutils.Require(t), 1, len(job.Artifacts))
+		testutils.Equal(t, models.Artifact{FileType: "archive", Filename: "artifacts.zip", Size: 1024}, job.Artifacts[0])
+		testutils.Equal(t, now.Add(time.Hour), job.ArtifactsExpireAt)
+		testutils.Equal(t, now, job.CreatedAt)
+		testutils.Equal(t, 1, job.ID)
+		testutils.Equal(t, "build", job.Name)
+		testutils.Equal(t, 3, job.PipelineID)
+		testutils.Equal(t, "success", job.PipelineStatus)
+		testutils.Equal(t, 5, job.ProjectID)
+		testutils.Equal(t, "main", job.Ref)
+		testutils.Equal(t, "build", job.Stage)
+		testutils.Equal(t, "success", job.Status)
+		testutils.True(t, job.Tag)
 	})
 }
@@ -0,0 +1,63 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/samber/lo"
+	gitlab "gitlab.com/gitlab-org/api/client-go/v2"
+)
+
+// Package is a simplified view of a gitlab package version with only useful information for packages deletion feature.
+type Package struct {
+	Cleaned     bool
+	CreatedAt   time.Time
+	ID          int64
+	Name        string
+	PackageType string
+	ProjectID   int64
+	Version     string
+}
+
+// Key returns the package identifier shared by all its versions (package type and name).
+func (p Package) Key() string {
+	return p.PackageType + "/" + p.Name
+}
+
+// NeedCleanup returns truthy if the package version needs to be cleaned up.
+//
+// It returns true if the package version creation date is undefined or before now minus the threshold.
+func (p Package) NeedCleanup(threshold time.Duration) bool {
+	return p.CreatedAt.IsZero() || p.CreatedAt.Before(time.Now().Add(-threshold))
+}
+
+// Delete deletes the package version (with all its files).
+//
+// It returns an error if the deletion failed, alongside gitlab response when one was received.
+func (p Package) Delete(ctx context.Context, client *gitlab.Client) (*gitlab.Response, error) {
+	// call package deletion
+	response, err := client.Packages.DeleteProjectPackage(p.ProjectID, p.ID, gitlab.WithContext(ctx))
+	if err != nil {
+		return response, fmt.Errorf("delete package: %w", err)
+	}
+	defer response.Body.Close()
+
+	// handle http errors
+	if response.StatusCode/100 != 2 {
+		return response, statusError("delete package", response)
+	}
+	return response, nil
+}
+
+// PackageFromGitLab converts a GitLab package to its simplified view.
+func PackageFromGitLab(projectID int64, pkg *gitlab.Package) Package {
+	return Package{
+		CreatedAt:   lo.FromPtr(pkg.CreatedAt),
+		ID:          pkg.ID,
+		Name:        pkg.Name,
+		PackageType: pkg.PackageType,
+		ProjectID:   projectID,
+		Version:     pkg.Version,
+	}
+}
@@ -0,0 +1,100 @@
+package models_test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/samber/lo"
+	gitlab "gitlab.com/gitlab-org/api/client-go/v2"
+
+	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/artifacts/models"
+	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/testutils"
+)
+
+func TestPackageNeedCleanup(t *testing.T) {
+	t.Run("false_too_recent", func(t *testing.T) {
+		// Arrange
+		pkg := models.Package{CreatedAt: time.Now().Add(-5 * time.Minute)}
+
+		// Act
+		clean := pkg.NeedCleanup(time.Hour)
+
+		// Assert
+		testutils.False(t, clean)
+	})
+
+	t.Run("success_true_old", func(t *testing.T) {
+		// Arrange
+		pkg := models.Package{CreatedAt: time.Now().Add(-2 * time.Hour)}
+
+		// Act
+		clean := pkg.NeedCleanup(time.Hour)
+
+		// Assert
+		testutils.True(t, clean)
+	})
+}
+
+func TestPackageDelete(t *testing.T) {
+	ctx := t.Context()
+
+	httpmock.Activate()
+	t.Cleanup(httpmock.DeactivateAndReset)
+
+	// setup mock client
+	client, err := gitlab.NewClient("",
+		gitlab.WithHTTPClient(&http.Client{Transport: httpmock.DefaultTransport}),
+		gitlab.WithoutRetries(),
+	)
+	testutils.NoError(testutils.Require(t), err)
+
+	pkg := models.Package{ID: 7, ProjectID: 5}
+	url := fmt.Sprintf("https://gitlab.com/api/v4/projects/%d/packages/%d", pkg.ProjectID, pkg.ID)
+
+	t.Run("error_delete_call", func(t *testing.T) {
+		// Arrange
+		t.Cleanup(httpmock.Reset)
+		httpmock.RegisterResponder(http.MethodDelete, url,
+			httpmock.NewStringResponder(http.StatusInternalServerError, "an error"))
+
+		// Act
+		_, err := pkg.Delete(ctx, client)
+
+		// Assert
+		testutils.Error(testutils.Require(t), err)
+		testutils.Contains(t, err.Error(), "delete package")
+	})
+
+	t.Run("success_deletion", func(t *testing.T) {
+		// Arrange
+		t.Cleanup(httpmock.Reset)
+		httpmock.RegisterResponder(http.MethodDelete, url,
+			httpmock.NewStringResponder(http.StatusNoContent, ""))
+
+		// Act
+		_, err := pkg.Delete(ctx, client)
+
+		// Assert
+		testutils.NoError(testutils.Require(t), err)
+		testutils.Equal(t, 1, httpmock.GetTotalCallCount())
+	})
+}
+
+func TestPackageFromGitLab(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		// Arrange
+		now := time.Now()
+		gitlab := gitlab.Package{ID: 1, Name: "app", PackageType: "npm", Version: "1.0.0", CreatedAt: lo.ToPtr(now)}
+		expected := models.Package{CreatedAt: now, ID: 1, Name: "app", PackageType: "npm", ProjectID: 5, Version: "1.0.0"}
+
+		// Act
+		pkg := models.PackageFromGitLab(5, &gitlab)
+
+		// Assert
+		testutils.Equal(t, expected, pkg)
+		testutils.Equal(t, "npm/app", pkg.Key())
+	})
+}
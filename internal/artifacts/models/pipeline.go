@@ -0,0 +1,54 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/samber/lo"
+	gitlab "gitlab.com/gitlab-org/api/client-go/v2"
+)
+
+// Pipeline is a simplified view of a gitlab pipeline with only useful information for pipelines deletion feature.
+type Pipeline struct {
+	Cleaned   bool
+	CreatedAt time.Time
+	ID        int64
+	ProjectID int64
+	Ref       string
+}
+
+// NeedCleanup returns truthy if the pipeline needs to be cleaned up.
+//
+// It returns true if the pipeline creation date is undefined or before now minus the threshold.
+func (p Pipeline) NeedCleanup(threshold time.Duration) bool {
+	return p.CreatedAt.IsZero() || p.CreatedAt.Before(time.Now().Add(-threshold))
+}
+
+// Delete deletes the pipeline with all its jobs, logs and artifacts.
+//
+// It returns an error if the deletion failed, alongside gitlab response when one was received.
+func (p Pipeline) Delete(ctx context.Context, client *gitlab.Client) (*gitlab.Response, error) {
+	// call pipeline deletion
+	response, err := client.Pipelines.DeletePipeline(p.ProjectID, p.ID, gitlab.WithContext(ctx))
+	if err != nil {
+		return response, fmt.Errorf("delete pipeline: %w", err)
+	}
+	defer response.Body.Close()
+
+	// handle http errors
+	if response.StatusCode/100 != 2 {
+		return response, statusError("delete pipeline", response)
+	}
+	return response, nil
+}
+
+// PipelineFromGitLab converts a GitLab pipeline to its simplified view.
+func PipelineFromGitLab(projectID int64, pipeline *gitlab.PipelineInfo) Pipeline {
+	return Pipeline{
+		CreatedAt: lo.FromPtr(pipeline.CreatedAt),
+		ID:        pipeline.ID,
+		ProjectID: projectID,
+		Ref:       pipeline.Ref,
+	}
+}
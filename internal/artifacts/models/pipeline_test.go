@@ -0,0 +1,99 @@
+package models_test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/samber/lo"
+	gitlab "gitlab.com/gitlab-org/api/client-go/v2"
+
+	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/artifacts/models"
+	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/testutils"
+)
+
+func TestPipelineNeedCleanup(t *testing.T) {
+	t.Run("false_too_recent", func(t *testing.T) {
+		// Arrange
+		pipeline := models.Pipeline{CreatedAt: time.Now().Add(-5 * time.Minute)}
+
+		// Act
+		clean := pipeline.NeedCleanup(time.Hour)
+
+		// Assert
+		testutils.False(t, clean)
+	})
+
+	t.Run("success_true_old", func(t *testing.T) {
+		// Arrange
+		pipeline := models.Pipeline{CreatedAt: time.Now().Add(-2 * time.Hour)}
+
+		// Act
+		clean := pipeline.NeedCleanup(time.Hour)
+
+		// Assert
+		testutils.True(t, clean)
+	})
+}
+
+func TestPipelineDelete(t *testing.T) {
+	ctx := t.Context()
+
+	httpmock.Activate()
+	t.Cleanup(httpmock.DeactivateAndReset)
+
+	// setup mock client
+	client, err := gitlab.NewClient("",
+		gitlab.WithHTTPClient(&http.Client{Transport: httpmock.DefaultTransport}),
+		gitlab.WithoutRetries(),
+	)
+	testutils.NoError(testutils.Require(t), err)
+
+	pipeline := models.Pipeline{ID: 7, ProjectID: 5}
+	url := fmt.Sprintf("https://gitlab.com/api/v4/projects/%d/pipelines/%d", pipeline.ProjectID, pipeline.ID)
+
+	t.Run("error_delete_call", func(t *testing.T) {
+		// Arrange
+		t.Cleanup(httpmock.Reset)
+		httpmock.RegisterResponder(http.MethodDelete, url,
+			httpmock.NewStringResponder(http.StatusInternalServerError, "an error"))
+
+		// Act
+		_, err := pipeline.Delete(ctx, client)
+
+		// Assert
+		testutils.Error(testutils.Require(t), err)
+		testutils.Contains(t, err.Error(), "delete pipeline")
+	})
+
+	t.Run("success_deletion", func(t *testing.T) {
+		// Arrange
+		t.Cleanup(httpmock.Reset)
+		httpmock.RegisterResponder(http.MethodDelete, url,
+			httpmock.NewStringResponder(http.StatusNoContent, ""))
+
+		// Act
+		_, err := pipeline.Delete(ctx, client)
+
+		// Assert
+		testutils.NoError(testutils.Require(t), err)
+		testutils.Equal(t, 1, httpmock.GetTotalCallCount())
+	})
+}
+
+func TestPipelineFromGitLab(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		// Arrange
+		now := time.Now()
+		gitlab := gitlab.PipelineInfo{ID: 1, Ref: "main", CreatedAt: lo.ToPtr(now)}
+		expected := models.Pipeline{CreatedAt: now, ID: 1, ProjectID: 5, Ref: "main"}
+
+		// Act
+		pipeline := models.PipelineFromGitLab(5, &gitlab)
+
+		// Assert
+		testutils.Equal(t, expected, pipeline)
+	})
+}
@@ -2,31 +2,120 @@ package models
 
 import (
 	"regexp"
+	"slices"
+	"time"
 
+	"github.com/samber/lo"
 	gitlab "gitlab.com/gitlab-org/api/client-go/v2"
 )
 
 // Project is a simplified view of a gitlab project with only useful information used during artifacts command.
 type Project struct {
+	Archived          bool
 	ID                int64
+	LastActivityAt    time.Time
 	PathWithNamespace string
+	Statistics        *Statistics
+	Topics            []string
+	Visibility        string
 	JobsCleaned       int
 }
 
-// Matches returns truthy if the project path matches any of the provided regexps.
-func (p Project) Matches(regexps ...*regexp.Regexp) bool {
+// Statistics is a simplified view of gitlab project storage statistics, all sizes being in bytes.
+//
+// Statistics are only filled when explicitly requested to gitlab api (e.g. for storage usage reporting).
+type Statistics struct {
+	ContainerRegistrySize int64
+	JobArtifactsSize      int64
+	LFSObjectsSize        int64
+	PackagesSize          int64
+	PipelineArtifactsSize int64
+	RepositorySize        int64
+	SnippetsSize          int64
+	StorageSize           int64
+	UploadsSize           int64
+	WikiSize              int64
+}
+
+// ProjectFilter represents conditions on projects metadata.
+//
+// A zero value filter matches all projects.
+type ProjectFilter struct {
+	// IdleDuration is the minimum duration elapsed since the project last activity.
+	IdleDuration time.Duration
+
+	// Topics is the list of topics the project must all have.
+	Topics []string
+
+	// Visibilities is the list of visibility levels the project must have one of.
+	Visibilities []string
+}
+
+// FirstMatch returns the first of the provided regexps matching the project path.
+//
+// It returns nil if none of them matches.
+func (p Project) FirstMatch(regexps ...*regexp.Regexp) *regexp.Regexp {
 	for _, r := range regexps {
 		if r.MatchString(p.PathWithNamespace) {
-			return true
+			return r
+		}
+	}
+	return nil
+}
+
+// Matches returns truthy if the project path matches any of the provided regexps.
+func (p Project) Matches(regexps ...*regexp.Regexp) bool {
+	return p.FirstMatch(regexps...) != nil
+}
+
+// MatchesFilter returns truthy if the project metadata matches all conditions of the provided filter.
+//
+// A project without last activity date is never considered idle.
+func (p Project) MatchesFilter(filter ProjectFilter) bool {
+	for _, topic := range filter.Topics {
+		if !slices.Contains(p.Topics, topic) {
+			return false
 		}
 	}
-	return false
+	if len(filter.Visibilities) > 0 && !slices.Contains(filter.Visibilities, p.Visibility) {
+		return false
+	}
+	if filter.IdleDuration > 0 {
+		return !p.LastActivityAt.IsZero() && p.LastActivityAt.Before(time.Now().Add(-filter.IdleDuration))
+	}
+	return true
 }
 
 // ProjectFromGitLab converts a GitLab project to its simplified view.
 func ProjectFromGitLab(project *gitlab.Project) Project {
 	return Project{
+		Archived:          project.Archived,
 		ID:                project.ID,
+		LastActivityAt:    lo.FromPtr(project.LastActivityAt),
 		PathWithNamespace: project.PathWithNamespace,
+		Statistics:        StatisticsFromGitLab(project.Statistics),
+		Topics:            project.Topics,
+		Visibility:        string(project.Visibility),
+	}
+}
+
+// StatisticsFromGitLab converts GitLab project statistics to their simplified view.
+//
+// Nil is returned when statistics are nil (not requested).
+func StatisticsFromGitLab(statistics *gitlab.Statistics) *Statistics {
+	if statistics == nil {
+		return nil
+	}
+	return &Statistics{
+		ContainerRegistrySize: statistics.ContainerRegistrySize,
+		JobArtifactsSize:      statistics.JobArtifactsSize,
+		LFSObjectsSize:        statistics.LFSObjectsSize,
+		PackagesSize:          statistics.PackagesSize,
+		PipelineArtifactsSize: statistics.PipelineArtifactsSize,
+		RepositorySize:        statistics.RepositorySize,
+		SnippetsSize:          statistics.SnippetsSize,
+		StorageSize:           statistics.StorageSize,
+		UploadsSize:           statistics.UploadsSize,
+		WikiSize:              statistics.WikiSize,
 	}
 }
@@ -3,6 +3,7 @@ package models_test
 import (
 	"regexp"
 	"testing"
+	"time"
 
 	gitlab "gitlab.com/gitlab-org/api/client-go/v2"
 
@@ -10,6 +11,36 @@ import (
 	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/testutils"
 )
 
+func TestFirstMatch(t *testing.T) {
+	t.Run("no_match", func(t *testing.T) {
+		// Arrange
+		project := models.Project{PathWithNamespace: "john.doe"}
+
+		// Act
+		match := project.FirstMatch(regexp.MustCompile("^hey_.*$"))
+
+		// Assert
+		testutils.True(t, match == nil)
+	})
+
+	t.Run("first_match", func(t *testing.T) {
+		// Arrange
+		project := models.Project{PathWithNamespace: "hey_john.doe"}
+		regexps := []*regexp.Regexp{
+			regexp.MustCompile("^hoï_.*$"),
+			regexp.MustCompile("^hey_.*$"),
+			regexp.MustCompile("^hey_john.*$"),
+		}
+
+		// Act
+		match := project.FirstMatch(regexps...)
+
+		// Assert
+		testutils.False(testutils.Require(t), match == nil)
+		testutils.Equal(t, "^hey_.*$", match.String())
+	})
+}
+
 func TestMatches(t *testing.T) {
 	t.Run("does_not_match", func(t *testing.T) {
 		// Arrange
@@ -42,16 +73,96 @@ func TestMatches(t *testing.T) {
 	})
 }
 
+func TestMatchesFilter(t *testing.T) {
+	project := models.Project{
+		LastActivityAt: time.Now().Add(-48 * time.Hour),
+		Topics:         []string{"go", "cli"},
+		Visibility:     "internal",
+	}
+
+	t.Run("matches_empty_filter", func(t *testing.T) {
+		// Act
+		matches := project.MatchesFilter(models.ProjectFilter{})
+
+		// Assert
+		testutils.True(t, matches)
+	})
+
+	t.Run("matches_all_conditions", func(t *testing.T) {
+		// Arrange
+		filter := models.ProjectFilter{
+			IdleDuration: 24 * time.Hour,
+			Topics:       []string{"cli", "go"},
+			Visibilities: []string{"private", "internal"},
+		}
+
+		// Act
+		matches := project.MatchesFilter(filter)
+
+		// Assert
+		testutils.True(t, matches)
+	})
+
+	t.Run("missing_topic", func(t *testing.T) {
+		// Act
+		matches := project.MatchesFilter(models.ProjectFilter{Topics: []string{"go", "rust"}})
+
+		// Assert
+		testutils.False(t, matches)
+	})
+
+	t.Run("other_visibility", func(t *testing.T) {
+		// Act
+		matches := project.MatchesFilter(models.ProjectFilter{Visibilities: []string{"public"}})
+
+		// Assert
+		testutils.False(t, matches)
+	})
+
+	t.Run("not_idle", func(t *testing.T) {
+		// Act
+		matches := project.MatchesFilter(models.ProjectFilter{IdleDuration: 72 * time.Hour})
+
+		// Assert
+		testutils.False(t, matches)
+	})
+
+	t.Run("no_last_activity", func(t *testing.T) {
+		// Arrange
+		project := models.Project{}
+
+		// Act
+		matches := project.MatchesFilter(models.ProjectFilter{IdleDuration: time.Hour})
+
+		// Assert
+		testutils.False(t, matches)
+	})
+}
+
 func TestProjectFromGitLab(t *testing.T) {
 	t.Run("success", func(t *testing.T) {
 		// Arrange
-		gitlab := gitlab.Project{ID: 1, PathWithNamespace: "john.doe"}
-		expected := models.Project{ID: 1, PathWithNamespace: "john.doe"}
+		now := time.Now()
+		gitlab := gitlab.Project{
+			ID:                1,
+			LastActivityAt:    &now,
+			PathWithNamespace: "john.doe",
+			Statistics:        &gitlab.Statistics{JobArtifactsSize: 1024, StorageSize: 4096},
+			Topics:            []string{"go"},
+			Visibility:        gitlab.PrivateVisibility,
+		}
 
 		// Act
 		project := models.ProjectFromGitLab(&gitlab)
 
 		// Assert
-		testutils.Equal(t, expected, project)
+		testutils.Equal(t, 1, project.ID)
+		testutils.Equal(t, now, project.LastActivityAt)
+		testutils.Equal(t, "john.doe", project.PathWithNamespace)
+		testutils.NotNil(testutils.Require(t), project.Statistics)
+		testutils.Equal(t, models.Statistics{JobArtifactsSize: 1024, StorageSize: 4096}, *project.Statistics)
+		testutils.Equal(testutils.Require(t), 1, len(project.Topics))
+		testutils.Equal(t, "go", project.Topics[0])
+		testutils.Equal(t, "private", project.Visibility)
 	})
 }
@@ -0,0 +1,77 @@
+package models
+
+import (
+	"regexp"
+	"strings"
+
+	gitlab "gitlab.com/gitlab-org/api/client-go/v2"
+)
+
+// ProtectedBranches represents the protected branches of a project.
+//
+// Protected branches names can contain wildcards (e.g. 'release/*'), as such they are kept as regexps.
+type ProtectedBranches []*regexp.Regexp
+
+// Matches returns truthy if the input ref is one of the protected branches.
+func (p ProtectedBranches) Matches(ref string) bool {
+	return matchesAny(p, ref)
+}
+
+// ProtectedBranchesFromGitLab converts GitLab protected branches to their simplified view.
+func ProtectedBranchesFromGitLab(branches ...*gitlab.ProtectedBranch) ProtectedBranches {
+	protected := make(ProtectedBranches, 0, len(branches))
+	for _, branch := range branches {
+		protected = append(protected, wildcard(branch.Name))
+	}
+	return protected
+}
+
+// ProtectedTags represents the protected tags of a project.
+//
+// Protected tags names can contain wildcards (e.g. 'v*'), as such they are kept as regexps.
+type ProtectedTags []*regexp.Regexp
+
+// Matches returns truthy if the input ref is one of the protected tags.
+func (p ProtectedTags) Matches(ref string) bool {
+	return matchesAny(p, ref)
+}
+
+// ProtectedTagsFromGitLab converts GitLab protected tags to their simplified view.
+func ProtectedTagsFromGitLab(tags ...*gitlab.ProtectedTag) ProtectedTags {
+	protected := make(ProtectedTags, 0, len(tags))
+	for _, tag := range tags {
+		protected = append(protected, wildcard(tag.Name))
+	}
+	return protected
+}
+
+// ProtectedRefs represents both protected branches and protected tags of a project.
+type ProtectedRefs struct {
+	Branches ProtectedBranches
+	Tags     ProtectedTags
+}
+
+// Matches returns truthy if the input job ran on a protected branch or a protected tag.
+func (p ProtectedRefs) Matches(job Job) bool {
+	if job.Tag {
+		return p.Tags.Matches(job.Ref)
+	}
+	return p.Branches.Matches(job.Ref)
+}
+
+// matchesAny returns truthy if the input ref matches one of the input regexps.
+func matchesAny(regexps []*regexp.Regexp, ref string) bool {
+	for _, r := range regexps {
+		if r.MatchString(ref) {
+			return true
+		}
+	}
+	return false
+}
+
+// wildcard returns the regexp associated to a protected branch or tag name.
+func wildcard(name string) *regexp.Regexp {
+	// '*' wildcard matches any character (including '/') in gitlab protected branches and tags
+	pattern := strings.ReplaceAll(regexp.QuoteMeta(name), `\*`, ".*")
+	return regexp.MustCompile("^" + pattern + "$")
+}
@@ -0,0 +1,73 @@
+package models_test
+
+import (
+	"testing"
+
+	gitlab "gitlab.com/gitlab-org/api/client-go/v2"
+
+	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/artifacts/models"
+	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/testutils"
+)
+
+func TestProtectedBranchesMatches(t *testing.T) {
+	branches := models.ProtectedBranchesFromGitLab(
+		&gitlab.ProtectedBranch{Name: "main"},
+		&gitlab.ProtectedBranch{Name: "release/*"},
+		&gitlab.ProtectedBranch{Name: "v1.x"},
+	)
+
+	t.Run("does_not_match", func(t *testing.T) {
+		for _, ref := range []string{"feat/main", "release", "v1-x"} {
+			t.Run(ref, func(t *testing.T) {
+				// Act
+				matches := branches.Matches(ref)
+
+				// Assert
+				testutils.False(t, matches)
+			})
+		}
+	})
+
+	t.Run("matches", func(t *testing.T) {
+		for _, ref := range []string{"main", "release/1.x", "release/v1/hotfix", "v1.x"} {
+			t.Run(ref, func(t *testing.T) {
+				// Act
+				matches := branches.Matches(ref)
+
+				// Assert
+				testutils.True(t, matches)
+			})
+		}
+	})
+}
+
+func TestProtectedRefsMatches(t *testing.T) {
+	protected := models.ProtectedRefs{
+		Branches: models.ProtectedBranchesFromGitLab(&gitlab.ProtectedBranch{Name: "main"}),
+		Tags:     models.ProtectedTagsFromGitLab(&gitlab.ProtectedTag{Name: "v*"}),
+	}
+
+	t.Run("does_not_match", func(t *testing.T) {
+		for _, job := range []models.Job{{Ref: "feat/main"}, {Ref: "main", Tag: true}, {Ref: "v1.0.0"}, {Ref: "1.0.0", Tag: true}} {
+			t.Run(job.Ref, func(t *testing.T) {
+				// Act
+				matches := protected.Matches(job)
+
+				// Assert
+				testutils.False(t, matches)
+			})
+		}
+	})
+
+	t.Run("matches", func(t *testing.T) {
+		for _, job := range []models.Job{{Ref: "main"}, {Ref: "v1.0.0", Tag: true}} {
+			t.Run(job.Ref, func(t *testing.T) {
+				// Act
+				matches := protected.Matches(job)
+
+				// Assert
+				testutils.True(t, matches)
+			})
+		}
+	})
+}
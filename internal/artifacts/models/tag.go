@@ -0,0 +1,68 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/samber/lo"
+	gitlab "gitlab.com/gitlab-org/api/client-go/v2"
+)
+
+// semverRegexp represents a semantic version (https://semver.org/) with an optional 'v' prefix.
+var semverRegexp = regexp.MustCompile(`^v?(0|[1-9][0-9]*)\.(0|[1-9][0-9]*)\.(0|[1-9][0-9]*)(-[0-9A-Za-z-]+(\.[0-9A-Za-z-]+)*)?(\+[0-9A-Za-z-]+(\.[0-9A-Za-z-]+)*)?$`)
+
+// Tag is a simplified view of a gitlab container registry tag with only useful information for registry deletion feature.
+type Tag struct {
+	Cleaned      bool
+	CreatedAt    time.Time
+	Name         string
+	ProjectID    int64
+	RepositoryID int64
+}
+
+// IsLatest returns truthy if the tag is the 'latest' tag.
+func (t Tag) IsLatest() bool {
+	return t.Name == "latest"
+}
+
+// IsSemver returns truthy if the tag name is a semantic version (with or without 'v' prefix).
+func (t Tag) IsSemver() bool {
+	return semverRegexp.MatchString(t.Name)
+}
+
+// NeedCleanup returns truthy if the tag needs to be cleaned up.
+//
+// It returns true if the tag creation date is undefined or before now minus the threshold.
+func (t Tag) NeedCleanup(threshold time.Duration) bool {
+	return t.CreatedAt.IsZero() || t.CreatedAt.Before(time.Now().Add(-threshold))
+}
+
+// Delete deletes the tag from its registry repository.
+//
+// It returns an error if the deletion failed, alongside gitlab response when one was received.
+func (t Tag) Delete(ctx context.Context, client *gitlab.Client) (*gitlab.Response, error) {
+	// call registry repository tag deletion
+	response, err := client.ContainerRegistry.DeleteRegistryRepositoryTag(t.ProjectID, t.RepositoryID, t.Name, gitlab.WithContext(ctx))
+	if err != nil {
+		return response, fmt.Errorf("delete tag: %w", err)
+	}
+	defer response.Body.Close()
+
+	// handle http errors
+	if response.StatusCode/100 != 2 {
+		return response, statusError("delete tag", response)
+	}
+	return response, nil
+}
+
+// TagFromGitLab converts a GitLab registry repository tag to its simplified view.
+func TagFromGitLab(projectID, repositoryID int64, tag *gitlab.RegistryRepositoryTag) Tag {
+	return Tag{
+		CreatedAt:    lo.FromPtr(tag.CreatedAt),
+		Name:         tag.Name,
+		ProjectID:    projectID,
+		RepositoryID: repositoryID,
+	}
+}
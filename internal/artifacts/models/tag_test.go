@@ -0,0 +1,166 @@
+package models_test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/samber/lo"
+	gitlab "gitlab.com/gitlab-org/api/client-go/v2"
+
+	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/artifacts/models"
+	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/testutils"
+)
+
+func TestIsLatest(t *testing.T) {
+	t.Run("false_not_latest", func(t *testing.T) {
+		// Arrange
+		tag := models.Tag{Name: "latest-rc"}
+
+		// Act
+		latest := tag.IsLatest()
+
+		// Assert
+		testutils.False(t, latest)
+	})
+
+	t.Run("success_true", func(t *testing.T) {
+		// Arrange
+		tag := models.Tag{Name: "latest"}
+
+		// Act
+		latest := tag.IsLatest()
+
+		// Assert
+		testutils.True(t, latest)
+	})
+}
+
+func TestIsSemver(t *testing.T) {
+	t.Run("false_not_semver", func(t *testing.T) {
+		for _, name := range []string{"latest", "v1.2", "1.2.3.4", "main-1.2.3"} {
+			t.Run(name, func(t *testing.T) {
+				// Arrange
+				tag := models.Tag{Name: name}
+
+				// Act
+				semver := tag.IsSemver()
+
+				// Assert
+				testutils.False(t, semver)
+			})
+		}
+	})
+
+	t.Run("success_true", func(t *testing.T) {
+		for _, name := range []string{"1.2.3", "v1.2.3", "v1.2.3-rc.1", "1.0.0+build.5"} {
+			t.Run(name, func(t *testing.T) {
+				// Arrange
+				tag := models.Tag{Name: name}
+
+				// Act
+				semver := tag.IsSemver()
+
+				// Assert
+				testutils.True(t, semver)
+			})
+		}
+	})
+}
+
+func TestTagNeedCleanup(t *testing.T) {
+	t.Run("false_too_recent", func(t *testing.T) {
+		// Arrange
+		tag := models.Tag{CreatedAt: time.Now().Add(-5 * time.Minute)}
+
+		// Act
+		clean := tag.NeedCleanup(time.Hour)
+
+		// Assert
+		testutils.False(t, clean)
+	})
+
+	t.Run("success_true_old", func(t *testing.T) {
+		// Arrange
+		tag := models.Tag{CreatedAt: time.Now().Add(-2 * time.Hour)}
+
+		// Act
+		clean := tag.NeedCleanup(time.Hour)
+
+		// Assert
+		testutils.True(t, clean)
+	})
+
+	t.Run("success_true_no_creation_date", func(t *testing.T) {
+		// Arrange
+		tag := models.Tag{}
+
+		// Act
+		clean := tag.NeedCleanup(time.Hour)
+
+		// Assert
+		testutils.True(t, clean)
+	})
+}
+
+func TestTagDelete(t *testing.T) {
+	ctx := t.Context()
+
+	httpmock.Activate()
+	t.Cleanup(httpmock.DeactivateAndReset)
+
+	// setup mock client
+	client, err := gitlab.NewClient("",
+		gitlab.WithHTTPClient(&http.Client{Transport: httpmock.DefaultTransport}),
+		gitlab.WithoutRetries(),
+	)
+	testutils.NoError(testutils.Require(t), err)
+
+	tag := models.Tag{Name: "old", ProjectID: 5, RepositoryID: 3}
+	url := fmt.Sprintf("https://gitlab.com/api/v4/projects/%d/registry/repositories/%d/tags/%s", tag.ProjectID, tag.RepositoryID, tag.Name)
+
+	t.Run("error_delete_call", func(t *testing.T) {
+		// Arrange
+		t.Cleanup(httpmock.Reset)
+		httpmock.RegisterResponder(http.MethodDelete, url,
+			httpmock.NewStringResponder(http.StatusInternalServerError, "an error"))
+
+		// Act
+		_, err := tag.Delete(ctx, client)
+
+		// Assert
+		testutils.Error(testutils.Require(t), err)
+		testutils.Contains(t, err.Error(), "delete tag")
+	})
+
+	t.Run("success_deletion", func(t *testing.T) {
+		// Arrange
+		t.Cleanup(httpmock.Reset)
+		httpmock.RegisterResponder(http.MethodDelete, url,
+			httpmock.NewStringResponder(http.StatusOK, ""))
+
+		// Act
+		_, err := tag.Delete(ctx, client)
+
+		// Assert
+		testutils.NoError(testutils.Require(t), err)
+		testutils.Equal(t, 1, httpmock.GetTotalCallCount())
+	})
+}
+
+func TestTagFromGitLab(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		// Arrange
+		now := time.Now()
+		gitlab := gitlab.RegistryRepositoryTag{Name: "v1.0.0", CreatedAt: lo.ToPtr(now)}
+		expected := models.Tag{CreatedAt: now, Name: "v1.0.0", ProjectID: 5, RepositoryID: 3}
+
+		// Act
+		tag := models.TagFromGitLab(5, 3, &gitlab)
+
+		// Assert
+		testutils.Equal(t, expected, tag)
+	})
+}
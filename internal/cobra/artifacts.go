@@ -1,120 +1,221 @@
 package cobra
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"os"
+	"slices"
 	"strconv"
-	"strings"
 	"time"
 
 	"github.com/spf13/cobra"
-	gitlab "gitlab.com/gitlab-org/api/client-go/v2"
 
 	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/artifacts/engine"
 	artifacts "github.com/kilianpaquier/gitlab-storage-cleaner/internal/artifacts/engine/v2"
 )
 
-const envPrefix = "cleaner-"
-
 const (
-	flagDryRun            = "dry-run"
-	flagPaths             = "paths"
-	flagServer            = "server"
-	flagThresholdDuration = "threshold-duration"
-	flagToken             = "token"
+	flagArtifactTypes      = "artifact-types"
+	flagConfig             = "config"
+	flagExcludeJobNames    = "exclude-job-names"
+	flagExcludeJobRefs     = "exclude-job-refs"
+	flagExcludeJobStages   = "exclude-job-stages"
+	flagForceRescan        = "force-rescan"
+	flagJobNames           = "job-names"
+	flagJobRefs            = "job-refs"
+	flagJobStages          = "job-stages"
+	flagJobStatuses        = "job-statuses"
+	flagKeepLatestBy       = "keep-latest-by"
+	flagMaxArtifactsSize   = "max-artifacts-size"
+	flagProtectedThreshold = "protected-threshold-duration"
+	flagReport             = "report"
+	flagReportFormat       = "report-format"
+	flagResume             = "resume"
+	flagStateFile          = "state-file"
 )
 
 // artifactsCmd creates a new cobra command for cleaning GitLab artifacts.
-func artifactsCmd() *cobra.Command { //nolint:gocognit,funlen
+func artifactsCmd() *cobra.Command {
+	flags := newCleanFlags()
+	keepLatestBy := string(engine.KeepLatestNone)
+
 	var (
-		token  string
-		server string
-		dryRun bool
-		paths  []string
+		config     engine.Config
+		configPath string
+		jobs       engine.JobFilters
+
+		maxArtifactsSize   engine.Size
+		protectedThreshold time.Duration
+
+		reportPath   string
+		reportFormat = string(artifacts.ReportJSON)
+
+		stateFile   string
+		resume      bool
+		forceRescan bool
 	)
-	thresholdDuration := 7 * 24 * time.Hour
 
 	cmd := &cobra.Command{
 		Use:   "artifacts",
 		Short: "Clean artifacts of provided project(s)' gitlab storage",
-		Args: func(cmd *cobra.Command, _ []string) (err error) {
-			// validate dry run environment variable
-			if !cmd.Flags().Changed(flagDryRun) {
-				if env := getenv(envPrefix + flagDryRun); env != "" {
-					dr, err := strconv.ParseBool(env)
-					if err != nil {
-						return fmt.Errorf(`invalid argument %q for "--%s" flag: %w`, env, flagDryRun, err)
-					}
-					dryRun = dr
-				}
-			}
-
-			// validate paths environment variable
-			if !cmd.Flags().Changed(flagPaths) {
-				if env := getenv(envPrefix + flagPaths); env != "" {
-					paths = strings.Split(env, ",")
-				}
+		Args: func(cmd *cobra.Command, _ []string) error {
+			envString(cmd, flagConfig, &configPath)
+			envString(cmd, flagKeepLatestBy, &keepLatestBy)
+			envStrings(cmd, flagArtifactTypes, &jobs.ArtifactTypes)
+			envStrings(cmd, flagExcludeJobNames, &jobs.ExcludeNames)
+			envStrings(cmd, flagExcludeJobRefs, &jobs.ExcludeRefs)
+			envStrings(cmd, flagExcludeJobStages, &jobs.ExcludeStages)
+			envStrings(cmd, flagJobNames, &jobs.Names)
+			envStrings(cmd, flagJobRefs, &jobs.Refs)
+			envStrings(cmd, flagJobStages, &jobs.Stages)
+			envStrings(cmd, flagJobStatuses, &jobs.Statuses)
+			if err := envSize(cmd, flagMaxArtifactsSize, &maxArtifactsSize); err != nil {
+				return err
 			}
-
-			// validate threshold duration environment variable
-			if !cmd.Flags().Changed(flagThresholdDuration) {
-				if env := getenv(envPrefix + flagThresholdDuration); env != "" {
-					td, err := time.ParseDuration(env)
-					if err != nil {
-						return fmt.Errorf(`invalid argument %q for "--%s" flag: %w`, env, flagThresholdDuration, err)
-					}
-					thresholdDuration = td
-				}
+			if err := envDuration(cmd, flagProtectedThreshold, &protectedThreshold); err != nil {
+				return err
 			}
-
-			var missings []string
-			if len(paths) == 0 {
-				missings = append(missings, `"`+flagPaths+`"`)
+			envString(cmd, flagReport, &reportPath)
+			envString(cmd, flagReportFormat, &reportFormat)
+			envString(cmd, flagStateFile, &stateFile)
+			if err := envBool(cmd, flagResume, &resume); err != nil {
+				return err
 			}
-			if server == "" {
-				missings = append(missings, `"`+flagServer+`"`)
+			if err := envBool(cmd, flagForceRescan, &forceRescan); err != nil {
+				return err
 			}
-			if token == "" {
-				missings = append(missings, `"`+flagToken+`"`)
+			if !slices.Contains(artifacts.ReportFormats(), artifacts.ReportFormat(reportFormat)) {
+				return fmt.Errorf(`invalid argument %q for "--%s" flag`, reportFormat, flagReportFormat)
 			}
-			if len(missings) > 0 {
-				return fmt.Errorf("required flag(s) %s not set", strings.Join(missings, ", "))
+
+			if configPath != "" {
+				var err error
+				if config, err = engine.LoadConfig(configPath); err != nil {
+					return fmt.Errorf(`invalid argument %q for "--%s" flag: %w`, configPath, flagConfig, err)
+				}
+				flags.pathsOptional = true
 			}
-			return nil
+			return flags.parse(cmd)
 		},
-		RunE: func(cmd *cobra.Command, _ []string) error {
+		RunE: func(cmd *cobra.Command, _ []string) (err error) {
 			// check gitlab client
-			client, err := gitlab.NewClient(token, gitlab.WithBaseURL(server), gitlab.WithoutRetries())
+			client, err := flags.client()
 			if err != nil {
 				return err
 			}
 
-			opts := []engine.RunOption{
-				engine.WithDryRun(dryRun),
-				engine.WithLogger(engine.NewSlogLogger(logger)),
-				engine.WithPaths(paths...),
-				engine.WithThresholdDuration(thresholdDuration),
+			done, err := flags.instrument(cmd.Context())
+			if err != nil {
+				return err
+			}
+			defer func() { err = errors.Join(err, done()) }()
+
+			opts := append(flags.options(),
+				engine.WithJobFilters(jobs),
+				engine.WithKeepLatestJobsBy(engine.KeepLatestBy(keepLatestBy)),
+				engine.WithMaxArtifactsSize(maxArtifactsSize),
+				engine.WithProtectedThresholdDuration(protectedThreshold),
+				engine.WithReport(reportPath != ""),
+				engine.WithForceRescan(forceRescan),
+				engine.WithResume(resume),
+				engine.WithStateFile(stateFile))
+			if len(config.Rules) == 0 {
+				// run failures don't prevent the summary and report from being given
+				summary, err := artifacts.Run(cmd.Context(), client, opts...)
+				logSummary(summary)
+				return errors.Join(err, writeReport(reportPath, artifacts.ReportFormat(reportFormat), summary))
 			}
 
-			return artifacts.Run(cmd.Context(), client, opts...)
+			// run each configuration rule one after the other, rule options overriding command line ones
+			var (
+				errs  []error
+				total artifacts.Summary
+			)
+			for i, rule := range config.Rules {
+				name := coalesce(rule.Name, strconv.Itoa(i+1))
+				logger.Info("running configuration rule", "rule", name)
+				// each rule has its own progress in the state file
+				ruleOpts := append(slices.Clone(opts), engine.WithStateScope(name))
+				summary, err := artifacts.Run(cmd.Context(), client, append(ruleOpts, rule.Options()...)...)
+				if err != nil {
+					errs = append(errs, fmt.Errorf("rule '%s': %w", name, err))
+				}
+				total = total.Add(summary)
+			}
+			logSummary(total)
+			if err := writeReport(reportPath, artifacts.ReportFormat(reportFormat), total); err != nil {
+				errs = append(errs, err)
+			}
+			return errors.Join(errs...)
 		},
 	}
 
-	// gitlab token
-	cmd.Flags().StringVar(&token, flagToken, coalesce(os.Getenv("GITLAB_TOKEN"), os.Getenv("GL_TOKEN")), "gitlab read/write token with maintainer rights to delete artifacts")
+	flags.register(cmd, "jobs' artifacts")
+
+	// configuration file
+	cmd.Flags().StringVar(&configPath, flagConfig, "",
+		"path to a YAML or JSON configuration file with rule blocks (paths, threshold duration, dry run, etc.) overriding command line flags")
+
+	// jobs filters
+	cmd.Flags().StringSliceVar(&jobs.Names, flagJobNames, nil, "list of valid regexps to match job name, only matching jobs' artifacts are cleaned")
+	cmd.Flags().StringSliceVar(&jobs.ExcludeNames, flagExcludeJobNames, nil, "list of valid regexps to exclude job name, taking precedence over job names")
+	cmd.Flags().StringSliceVar(&jobs.Stages, flagJobStages, nil, "list of valid regexps to match job stage, only matching jobs' artifacts are cleaned")
+	cmd.Flags().StringSliceVar(&jobs.ExcludeStages, flagExcludeJobStages, nil, "list of valid regexps to exclude job stage, taking precedence over job stages")
+	cmd.Flags().StringSliceVar(&jobs.Refs, flagJobRefs, nil, "list of valid regexps to match job ref (branch or tag), only matching jobs' artifacts are cleaned")
+	cmd.Flags().StringSliceVar(&jobs.ExcludeRefs, flagExcludeJobRefs, nil, "list of valid regexps to exclude job ref (branch or tag), taking precedence over job refs")
+	cmd.Flags().StringSliceVar(&jobs.ArtifactTypes, flagArtifactTypes, nil, "list of artifacts file types (e.g. archive, junit, cobertura) allowed for deletion, jobs having other types of artifacts are never cleaned")
+	cmd.Flags().StringSliceVar(&jobs.Statuses, flagJobStatuses, nil, "list of job statuses (e.g. failed, success, canceled) to consider for cleaning, defaults to failed and success")
+
+	// storage budget
+	cmd.Flags().Var(&maxArtifactsSize, flagMaxArtifactsSize,
+		"storage budget (e.g. 500MB or 5GiB) of each project jobs' artifacts, the largest ones older than threshold duration are deleted only until the project fits in it")
 
-	// gitlab server
-	cmd.Flags().StringVar(&server, flagServer, coalesce(os.Getenv("CI_API_V4_URL"), os.Getenv("CI_SERVER_HOST")), "gitlab server host")
+	// protected branches and tags
+	cmd.Flags().DurationVar(&protectedThreshold, flagProtectedThreshold, 0,
+		"threshold duration (positive) of jobs' artifacts ran on protected branches and tags, those artifacts are never deleted when not provided")
 
-	// dry run
-	cmd.Flags().BoolVar(&dryRun, flagDryRun, false, "truthy if run must not delete jobs' artifacts but only list matched projects")
+	// run report
+	cmd.Flags().StringVar(&reportPath, flagReport, "", "path to a file where to write a report of all visited projects (processed or skipped with the reason) and matched jobs with their cleanup outcome (deleted, dry-run, interrupted or failed)")
+	cmd.Flags().StringVar(&reportFormat, flagReportFormat, reportFormat, "format of the report file, either 'json', 'csv' or 'markdown'")
 
-	// projects filtering options
-	cmd.Flags().StringSliceVar(&paths, flagPaths, nil, "list of valid regexps to match project path (with namespace)")
+	// checkpointing
+	cmd.Flags().StringVar(&stateFile, flagStateFile, "", "path to a JSON file where to record the run progress (completed projects and last listed jobs page of each project)")
+	cmd.Flags().BoolVar(&resume, flagResume, false, "truthy if the run must resume from the state file, skipping completed projects and listing jobs from their last listed page")
+	cmd.Flags().BoolVar(&forceRescan, flagForceRescan, false, "truthy if projects completed in a previous run must be processed again when resuming")
 
-	// threshold duration
-	cmd.Flags().DurationVar(&thresholdDuration, flagThresholdDuration, thresholdDuration,
-		"threshold duration (positive) where, jobs older than command execution time minus this threshold will be deleted")
+	// keep rules
+	cmd.Flags().StringVar(&keepLatestBy, flagKeepLatestBy, keepLatestBy,
+		"keep artifacts of the latest successful pipeline per ref ('ref') or of the latest successful job per ref and job name ('ref-name'), 'none' to disable")
 
 	return cmd
 }
+
+// logSummary logs the totals of one or multiple artifacts runs.
+func logSummary(summary artifacts.Summary) {
+	logger.Info("ending artifacts cleaning",
+		"bytes_cleaned", summary.BytesCleaned,
+		"failures", summary.Failures,
+		"jobs_cleaned", summary.JobsCleaned,
+		"jobs_failed", summary.JobsFailed,
+		"projects", summary.Projects,
+		"size_cleaned", engine.Size(summary.BytesCleaned).Human())
+}
+
+// writeReport writes the run summary into the input report file path with the input format.
+//
+// It's a no-op when no report file path is provided.
+func writeReport(path string, format artifacts.ReportFormat, summary artifacts.Summary) error {
+	if path == "" {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	if err := artifacts.WriteReport(&buf, format, summary); err != nil {
+		return fmt.Errorf("write report: %w", err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil { //nolint:gosec
+		return fmt.Errorf("write report: %w", err)
+	}
+	return nil
+}
@@ -1,11 +1,14 @@
 package cobra //nolint:testpackage
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
 	"github.com/spf13/cobra"
 
+	artifacts "github.com/kilianpaquier/gitlab-storage-cleaner/internal/artifacts/engine/v2"
 	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/testutils"
 )
 
@@ -33,7 +36,7 @@ func TestArtifactsFlags(t *testing.T) {
 	})
 
 	t.Run("invalid_env", func(t *testing.T) {
-		for _, env := range []string{"CLEANER_DRY_RUN", "CLEANER_THRESHOLD_DURATION"} {
+		for _, env := range []string{"CLEANER_ARCHIVED_ONLY", "CLEANER_ARCHIVED_ONLY_THRESHOLD_DURATION", "CLEANER_DRY_RUN", "CLEANER_FORCE_RESCAN", "CLEANER_GRACE_PERIOD", "CLEANER_IDLE_DURATION", "CLEANER_INCLUDE_ARCHIVED", "CLEANER_INCLUDE_ARCHIVED_THRESHOLD_DURATION", "CLEANER_JOB_CONCURRENCY", "CLEANER_MAX_ARTIFACTS_SIZE", "CLEANER_PROJECT_CONCURRENCY", "CLEANER_PROTECTED_THRESHOLD_DURATION", "CLEANER_RATE_LIMIT", "CLEANER_RETRY_BASE_DELAY", "CLEANER_RETRY_JITTER", "CLEANER_RETRY_MAX_ATTEMPTS", "CLEANER_RETRY_STATUS_CODES", "CLEANER_REPORT_FORMAT", "CLEANER_RESUME", "CLEANER_THRESHOLD_DURATION"} {
 			t.Run(env, func(t *testing.T) {
 				// Arrange
 				t.Setenv("CI_API_V4_URL", "https://gitlab.example.com/api/v4")
@@ -53,12 +56,94 @@ func TestArtifactsFlags(t *testing.T) {
 		}
 	})
 
+	t.Run("archived_flags_mutually_exclusive", func(t *testing.T) {
+		// Arrange
+		t.Setenv("CI_API_V4_URL", "https://gitlab.example.com/api/v4")
+		t.Setenv("CLEANER_ARCHIVED_ONLY", "true")
+		t.Setenv("CLEANER_PATHS", "path1,path2")
+		t.Setenv("GITLAB_TOKEN", "token")
+
+		cmd := norun(artifactsCmd())
+		cmd.SetArgs([]string{"--" + flagIncludeArchived})
+
+		// Act
+		err := cmd.ExecuteContext(t.Context())
+
+		// Assert
+		testutils.Error(testutils.Require(t), err)
+		testutils.Contains(t, err.Error(), `"--include-archived" and "--archived-only" flags are mutually exclusive`)
+	})
+
+	t.Run("invalid_config", func(t *testing.T) {
+		// Arrange
+		path := filepath.Join(t.TempDir(), "config.yml")
+		testutils.NoError(testutils.Require(t), os.WriteFile(path, []byte("version: 2\nrules: []\n"), 0o600))
+
+		t.Setenv("CI_API_V4_URL", "https://gitlab.example.com/api/v4")
+		t.Setenv("CLEANER_CONFIG", path)
+		t.Setenv("GITLAB_TOKEN", "token")
+
+		cmd := norun(artifactsCmd())
+
+		// Act
+		err := cmd.ExecuteContext(t.Context())
+
+		// Assert
+		testutils.Error(testutils.Require(t), err)
+		testutils.Contains(t, err.Error(), `for "--config" flag`)
+		testutils.Contains(t, err.Error(), "line 1: unsupported version '2'")
+	})
+
+	t.Run("from_config", func(t *testing.T) {
+		// Arrange
+		path := filepath.Join(t.TempDir(), "config.yml")
+		testutils.NoError(testutils.Require(t), os.WriteFile(path, []byte("version: 1\nrules:\n  - paths: [path1]\n"), 0o600))
+
+		t.Setenv("CI_API_V4_URL", "https://gitlab.example.com/api/v4")
+		t.Setenv("CLEANER_PATHS", "")
+		t.Setenv("GITLAB_TOKEN", "token")
+
+		cmd := norun(artifactsCmd())
+		cmd.SetArgs([]string{"--" + flagConfig, path})
+
+		// Act
+		err := cmd.ExecuteContext(t.Context())
+
+		// Assert
+		testutils.NoError(t, err) // paths aren't required with a configuration file
+	})
+
 	t.Run("from_env", func(t *testing.T) {
 		// Arrange
 		t.Setenv("CI_API_V4_URL", "https://gitlab.example.com/api/v4")
+		t.Setenv("CLEANER_ARTIFACT_TYPES", "archive")
 		t.Setenv("CLEANER_DRY_RUN", "true")
+		t.Setenv("CLEANER_EXCLUDE_PATHS", "path1,path2")
+		t.Setenv("CLEANER_EXCLUDE_JOB_REFS", "^main$")
+		t.Setenv("CLEANER_GRACE_PERIOD", "1m")
+		t.Setenv("CLEANER_INCLUDE_ARCHIVED", "true")
+		t.Setenv("CLEANER_INCLUDE_ARCHIVED_THRESHOLD_DURATION", "24h")
+		t.Setenv("CLEANER_IDLE_DURATION", "720h")
+		t.Setenv("CLEANER_JOB_CONCURRENCY", "20")
+		t.Setenv("CLEANER_JOB_NAMES", "^test:,^lint:")
+		t.Setenv("CLEANER_JOB_STATUSES", "canceled")
+		t.Setenv("CLEANER_KEEP_LATEST_BY", "ref")
+		t.Setenv("CLEANER_MAX_ARTIFACTS_SIZE", "5GiB")
 		t.Setenv("CLEANER_PATHS", `^$CI_PROJECT_NAMESPACE\/.*$`)
+		t.Setenv("CLEANER_PROJECT_CONCURRENCY", "5")
+		t.Setenv("CLEANER_PROTECTED_THRESHOLD_DURATION", "8760h")
+		t.Setenv("CLEANER_RATE_LIMIT", "2.5")
+		t.Setenv("CLEANER_RETRY_BASE_DELAY", "500ms")
+		t.Setenv("CLEANER_RETRY_JITTER", "0.5")
+		t.Setenv("CLEANER_RETRY_MAX_ATTEMPTS", "5")
+		t.Setenv("CLEANER_RETRY_STATUS_CODES", "429,503")
+		t.Setenv("CLEANER_REPORT", "report.md")
+		t.Setenv("CLEANER_REPORT_FORMAT", "markdown")
+		t.Setenv("CLEANER_RESUME", "true")
+		t.Setenv("CLEANER_STATE_FILE", "state.json")
 		t.Setenv("CLEANER_THRESHOLD_DURATION", "72h")
+		t.Setenv("CLEANER_TOPICS", "go")
+		t.Setenv("CLEANER_VISIBILITIES", "private,internal")
 		t.Setenv("GITLAB_TOKEN", "token")
 
 		cmd := norun(artifactsCmd())
@@ -69,6 +154,30 @@ func TestArtifactsFlags(t *testing.T) {
 		// Assert
 		testutils.NoError(testutils.Require(t), err)
 
+		includeArchivedThreshold, err := cmd.Flags().GetDuration(flagIncludeArchivedThreshold)
+		testutils.NoError(testutils.Require(t), err)
+		testutils.Equal(t, 24*time.Hour, includeArchivedThreshold)
+
+		includeArchived, err := cmd.Flags().GetBool(flagIncludeArchived)
+		testutils.NoError(testutils.Require(t), err)
+		testutils.True(t, includeArchived)
+
+		gracePeriod, err := cmd.Flags().GetDuration(flagGracePeriod)
+		testutils.NoError(testutils.Require(t), err)
+		testutils.Equal(t, time.Minute, gracePeriod)
+
+		idleDuration, err := cmd.Flags().GetDuration(flagIdleDuration)
+		testutils.NoError(testutils.Require(t), err)
+		testutils.Equal(t, 720*time.Hour, idleDuration)
+
+		topics, err := cmd.Flags().GetStringSlice(flagTopics)
+		testutils.NoError(testutils.Require(t), err)
+		testutils.Equal(t, 1, len(topics))
+
+		visibilities, err := cmd.Flags().GetStringSlice(flagVisibilities)
+		testutils.NoError(testutils.Require(t), err)
+		testutils.Equal(t, 2, len(visibilities))
+
 		server, err := cmd.Flags().GetString(flagServer)
 		testutils.NoError(testutils.Require(t), err)
 		testutils.Equal(t, "https://gitlab.example.com/api/v4", server)
@@ -81,6 +190,64 @@ func TestArtifactsFlags(t *testing.T) {
 		testutils.NoError(testutils.Require(t), err)
 		testutils.Equal(t, true, dryRun)
 
+		excludePaths, err := cmd.Flags().GetStringSlice(flagExcludePaths)
+		testutils.NoError(testutils.Require(t), err)
+		testutils.Equal(t, 2, len(excludePaths))
+
+		artifactTypes, err := cmd.Flags().GetStringSlice(flagArtifactTypes)
+		testutils.NoError(testutils.Require(t), err)
+		testutils.Equal(testutils.Require(t), 1, len(artifactTypes))
+		testutils.Equal(t, "archive", artifactTypes[0])
+
+		excludeJobRefs, err := cmd.Flags().GetStringSlice(flagExcludeJobRefs)
+		testutils.NoError(testutils.Require(t), err)
+		testutils.Equal(t, 1, len(excludeJobRefs))
+
+		jobConcurrency, err := cmd.Flags().GetInt(flagJobConcurrency)
+		testutils.NoError(testutils.Require(t), err)
+		testutils.Equal(t, 20, jobConcurrency)
+
+		jobNames, err := cmd.Flags().GetStringSlice(flagJobNames)
+		testutils.NoError(testutils.Require(t), err)
+		testutils.Equal(t, 2, len(jobNames))
+
+		jobStatuses, err := cmd.Flags().GetStringSlice(flagJobStatuses)
+		testutils.NoError(testutils.Require(t), err)
+		testutils.Equal(testutils.Require(t), 1, len(jobStatuses))
+		testutils.Equal(t, "canceled", jobStatuses[0])
+
+		keepLatestBy, err := cmd.Flags().GetString(flagKeepLatestBy)
+		testutils.NoError(testutils.Require(t), err)
+		testutils.Equal(t, "ref", keepLatestBy)
+
+		maxArtifactsSize := cmd.Flags().Lookup(flagMaxArtifactsSize)
+		testutils.NotNil(testutils.Require(t), maxArtifactsSize)
+		testutils.Equal(t, "5GiB", maxArtifactsSize.Value.String())
+
+		projectConcurrency, err := cmd.Flags().GetInt(flagProjectConcurrency)
+		testutils.NoError(testutils.Require(t), err)
+		testutils.Equal(t, 5, projectConcurrency)
+
+		protectedThreshold, err := cmd.Flags().GetDuration(flagProtectedThreshold)
+		testutils.NoError(testutils.Require(t), err)
+		testutils.Equal(t, 8760*time.Hour, protectedThreshold)
+
+		report, err := cmd.Flags().GetString(flagReport)
+		testutils.NoError(testutils.Require(t), err)
+		testutils.Equal(t, "report.md", report)
+
+		reportFormat, err := cmd.Flags().GetString(flagReportFormat)
+		testutils.NoError(testutils.Require(t), err)
+		testutils.Equal(t, "markdown", reportFormat)
+
+		resume, err := cmd.Flags().GetBool(flagResume)
+		testutils.NoError(testutils.Require(t), err)
+		testutils.True(t, resume)
+
+		stateFile, err := cmd.Flags().GetString(flagStateFile)
+		testutils.NoError(testutils.Require(t), err)
+		testutils.Equal(t, "state.json", stateFile)
+
 		thresholdDuration, err := cmd.Flags().GetDuration(flagThresholdDuration)
 		testutils.NoError(testutils.Require(t), err)
 		testutils.Equal(t, 72*time.Hour, thresholdDuration)
@@ -161,3 +328,39 @@ func TestArtifactsFlags(t *testing.T) {
 		testutils.Equal(t, `^$CI_PROJECT_NAMESPACE\/.*$`, paths[0])
 	})
 }
+
+func TestWriteReport(t *testing.T) {
+	t.Run("success_no_path", func(t *testing.T) {
+		// Act
+		err := writeReport("", artifacts.ReportJSON, artifacts.Summary{})
+
+		// Assert
+		testutils.NoError(t, err)
+	})
+
+	t.Run("error_write", func(t *testing.T) {
+		// Arrange
+		path := filepath.Join(t.TempDir(), "unknown", "report.json")
+
+		// Act
+		err := writeReport(path, artifacts.ReportJSON, artifacts.Summary{})
+
+		// Assert
+		testutils.Error(testutils.Require(t), err)
+		testutils.Contains(t, err.Error(), "write report")
+	})
+
+	t.Run("success", func(t *testing.T) {
+		// Arrange
+		path := filepath.Join(t.TempDir(), "report.csv")
+
+		// Act
+		err := writeReport(path, artifacts.ReportCSV, artifacts.Summary{Reports: []artifacts.ProjectReport{{ID: 7, Path: "group/project"}}})
+
+		// Assert
+		testutils.NoError(testutils.Require(t), err)
+		bytes, err := os.ReadFile(path)
+		testutils.NoError(testutils.Require(t), err)
+		testutils.Contains(t, string(bytes), "7,group/project,,,,,,,")
+	})
+}
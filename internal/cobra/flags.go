@@ -0,0 +1,269 @@
+package cobra
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	gitlab "gitlab.com/gitlab-org/api/client-go/v2"
+
+	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/artifacts/engine"
+	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/ratelimit"
+	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/tracing"
+)
+
+const envPrefix = "cleaner-"
+
+const (
+	flagArchivedOnly             = "archived-only"
+	flagArchivedOnlyThreshold    = "archived-only-threshold-duration"
+	flagDryRun                   = "dry-run"
+	flagExcludePaths             = "exclude-paths"
+	flagGracePeriod              = "grace-period"
+	flagGroups                   = "groups"
+	flagIdleDuration             = "idle-duration"
+	flagIncludeArchived          = "include-archived"
+	flagIncludeArchivedThreshold = "include-archived-threshold-duration"
+	flagJobConcurrency           = "job-concurrency"
+	flagMetricsListen            = "metrics-listen"
+	flagMetricsTextfile          = "metrics-textfile"
+	flagPaths                    = "paths"
+	flagProjectConcurrency       = "project-concurrency"
+	flagRateLimit                = "rate-limit"
+	flagRetryBaseDelay           = "retry-base-delay"
+	flagRetryJitter              = "retry-jitter"
+	flagRetryMaxAttempts         = "retry-max-attempts"
+	flagRetryStatusCodes         = "retry-status-codes"
+	flagServer                   = "server"
+	flagThresholdDuration        = "threshold-duration"
+	flagToken                    = "token"
+	flagTopics                   = "topics"
+	flagVisibilities             = "visibilities"
+)
+
+// cleanFlags represents the flags shared between all cleaning commands (artifacts, registry, etc.).
+type cleanFlags struct {
+	archivedOnly             bool
+	archivedOnlyThreshold    time.Duration
+	dryRun                   bool
+	excludePaths             []string
+	gracePeriod              time.Duration
+	groups                   []string
+	idleDuration             time.Duration
+	includeArchived          bool
+	includeArchivedThreshold time.Duration
+	jobConcurrency           int
+	metricsListen            string
+	metricsTextfile          string
+	paths                    []string
+	projectConcurrency       int
+	rateLimit                float64
+	retry                    engine.RetryPolicy
+	server                   string
+	thresholdDuration        time.Duration
+	token                    string
+	topics                   []string
+	visibilities             []string
+
+	// pathsOptional is set when paths are provided by another mean (e.g. a configuration file).
+	pathsOptional bool
+}
+
+// newCleanFlags creates a new cleanFlags with default values.
+func newCleanFlags() *cleanFlags {
+	return &cleanFlags{
+		gracePeriod:        30 * time.Second,
+		jobConcurrency:     100,
+		projectConcurrency: 10,
+		retry:              engine.DefaultRetryPolicy(),
+		thresholdDuration:  7 * 24 * time.Hour,
+	}
+}
+
+// register adds all cleaning flags to the input command.
+//
+// items represents what is cleaned by the command (e.g. "jobs' artifacts") and is used in flags usage.
+func (f *cleanFlags) register(cmd *cobra.Command, items string) {
+	f.registerProjects(cmd, "gitlab read/write token with maintainer rights to delete "+items)
+
+	// dry run
+	cmd.Flags().BoolVar(&f.dryRun, flagDryRun, false, "truthy if run must not delete "+items+" but only list matched projects")
+
+	// threshold duration
+	cmd.Flags().DurationVar(&f.thresholdDuration, flagThresholdDuration, f.thresholdDuration,
+		"threshold duration (positive) where, "+items+" older than command execution time minus this threshold will be deleted")
+
+	// interruption
+	cmd.Flags().DurationVar(&f.gracePeriod, flagGracePeriod, f.gracePeriod,
+		"duration given to in-flight deletions of "+items+" to finish when the run is interrupted (SIGINT or SIGTERM)")
+
+	// concurrency
+	cmd.Flags().IntVar(&f.jobConcurrency, flagJobConcurrency, f.jobConcurrency,
+		"maximum number of "+items+" deleted concurrently in each project, preventing a project with a lot of them from starving other projects")
+
+	// archived projects
+	cmd.Flags().DurationVar(&f.includeArchivedThreshold, flagIncludeArchivedThreshold, 0,
+		"threshold duration (positive) of archived projects "+items+" with --"+flagIncludeArchived+", threshold duration is used when not provided")
+	cmd.Flags().DurationVar(&f.archivedOnlyThreshold, flagArchivedOnlyThreshold, 0,
+		"threshold duration (positive) of archived projects "+items+" with --"+flagArchivedOnly+", threshold duration is used when not provided")
+
+	// metrics
+	cmd.Flags().StringVar(&f.metricsListen, flagMetricsListen, "", "address (e.g. ':9090') where to expose prometheus metrics on '/metrics' during the run")
+	cmd.Flags().StringVar(&f.metricsTextfile, flagMetricsTextfile, "", "path to a file where to write prometheus metrics at the end of the run (e.g. for node_exporter textfile collector)")
+}
+
+// registerProjects adds gitlab and projects selection flags to the input command.
+//
+// tokenUsage is the usage of token flag since its required rights depend on the command.
+func (f *cleanFlags) registerProjects(cmd *cobra.Command, tokenUsage string) {
+	// gitlab token
+	cmd.Flags().StringVar(&f.token, flagToken, coalesce(os.Getenv("GITLAB_TOKEN"), os.Getenv("GL_TOKEN")), tokenUsage)
+
+	// gitlab server
+	cmd.Flags().StringVar(&f.server, flagServer, coalesce(os.Getenv("CI_API_V4_URL"), os.Getenv("CI_SERVER_HOST")), "gitlab server host")
+
+	// concurrency
+	cmd.Flags().IntVar(&f.projectConcurrency, flagProjectConcurrency, f.projectConcurrency, "maximum number of projects processed concurrently")
+
+	// gitlab api rate limiting
+	cmd.Flags().Float64Var(&f.rateLimit, flagRateLimit, f.rateLimit,
+		"maximum number of gitlab api requests per second shared by all calls (0 for unlimited), automatically lowered when gitlab signals its rate limit is reached")
+
+	// gitlab api retries
+	cmd.Flags().IntVar(&f.retry.MaxAttempts, flagRetryMaxAttempts, f.retry.MaxAttempts, "maximum number of attempts of a failed gitlab api call (including the first one), 1 to disable retries")
+	cmd.Flags().DurationVar(&f.retry.BaseDelay, flagRetryBaseDelay, f.retry.BaseDelay, "delay before the first retry of a failed gitlab api call, doubled for each next retry")
+	cmd.Flags().Float64Var(&f.retry.Jitter, flagRetryJitter, f.retry.Jitter, "ratio (between 0 and 1) of random variation applied to retries delays")
+	cmd.Flags().IntSliceVar(&f.retry.StatusCodes, flagRetryStatusCodes, f.retry.StatusCodes, "list of http status codes for which failed gitlab api calls are retried, network errors are always retried")
+
+	// projects filtering options
+	cmd.Flags().StringSliceVar(&f.paths, flagPaths, nil, "list of valid regexps to match project path (with namespace)")
+	cmd.Flags().StringSliceVar(&f.groups, flagGroups, nil, "list of groups (IDs or paths with namespace) to read projects from (with their subgroups) instead of all projects the token is a maintainer of")
+	cmd.Flags().StringSliceVar(&f.topics, flagTopics, nil, "list of topics projects must all have to be cleaned")
+	cmd.Flags().StringSliceVar(&f.visibilities, flagVisibilities, nil, "list of visibility levels (private, internal or public) projects must have one of to be cleaned")
+	cmd.Flags().DurationVar(&f.idleDuration, flagIdleDuration, 0, "minimum duration (positive) since projects last activity for them to be cleaned")
+	cmd.Flags().StringSliceVar(&f.excludePaths, flagExcludePaths, nil, "list of valid regexps to exclude project path (with namespace), taking precedence over paths")
+
+	// archived projects
+	cmd.Flags().BoolVar(&f.includeArchived, flagIncludeArchived, false, "truthy if archived projects must be cleaned along with other projects")
+	cmd.Flags().BoolVar(&f.archivedOnly, flagArchivedOnly, false, "truthy if only archived projects must be cleaned")
+}
+
+// parse reads environment variables of flags not provided in command line and validates required flags.
+func (f *cleanFlags) parse(cmd *cobra.Command) error {
+	if err := envBool(cmd, flagArchivedOnly, &f.archivedOnly); err != nil {
+		return err
+	}
+	if err := envDuration(cmd, flagArchivedOnlyThreshold, &f.archivedOnlyThreshold); err != nil {
+		return err
+	}
+	if err := envBool(cmd, flagDryRun, &f.dryRun); err != nil {
+		return err
+	}
+	envStrings(cmd, flagExcludePaths, &f.excludePaths)
+	if err := envDuration(cmd, flagGracePeriod, &f.gracePeriod); err != nil {
+		return err
+	}
+	envStrings(cmd, flagGroups, &f.groups)
+	if err := envDuration(cmd, flagIdleDuration, &f.idleDuration); err != nil {
+		return err
+	}
+	if err := envBool(cmd, flagIncludeArchived, &f.includeArchived); err != nil {
+		return err
+	}
+	if err := envDuration(cmd, flagIncludeArchivedThreshold, &f.includeArchivedThreshold); err != nil {
+		return err
+	}
+	if err := envInt(cmd, flagJobConcurrency, &f.jobConcurrency); err != nil {
+		return err
+	}
+	envString(cmd, flagMetricsListen, &f.metricsListen)
+	envString(cmd, flagMetricsTextfile, &f.metricsTextfile)
+	envStrings(cmd, flagPaths, &f.paths)
+	if err := envInt(cmd, flagProjectConcurrency, &f.projectConcurrency); err != nil {
+		return err
+	}
+	if err := envFloat(cmd, flagRateLimit, &f.rateLimit); err != nil {
+		return err
+	}
+	if err := envDuration(cmd, flagRetryBaseDelay, &f.retry.BaseDelay); err != nil {
+		return err
+	}
+	if err := envFloat(cmd, flagRetryJitter, &f.retry.Jitter); err != nil {
+		return err
+	}
+	if err := envInt(cmd, flagRetryMaxAttempts, &f.retry.MaxAttempts); err != nil {
+		return err
+	}
+	if err := envInts(cmd, flagRetryStatusCodes, &f.retry.StatusCodes); err != nil {
+		return err
+	}
+	if err := envDuration(cmd, flagThresholdDuration, &f.thresholdDuration); err != nil {
+		return err
+	}
+	envStrings(cmd, flagTopics, &f.topics)
+	envStrings(cmd, flagVisibilities, &f.visibilities)
+
+	if f.includeArchived && f.archivedOnly {
+		return fmt.Errorf(`"--%s" and "--%s" flags are mutually exclusive`, flagIncludeArchived, flagArchivedOnly)
+	}
+
+	var missings []string
+	// paths aren't required when groups are provided since all their projects can be cleaned
+	if len(f.paths) == 0 && len(f.groups) == 0 && !f.pathsOptional {
+		missings = append(missings, `"`+flagPaths+`"`)
+	}
+	if f.server == "" {
+		missings = append(missings, `"`+flagServer+`"`)
+	}
+	if f.token == "" {
+		missings = append(missings, `"`+flagToken+`"`)
+	}
+	if len(missings) > 0 {
+		return fmt.Errorf("required flag(s) %s not set", strings.Join(missings, ", "))
+	}
+	return nil
+}
+
+// client creates a new gitlab client with server, token and rate limit flags,
+// propagating the trace context of gitlab api calls (see tracing.Interceptor).
+func (f *cleanFlags) client() (*gitlab.Client, error) {
+	limiter := ratelimit.New(f.rateLimit)
+	return gitlab.NewClient(f.token,
+		gitlab.WithBaseURL(f.server),
+		gitlab.WithCustomLimiter(limiter),
+		gitlab.WithInterceptor(limiter.Interceptor),
+		gitlab.WithInterceptor(tracing.Interceptor),
+		gitlab.WithoutRetries())
+}
+
+// options returns the engine run options associated to cleaning flags.
+func (f *cleanFlags) options() []engine.RunOption {
+	archivedMode := engine.ArchivedExclude
+	switch {
+	case f.includeArchived:
+		archivedMode = engine.ArchivedInclude
+	case f.archivedOnly:
+		archivedMode = engine.ArchivedOnly
+	}
+
+	return []engine.RunOption{
+		engine.WithArchivedMode(archivedMode),
+		engine.WithArchivedOnlyThresholdDuration(f.archivedOnlyThreshold),
+		engine.WithDryRun(f.dryRun),
+		engine.WithExcludePaths(f.excludePaths...),
+		engine.WithGracePeriod(f.gracePeriod),
+		engine.WithGroups(f.groups...),
+		engine.WithIdleDuration(f.idleDuration),
+		engine.WithIncludeArchivedThresholdDuration(f.includeArchivedThreshold),
+		engine.WithJobConcurrency(f.jobConcurrency),
+		engine.WithLogger(engine.NewSlogLogger(logger)),
+		engine.WithPaths(f.paths...),
+		engine.WithProjectConcurrency(f.projectConcurrency),
+		engine.WithRetryPolicy(f.retry),
+		engine.WithThresholdDuration(f.thresholdDuration),
+		engine.WithTopics(f.topics...),
+		engine.WithVisibilities(f.visibilities...),
+	}
+}
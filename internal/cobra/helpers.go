@@ -4,9 +4,13 @@ import (
 	"fmt"
 	"log/slog"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
+
+	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/artifacts/engine"
 )
 
 var (
@@ -61,3 +65,115 @@ func usage(parent, child *cobra.Command, err error) {
 		break
 	}
 }
+
+// envBool sets target with flag associated environment variable value when flag isn't provided in command line.
+func envBool(cmd *cobra.Command, flag string, target *bool) error {
+	if cmd.Flags().Changed(flag) {
+		return nil
+	}
+	if env := getenv(envPrefix + flag); env != "" {
+		value, err := strconv.ParseBool(env)
+		if err != nil {
+			return fmt.Errorf(`invalid argument %q for "--%s" flag: %w`, env, flag, err)
+		}
+		*target = value
+	}
+	return nil
+}
+
+// envDuration sets target with flag associated environment variable value when flag isn't provided in command line.
+func envDuration(cmd *cobra.Command, flag string, target *time.Duration) error {
+	if cmd.Flags().Changed(flag) {
+		return nil
+	}
+	if env := getenv(envPrefix + flag); env != "" {
+		value, err := time.ParseDuration(env)
+		if err != nil {
+			return fmt.Errorf(`invalid argument %q for "--%s" flag: %w`, env, flag, err)
+		}
+		*target = value
+	}
+	return nil
+}
+
+// envSize sets target with flag associated environment variable value when flag isn't provided in command line.
+func envSize(cmd *cobra.Command, flag string, target *engine.Size) error {
+	if cmd.Flags().Changed(flag) {
+		return nil
+	}
+	if env := getenv(envPrefix + flag); env != "" {
+		if err := target.Set(env); err != nil {
+			return fmt.Errorf(`invalid argument %q for "--%s" flag: %w`, env, flag, err)
+		}
+	}
+	return nil
+}
+
+// envString sets target with flag associated environment variable value when flag isn't provided in command line.
+func envString(cmd *cobra.Command, flag string, target *string) {
+	if cmd.Flags().Changed(flag) {
+		return
+	}
+	if env := getenv(envPrefix + flag); env != "" {
+		*target = env
+	}
+}
+
+// envStrings sets target with flag associated environment variable value (comma separated) when flag isn't provided in command line.
+func envStrings(cmd *cobra.Command, flag string, target *[]string) {
+	if cmd.Flags().Changed(flag) {
+		return
+	}
+	if env := getenv(envPrefix + flag); env != "" {
+		*target = strings.Split(env, ",")
+	}
+}
+
+// envInt sets target with flag associated environment variable value when flag isn't provided in command line.
+func envInt(cmd *cobra.Command, flag string, target *int) error {
+	if cmd.Flags().Changed(flag) {
+		return nil
+	}
+	if env := getenv(envPrefix + flag); env != "" {
+		value, err := strconv.Atoi(env)
+		if err != nil {
+			return fmt.Errorf(`invalid argument %q for "--%s" flag: %w`, env, flag, err)
+		}
+		*target = value
+	}
+	return nil
+}
+
+// envFloat sets target with flag associated environment variable value when flag isn't provided in command line.
+func envFloat(cmd *cobra.Command, flag string, target *float64) error {
+	if cmd.Flags().Changed(flag) {
+		return nil
+	}
+	if env := getenv(envPrefix + flag); env != "" {
+		value, err := strconv.ParseFloat(env, 64)
+		if err != nil {
+			return fmt.Errorf(`invalid argument %q for "--%s" flag: %w`, env, flag, err)
+		}
+		*target = value
+	}
+	return nil
+}
+
+// envInts sets target with flag associated environment variable value (comma separated) when flag isn't provided in command line.
+func envInts(cmd *cobra.Command, flag string, target *[]int) error {
+	if cmd.Flags().Changed(flag) {
+		return nil
+	}
+	if env := getenv(envPrefix + flag); env != "" {
+		values := make([]int, 0, strings.Count(env, ",")+1)
+		for value := range strings.SplitSeq(env, ",") {
+			i, err := strconv.Atoi(strings.TrimSpace(value))
+			if err != nil {
+				return fmt.Errorf(`invalid argument %q for "--%s" flag: %w`, env, flag, err)
+			}
+			values = append(values, i)
+		}
+		*target = values
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package cobra
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/otel"
+
+	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/metrics"
+	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/tracing"
+)
+
+// instrument serves prometheus metrics and sets up tracing for the duration of a cleaning run,
+// according to metrics flags and OpenTelemetry environment variables.
+//
+// It returns the function to call once the run is done, exporting remaining traces and writing metrics textfile.
+func (f *cleanFlags) instrument(ctx context.Context) (func() error, error) {
+	stop := func() {}
+	if f.metricsListen != "" {
+		var err error
+		if stop, err = serveMetrics(f.metricsListen); err != nil {
+			return nil, err
+		}
+	}
+
+	// tracing is optional, a misconfiguration (or an unreachable collector) mustn't prevent cleaning
+	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) { logger.Warn("failed to export traces", "error", err) }))
+	shutdown, err := tracing.Setup(ctx, "gitlab-storage-cleaner")
+	if err != nil {
+		logger.Warn("failed to setup tracing", "error", err)
+	}
+
+	return func() error {
+		defer stop()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := shutdown(ctx); err != nil {
+			logger.Warn("failed to export traces", "error", err)
+		}
+
+		// metrics are written even when the run failed since failures are part of them
+		if f.metricsTextfile == "" {
+			return nil
+		}
+		if err := prometheus.WriteToTextfile(f.metricsTextfile, metrics.Registry); err != nil {
+			return fmt.Errorf("write metrics textfile: %w", err)
+		}
+		return nil
+	}, nil
+}
+
+// serveMetrics exposes prometheus metrics on '/metrics' of input address in background.
+//
+// It returns a function to stop serving metrics.
+func serveMetrics(address string) (func(), error) {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, fmt.Errorf("listen metrics address: %w", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Warn("failed to serve metrics", "error", err)
+		}
+	}()
+	logger.Info("serving metrics", "address", listener.Addr().String())
+	return func() { _ = server.Close() }, nil
+}
@@ -0,0 +1,97 @@
+package cobra //nolint:testpackage
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/testutils"
+)
+
+func TestInstrument(t *testing.T) {
+	t.Run("error_listen", func(t *testing.T) {
+		// Arrange
+		flags := newCleanFlags()
+		flags.metricsListen = "invalid:address:0"
+
+		// Act
+		_, err := flags.instrument(t.Context())
+
+		// Assert
+		testutils.Error(testutils.Require(t), err)
+		testutils.Contains(t, err.Error(), "listen metrics address")
+	})
+
+	t.Run("error_textfile", func(t *testing.T) {
+		// Arrange
+		flags := newCleanFlags()
+		flags.metricsTextfile = filepath.Join(t.TempDir(), "missing", "cleaner.prom")
+
+		done, err := flags.instrument(t.Context())
+		testutils.NoError(testutils.Require(t), err)
+
+		// Act
+		err = done()
+
+		// Assert
+		testutils.Error(testutils.Require(t), err)
+		testutils.Contains(t, err.Error(), "write metrics textfile")
+	})
+
+	t.Run("success_textfile", func(t *testing.T) {
+		// Arrange
+		flags := newCleanFlags()
+		flags.metricsTextfile = filepath.Join(t.TempDir(), "cleaner.prom")
+
+		done, err := flags.instrument(t.Context())
+		testutils.NoError(testutils.Require(t), err)
+
+		// Act
+		err = done()
+
+		// Assert
+		testutils.NoError(testutils.Require(t), err)
+		bytes, err := os.ReadFile(flags.metricsTextfile)
+		testutils.NoError(testutils.Require(t), err)
+		testutils.Contains(t, string(bytes), "gitlab_storage_cleaner_artifacts_deleted_total")
+	})
+}
+
+func TestServeMetrics(t *testing.T) {
+	t.Run("error_listen", func(t *testing.T) {
+		// Act
+		_, err := serveMetrics("invalid:address:0")
+
+		// Assert
+		testutils.Error(testutils.Require(t), err)
+		testutils.Contains(t, err.Error(), "listen metrics address")
+	})
+
+	t.Run("success", func(t *testing.T) {
+		// Arrange
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		testutils.NoError(testutils.Require(t), err)
+		address := listener.Addr().String()
+		testutils.NoError(testutils.Require(t), listener.Close())
+
+		stop, err := serveMetrics(address)
+		testutils.NoError(testutils.Require(t), err)
+		t.Cleanup(stop)
+
+		// Act
+		request, err := http.NewRequestWithContext(t.Context(), http.MethodGet, "http://"+address+"/metrics", nil)
+		testutils.NoError(testutils.Require(t), err)
+		response, err := http.DefaultClient.Do(request)
+
+		// Assert
+		testutils.NoError(testutils.Require(t), err)
+		defer response.Body.Close()
+		body, err := io.ReadAll(response.Body)
+		testutils.NoError(testutils.Require(t), err)
+		testutils.Equal(t, http.StatusOK, response.StatusCode)
+		testutils.Contains(t, string(body), "gitlab_storage_cleaner_artifacts_deleted_total")
+	})
+}
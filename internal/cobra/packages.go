@@ -0,0 +1,58 @@
+package cobra
+
+import (
+	"errors"
+
+	"github.com/spf13/cobra"
+
+	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/artifacts/engine"
+	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/artifacts/engine/packages"
+)
+
+const flagKeepVersions = "keep-versions"
+
+// packagesCmd creates a new cobra command for cleaning GitLab package registry versions.
+func packagesCmd() *cobra.Command {
+	flags := newCleanFlags()
+	keepVersions := 1
+
+	cmd := &cobra.Command{
+		Use:   "packages",
+		Short: "Clean package registry versions of provided project(s)' gitlab storage",
+		Args: func(cmd *cobra.Command, _ []string) error {
+			if err := envInt(cmd, flagKeepVersions, &keepVersions); err != nil {
+				return err
+			}
+			return flags.parse(cmd)
+		},
+		RunE: func(cmd *cobra.Command, _ []string) (err error) {
+			// check gitlab client
+			client, err := flags.client()
+			if err != nil {
+				return err
+			}
+
+			done, err := flags.instrument(cmd.Context())
+			if err != nil {
+				return err
+			}
+			defer func() { err = errors.Join(err, done()) }()
+
+			opts := append(flags.options(), engine.WithKeepVersions(keepVersions))
+			// run failures don't prevent the summary from being given
+			summary, err := packages.Run(cmd.Context(), client, opts...)
+			logger.Info("ending package versions cleaning",
+				"failures", summary.Failures,
+				"projects", summary.Projects,
+				"packages_cleaned", summary.ItemsCleaned)
+			return err
+		},
+	}
+
+	flags.register(cmd, "package versions")
+
+	// keep rules
+	cmd.Flags().IntVar(&keepVersions, flagKeepVersions, keepVersions, "number of most recent versions to never delete per package (type and name)")
+
+	return cmd
+}
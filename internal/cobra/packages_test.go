@@ -0,0 +1,91 @@
+package cobra //nolint:testpackage
+
+import (
+	"testing"
+
+	"github.com/spf13/cobra"
+
+	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/testutils"
+)
+
+func TestPackagesFlags(t *testing.T) {
+	norun := func(cmd *cobra.Command) *cobra.Command {
+		cmd.RunE = func(*cobra.Command, []string) error {
+			return nil
+		}
+		return cmd
+	}
+
+	t.Run("missing_required", func(t *testing.T) {
+		// Arrange
+		t.Setenv("CI_API_V4_URL", "")
+		t.Setenv("CI_SERVER_HOST", "")
+
+		cmd := norun(packagesCmd())
+
+		// Act
+		err := cmd.ExecuteContext(t.Context())
+
+		// Assert
+		testutils.Error(testutils.Require(t), err)
+		testutils.Contains(t, err.Error(), `required flag(s) "paths", "server", "token" not set`)
+	})
+
+	t.Run("invalid_env", func(t *testing.T) {
+		// Arrange
+		t.Setenv("CI_API_V4_URL", "https://gitlab.example.com/api/v4")
+		t.Setenv("CLEANER_KEEP_VERSIONS", "invalid")
+		t.Setenv("CLEANER_PATHS", "path1,path2")
+		t.Setenv("GITLAB_TOKEN", "token")
+
+		cmd := norun(packagesCmd())
+
+		// Act
+		err := cmd.ExecuteContext(t.Context())
+
+		// Assert
+		testutils.Error(testutils.Require(t), err)
+		testutils.Contains(t, err.Error(), `invalid argument "invalid"`)
+	})
+
+	t.Run("from_env", func(t *testing.T) {
+		// Arrange
+		t.Setenv("CI_API_V4_URL", "https://gitlab.example.com/api/v4")
+		t.Setenv("CLEANER_KEEP_VERSIONS", "5")
+		t.Setenv("CLEANER_PATHS", "path1,path2")
+		t.Setenv("GITLAB_TOKEN", "token")
+
+		cmd := norun(packagesCmd())
+
+		// Act
+		err := cmd.ExecuteContext(t.Context())
+
+		// Assert
+		testutils.NoError(testutils.Require(t), err)
+
+		keepVersions, err := cmd.Flags().GetInt(flagKeepVersions)
+		testutils.NoError(testutils.Require(t), err)
+		testutils.Equal(t, 5, keepVersions)
+	})
+
+	t.Run("flags_override_env", func(t *testing.T) {
+		// Arrange
+		t.Setenv("CI_API_V4_URL", "https://gitlab.example.com/api/v4")
+		t.Setenv("CLEANER_KEEP_VERSIONS", "invalid")
+		t.Setenv("CLEANER_PATHS", "path1,path2")
+		t.Setenv("GITLAB_TOKEN", "token")
+
+		cmd := norun(packagesCmd())
+		cmd.SetArgs([]string{"--" + flagKeepVersions, "3"})
+
+		// Act
+		err := cmd.ExecuteContext(t.Context())
+
+		// Assert
+		testutils.NoError(testutils.Require(t), err)
+
+		keepVersions, err := cmd.Flags().GetInt(flagKeepVersions)
+		testutils.NoError(testutils.Require(t), err)
+		testutils.Equal(t, 3, keepVersions)
+	})
+}
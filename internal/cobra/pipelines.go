@@ -0,0 +1,45 @@
+package cobra
+
+import (
+	"errors"
+
+	"github.com/spf13/cobra"
+
+	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/artifacts/engine/pipelines"
+)
+
+// pipelinesCmd creates a new cobra command for cleaning GitLab pipelines (with their jobs, logs and artifacts).
+func pipelinesCmd() *cobra.Command {
+	flags := newCleanFlags()
+
+	cmd := &cobra.Command{
+		Use:   "pipelines",
+		Short: "Clean pipelines (with their jobs, logs and artifacts) of provided project(s)' gitlab storage",
+		Args:  func(cmd *cobra.Command, _ []string) error { return flags.parse(cmd) },
+		RunE: func(cmd *cobra.Command, _ []string) (err error) {
+			// check gitlab client
+			client, err := flags.client()
+			if err != nil {
+				return err
+			}
+
+			done, err := flags.instrument(cmd.Context())
+			if err != nil {
+				return err
+			}
+			defer func() { err = errors.Join(err, done()) }()
+
+			// run failures don't prevent the summary from being given
+			summary, err := pipelines.Run(cmd.Context(), client, flags.options()...)
+			logger.Info("ending pipelines cleaning",
+				"failures", summary.Failures,
+				"projects", summary.Projects,
+				"pipelines_cleaned", summary.ItemsCleaned)
+			return err
+		},
+	}
+
+	flags.register(cmd, "pipelines")
+
+	return cmd
+}
@@ -0,0 +1,71 @@
+package cobra //nolint:testpackage
+
+import (
+	"testing"
+
+	"github.com/spf13/cobra"
+
+	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/testutils"
+)
+
+func TestPipelinesFlags(t *testing.T) {
+	norun := func(cmd *cobra.Command) *cobra.Command {
+		cmd.RunE = func(*cobra.Command, []string) error {
+			return nil
+		}
+		return cmd
+	}
+
+	t.Run("missing_required", func(t *testing.T) {
+		// Arrange
+		t.Setenv("CI_API_V4_URL", "")
+		t.Setenv("CI_SERVER_HOST", "")
+
+		cmd := norun(pipelinesCmd())
+
+		// Act
+		err := cmd.ExecuteContext(t.Context())
+
+		// Assert
+		testutils.Error(testutils.Require(t), err)
+		testutils.Contains(t, err.Error(), `required flag(s) "paths", "server", "token" not set`)
+	})
+
+	t.Run("from_env", func(t *testing.T) {
+		// Arrange
+		t.Setenv("CI_API_V4_URL", "https://gitlab.example.com/api/v4")
+		t.Setenv("CLEANER_PATHS", "path1,path2")
+		t.Setenv("GITLAB_TOKEN", "token")
+
+		cmd := norun(pipelinesCmd())
+
+		// Act
+		err := cmd.ExecuteContext(t.Context())
+
+		// Assert
+		testutils.NoError(testutils.Require(t), err)
+
+		paths, err := cmd.Flags().GetStringSlice(flagPaths)
+		testutils.NoError(testutils.Require(t), err)
+		testutils.Equal(t, 2, len(paths))
+	})
+	t.Run("groups_without_paths", func(t *testing.T) {
+		// Arrange
+		t.Setenv("CI_API_V4_URL", "https://gitlab.example.com/api/v4")
+		t.Setenv("CLEANER_GROUPS", "group1,42")
+		t.Setenv("CLEANER_PATHS", "")
+		t.Setenv("GITLAB_TOKEN", "token")
+
+		cmd := norun(pipelinesCmd())
+
+		// Act
+		err := cmd.ExecuteContext(t.Context())
+
+		// Assert
+		testutils.NoError(testutils.Require(t), err)
+
+		groups, err := cmd.Flags().GetStringSlice(flagGroups)
+		testutils.NoError(testutils.Require(t), err)
+		testutils.Equal(t, 2, len(groups))
+	})
+}
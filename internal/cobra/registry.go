@@ -0,0 +1,68 @@
+package cobra
+
+import (
+	"errors"
+
+	"github.com/spf13/cobra"
+
+	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/artifacts/engine"
+	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/artifacts/engine/registry"
+)
+
+const (
+	flagKeepLatest = "keep-latest"
+	flagKeepSemver = "keep-semver"
+)
+
+// registryCmd creates a new cobra command for cleaning GitLab container registry tags.
+func registryCmd() *cobra.Command {
+	flags := newCleanFlags()
+	keepLatest, keepSemver := true, true
+
+	cmd := &cobra.Command{
+		Use:   "registry",
+		Short: "Clean container registry tags of provided project(s)' gitlab storage",
+		Args: func(cmd *cobra.Command, _ []string) error {
+			if err := envBool(cmd, flagKeepLatest, &keepLatest); err != nil {
+				return err
+			}
+			if err := envBool(cmd, flagKeepSemver, &keepSemver); err != nil {
+				return err
+			}
+			return flags.parse(cmd)
+		},
+		RunE: func(cmd *cobra.Command, _ []string) (err error) {
+			// check gitlab client
+			client, err := flags.client()
+			if err != nil {
+				return err
+			}
+
+			done, err := flags.instrument(cmd.Context())
+			if err != nil {
+				return err
+			}
+			defer func() { err = errors.Join(err, done()) }()
+
+			opts := append(flags.options(),
+				engine.WithKeepLatest(keepLatest),
+				engine.WithKeepSemver(keepSemver),
+			)
+			// run failures don't prevent the summary from being given
+			summary, err := registry.Run(cmd.Context(), client, opts...)
+			logger.Info("ending registry tags cleaning",
+				"failures", summary.Failures,
+				"projects", summary.Projects,
+				"tags_cleaned", summary.ItemsCleaned)
+			return err
+		},
+	}
+
+	flags.register(cmd, "registry tags")
+
+	// keep rules
+	cmd.Flags().BoolVar(&keepLatest, flagKeepLatest, keepLatest, "truthy if 'latest' tags must never be deleted")
+	cmd.Flags().BoolVar(&keepSemver, flagKeepSemver, keepSemver, "truthy if tags named after a semantic version (e.g. 'v1.2.3') must never be deleted")
+
+	return cmd
+}
@@ -0,0 +1,104 @@
+package cobra //nolint:testpackage
+
+import (
+	"testing"
+
+	"github.com/spf13/cobra"
+
+	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/testutils"
+)
+
+func TestRegistryFlags(t *testing.T) {
+	norun := func(cmd *cobra.Command) *cobra.Command {
+		cmd.RunE = func(*cobra.Command, []string) error {
+			return nil
+		}
+		return cmd
+	}
+
+	t.Run("missing_required", func(t *testing.T) {
+		// Arrange
+		t.Setenv("CI_API_V4_URL", "")
+		t.Setenv("CI_SERVER_HOST", "")
+
+		cmd := norun(registryCmd())
+
+		// Act
+		err := cmd.ExecuteContext(t.Context())
+
+		// Assert
+		testutils.Error(testutils.Require(t), err)
+		testutils.Contains(t, err.Error(), `required flag(s) "paths", "server", "token" not set`)
+	})
+
+	t.Run("invalid_env", func(t *testing.T) {
+		for _, env := range []string{"CLEANER_KEEP_LATEST", "CLEANER_KEEP_SEMVER"} {
+			t.Run(env, func(t *testing.T) {
+				// Arrange
+				t.Setenv("CI_API_V4_URL", "https://gitlab.example.com/api/v4")
+				t.Setenv("CLEANER_PATHS", "path1,path2")
+				t.Setenv("GITLAB_TOKEN", "token")
+				t.Setenv(env, "invalid")
+
+				cmd := norun(registryCmd())
+
+				// Act
+				err := cmd.ExecuteContext(t.Context())
+
+				// Assert
+				testutils.Error(testutils.Require(t), err)
+				testutils.Contains(t, err.Error(), `invalid argument "invalid"`)
+			})
+		}
+	})
+
+	t.Run("from_env", func(t *testing.T) {
+		// Arrange
+		t.Setenv("CI_API_V4_URL", "https://gitlab.example.com/api/v4")
+		t.Setenv("CLEANER_KEEP_LATEST", "false")
+		t.Setenv("CLEANER_KEEP_SEMVER", "false")
+		t.Setenv("CLEANER_PATHS", "path1,path2")
+		t.Setenv("GITLAB_TOKEN", "token")
+
+		cmd := norun(registryCmd())
+
+		// Act
+		err := cmd.ExecuteContext(t.Context())
+
+		// Assert
+		testutils.NoError(testutils.Require(t), err)
+
+		keepLatest, err := cmd.Flags().GetBool(flagKeepLatest)
+		testutils.NoError(testutils.Require(t), err)
+		testutils.False(t, keepLatest)
+
+		keepSemver, err := cmd.Flags().GetBool(flagKeepSemver)
+		testutils.NoError(testutils.Require(t), err)
+		testutils.False(t, keepSemver)
+	})
+
+	t.Run("flags_override_env", func(t *testing.T) {
+		// Arrange
+		t.Setenv("CI_API_V4_URL", "https://gitlab.example.com/api/v4")
+		t.Setenv("CLEANER_KEEP_LATEST", "invalid")
+		t.Setenv("CLEANER_PATHS", "path1,path2")
+		t.Setenv("GITLAB_TOKEN", "token")
+
+		cmd := norun(registryCmd())
+		cmd.SetArgs([]string{"--" + flagKeepLatest + "=false"})
+
+		// Act
+		err := cmd.ExecuteContext(t.Context())
+
+		// Assert
+		testutils.NoError(testutils.Require(t), err)
+
+		keepLatest, err := cmd.Flags().GetBool(flagKeepLatest)
+		testutils.NoError(testutils.Require(t), err)
+		testutils.False(t, keepLatest)
+
+		keepSemver, err := cmd.Flags().GetBool(flagKeepSemver)
+		testutils.NoError(testutils.Require(t), err)
+		testutils.True(t, keepSemver)
+	})
+}
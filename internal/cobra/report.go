@@ -0,0 +1,65 @@
+package cobra
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/spf13/cobra"
+
+	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/artifacts/engine/report"
+)
+
+const (
+	flagFormat = "format"
+	flagSort   = "sort"
+)
+
+// reportCmd creates a new cobra command for reporting GitLab projects storage usage.
+func reportCmd() *cobra.Command {
+	flags := newCleanFlags()
+	format, sort := string(report.FormatTable), "storage"
+
+	cmd := &cobra.Command{
+		Use:   "report",
+		Short: "Report storage usage (artifacts, registry, packages, etc.) of provided project(s) without deleting anything",
+		Args: func(cmd *cobra.Command, _ []string) error {
+			envString(cmd, flagFormat, &format)
+			envString(cmd, flagSort, &sort)
+
+			if !slices.Contains([]report.Format{report.FormatCSV, report.FormatJSON, report.FormatTable}, report.Format(format)) {
+				return fmt.Errorf(`invalid argument %q for "--%s" flag`, format, flagFormat)
+			}
+			if !slices.Contains(report.SortKeys(), sort) {
+				return fmt.Errorf(`invalid argument %q for "--%s" flag`, sort, flagSort)
+			}
+			return flags.parse(cmd)
+		},
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			// check gitlab client
+			client, err := flags.client()
+			if err != nil {
+				return err
+			}
+
+			// run failures don't prevent the (partial) report from being given
+			usages, err := report.Run(cmd.Context(), client, flags.options()...)
+			if err != nil && len(usages) == 0 {
+				return err
+			}
+			if err := report.Sort(usages, sort); err != nil {
+				return err
+			}
+			return errors.Join(err, report.Write(cmd.OutOrStdout(), report.Format(format), usages))
+		},
+	}
+
+	flags.registerProjects(cmd, "gitlab token with rights to read projects statistics (reporter or above), nothing is ever deleted")
+
+	// output
+	cmd.Flags().StringVar(&format, flagFormat, format, "output format, either 'table', 'json' or 'csv' (sizes in bytes with json and csv)")
+	cmd.Flags().StringVar(&sort, flagSort, sort,
+		"sort projects by descending size of 'storage', 'repository', 'artifacts', 'pipeline-artifacts', 'registry', 'packages', 'lfs', 'wiki', 'snippets' or 'uploads', or by 'path'")
+
+	return cmd
+}
@@ -0,0 +1,88 @@
+package cobra //nolint:testpackage
+
+import (
+	"testing"
+
+	"github.com/spf13/cobra"
+
+	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/testutils"
+)
+
+func TestReportFlags(t *testing.T) {
+	norun := func(cmd *cobra.Command) *cobra.Command {
+		cmd.RunE = func(*cobra.Command, []string) error {
+			return nil
+		}
+		return cmd
+	}
+
+	t.Run("missing_required", func(t *testing.T) {
+		// Arrange
+		t.Setenv("CI_API_V4_URL", "")
+		t.Setenv("CI_SERVER_HOST", "")
+
+		cmd := norun(reportCmd())
+
+		// Act
+		err := cmd.ExecuteContext(t.Context())
+
+		// Assert
+		testutils.Error(testutils.Require(t), err)
+		testutils.Contains(t, err.Error(), `required flag(s) "paths", "server", "token" not set`)
+	})
+
+	t.Run("no_cleaning_flags", func(t *testing.T) {
+		// Arrange
+		cmd := reportCmd()
+
+		// Assert
+		for _, flag := range []string{flagDryRun, flagThresholdDuration, flagArchivedOnlyThreshold, flagIncludeArchivedThreshold} {
+			testutils.True(t, cmd.Flags().Lookup(flag) == nil)
+		}
+	})
+
+	t.Run("invalid_env", func(t *testing.T) {
+		for _, env := range []string{"CLEANER_FORMAT", "CLEANER_SORT"} {
+			t.Run(env, func(t *testing.T) {
+				// Arrange
+				t.Setenv("CI_API_V4_URL", "https://gitlab.example.com/api/v4")
+				t.Setenv("CLEANER_PATHS", "path1,path2")
+				t.Setenv("GITLAB_TOKEN", "token")
+				t.Setenv(env, "invalid")
+
+				cmd := norun(reportCmd())
+
+				// Act
+				err := cmd.ExecuteContext(t.Context())
+
+				// Assert
+				testutils.Error(testutils.Require(t), err)
+				testutils.Contains(t, err.Error(), `invalid argument "invalid"`)
+			})
+		}
+	})
+
+	t.Run("from_env", func(t *testing.T) {
+		// Arrange
+		t.Setenv("CI_API_V4_URL", "https://gitlab.example.com/api/v4")
+		t.Setenv("CLEANER_FORMAT", "csv")
+		t.Setenv("CLEANER_PATHS", "path1,path2")
+		t.Setenv("CLEANER_SORT", "artifacts")
+		t.Setenv("GITLAB_TOKEN", "token")
+
+		var format, sort string
+		cmd := reportCmd()
+		cmd.RunE = func(cmd *cobra.Command, _ []string) error {
+			format, sort = cmd.Flag(flagFormat).Value.String(), cmd.Flag(flagSort).Value.String()
+			return nil
+		}
+
+		// Act
+		err := cmd.ExecuteContext(t.Context())
+
+		// Assert
+		testutils.NoError(testutils.Require(t), err)
+		testutils.Equal(t, "csv", format)
+		testutils.Equal(t, "artifacts", sort)
+	})
+}
@@ -1,7 +1,10 @@
 package cobra
 
 import (
+	"context"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/spf13/cobra"
 )
@@ -15,9 +18,24 @@ const (
 func Execute() {
 	cmd := rootCmd()
 	cmd.AddCommand(artifactsCmd())
+	cmd.AddCommand(packagesCmd())
+	cmd.AddCommand(pipelinesCmd())
+	cmd.AddCommand(registryCmd())
+	cmd.AddCommand(reportCmd())
 	cmd.AddCommand(version())
 
-	if err := cmd.Execute(); err != nil {
+	// a first SIGINT or SIGTERM gracefully stops the run (in-flight deletions are given a grace period),
+	// a second one kills it since signals are restored to their default behavior
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	unregister := context.AfterFunc(ctx, func() {
+		stop()
+		logger.Warn("interrupted, stopping run once in-flight deletions are done (interrupt again to force exit)")
+	})
+
+	err := cmd.ExecuteContext(ctx)
+	unregister()
+	stop()
+	if err != nil {
 		subcmd, _, _ := cmd.Find(os.Args[1:])
 		usage(cmd, subcmd, err)
 		logger.Error(err.Error())
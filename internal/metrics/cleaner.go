@@ -0,0 +1,63 @@
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	gitlab "gitlab.com/gitlab-org/api/client-go/v2"
+)
+
+var (
+	// ProjectsScanned counts the projects read from gitlab api, before any filtering.
+	ProjectsScanned = register(prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "gitlab_storage_cleaner_projects_scanned_total",
+		Help: "Number of projects read from gitlab api, before paths and metadata filtering.",
+	}))
+
+	// JobsMatched counts the jobs matched for artifacts deletion.
+	JobsMatched = register(prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "gitlab_storage_cleaner_jobs_matched_total",
+		Help: "Number of jobs matched for artifacts deletion.",
+	}))
+
+	// ArtifactsDeleted counts the jobs whose artifacts were deleted.
+	ArtifactsDeleted = register(prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "gitlab_storage_cleaner_artifacts_deleted_total",
+		Help: "Number of jobs whose artifacts were deleted.",
+	}))
+
+	// ArtifactsDeletedBytes counts the size of deleted jobs' artifacts.
+	ArtifactsDeletedBytes = register(prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "gitlab_storage_cleaner_artifacts_deleted_bytes_total",
+		Help: "Size in bytes of deleted jobs' artifacts.",
+	}))
+
+	// APIErrors counts the failed gitlab api calls by operation and http status code ('none' when no response was received).
+	APIErrors = register(prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "gitlab_storage_cleaner_api_errors_total",
+		Help: "Number of failed gitlab api calls by operation and http status code.",
+	}, []string{"operation", "status_code"}))
+
+	// APIDuration observes the gitlab api calls durations by operation.
+	APIDuration = register(prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "gitlab_storage_cleaner_api_request_duration_seconds",
+		Help:    "Duration in seconds of gitlab api calls by operation.",
+		Buckets: []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30},
+	}, []string{"operation"}))
+)
+
+// ObserveRequest observes a gitlab api call of input operation (e.g. 'list_projects') started at input time,
+// counting it as an error when it failed.
+func ObserveRequest(operation string, start time.Time, response *gitlab.Response, err error) {
+	APIDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+	if err == nil && (response == nil || response.Response == nil || response.StatusCode/100 == 2) {
+		return
+	}
+
+	status := "none"
+	if response != nil && response.Response != nil {
+		status = strconv.Itoa(response.StatusCode)
+	}
+	APIErrors.WithLabelValues(operation, status).Inc()
+}
@@ -0,0 +1,18 @@
+// Package metrics holds the cleaner Prometheus metrics,
+// exposed over http with the default registry or written to a node_exporter textfile with Registry.
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Registry holds the cleaner metrics only (without go runtime and process metrics of the default registry),
+// as such they can be written to a node_exporter textfile without colliding with node_exporter own metrics.
+var Registry = prometheus.NewRegistry()
+
+// register registers input collector in both Registry and the default registry and returns it.
+func register[C prometheus.Collector](collector C) C {
+	Registry.MustRegister(collector)
+	prometheus.MustRegister(collector)
+	return collector
+}
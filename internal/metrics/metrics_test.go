@@ -0,0 +1,99 @@
+package metrics_test
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	gitlab "gitlab.com/gitlab-org/api/client-go/v2"
+
+	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/metrics"
+	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/testutils"
+)
+
+func TestRegistry(t *testing.T) {
+	t.Run("success_textfile", func(t *testing.T) {
+		// Arrange
+		path := filepath.Join(t.TempDir(), "cleaner.prom")
+
+		// Act
+		err := prometheus.WriteToTextfile(path, metrics.Registry)
+
+		// Assert
+		testutils.NoError(testutils.Require(t), err)
+		bytes, err := os.ReadFile(path)
+		testutils.NoError(testutils.Require(t), err)
+		testutils.Contains(t, string(bytes), "gitlab_storage_cleaner_artifacts_deleted_total 0\n")
+		testutils.NotContains(t, string(bytes), "go_goroutines") // default registry collectors
+	})
+
+	t.Run("success_default_registry", func(t *testing.T) {
+		// Act
+		families, err := prometheus.DefaultGatherer.Gather()
+
+		// Assert
+		testutils.NoError(testutils.Require(t), err)
+		var found bool
+		for _, family := range families {
+			found = found || family.GetName() == "gitlab_storage_cleaner_projects_scanned_total"
+		}
+		testutils.True(t, found)
+	})
+}
+
+func TestObserveRequest(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		// Arrange
+		response := &gitlab.Response{Response: &http.Response{StatusCode: http.StatusOK}}
+
+		// Act
+		metrics.ObserveRequest("test_success", time.Now(), response, nil)
+
+		// Assert
+		testutils.Equal(t, 1, observations(t, metrics.APIDuration.WithLabelValues("test_success")))
+		testutils.Equal(t, 0, value(t, metrics.APIErrors.WithLabelValues("test_success", "200")))
+	})
+
+	t.Run("error_status_code", func(t *testing.T) {
+		// Arrange
+		response := &gitlab.Response{Response: &http.Response{StatusCode: http.StatusTooManyRequests}}
+
+		// Act
+		metrics.ObserveRequest("test_status_code", time.Now(), response, errors.New("429 Too Many Requests"))
+
+		// Assert
+		testutils.Equal(t, 1, observations(t, metrics.APIDuration.WithLabelValues("test_status_code")))
+		testutils.Equal(t, 1, value(t, metrics.APIErrors.WithLabelValues("test_status_code", "429")))
+	})
+
+	t.Run("error_no_response", func(t *testing.T) {
+		// Act
+		metrics.ObserveRequest("test_no_response", time.Now(), nil, errors.New("connection refused"))
+
+		// Assert
+		testutils.Equal(t, 1, value(t, metrics.APIErrors.WithLabelValues("test_no_response", "none")))
+	})
+}
+
+// value returns the current value of input counter.
+func value(t *testing.T, counter prometheus.Counter) float64 {
+	t.Helper()
+	var metric dto.Metric
+	testutils.NoError(testutils.Require(t), counter.Write(&metric))
+	return metric.GetCounter().GetValue()
+}
+
+// observations returns the number of observations of input histogram series.
+func observations(t *testing.T, observer prometheus.Observer) uint64 {
+	t.Helper()
+	histogram, ok := observer.(prometheus.Metric)
+	testutils.True(testutils.Require(t), ok)
+	var metric dto.Metric
+	testutils.NoError(testutils.Require(t), histogram.Write(&metric))
+	return metric.GetHistogram().GetSampleCount()
+}
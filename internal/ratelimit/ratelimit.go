@@ -0,0 +1,171 @@
+// Package ratelimit provides a client-side token bucket rate limiter for gitlab api calls,
+// automatically slowing down when gitlab signals that its rate limit is (nearly) reached.
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+const (
+	// minLimit is the lowest rate (in requests per second) the limiter can be slowed down to.
+	minLimit = rate.Limit(0.1)
+
+	// cooldown is the minimal duration between two rate adjustments,
+	// it avoids concurrent responses (all signaling the same thing) to collapse or restore the rate at once.
+	cooldown = time.Second
+
+	// lowRemaining is the ratio of remaining requests (RateLimit-Remaining over RateLimit-Limit) under which the limiter slows down.
+	lowRemaining = 0.1
+
+	// defaultPause is the pause duration after a 429 response without Retry-After nor RateLimit-Reset headers.
+	defaultPause = time.Second
+)
+
+// Limiter is a token bucket rate limiter to be shared by all gitlab api calls (list and delete ones).
+//
+// It implements gitlab.RateLimiter and its Interceptor must be registered in the same gitlab client
+// for it to adapt its rate to gitlab responses:
+//
+//   - a 429 response halves the rate and pauses all calls until Retry-After (or RateLimit-Reset) is elapsed,
+//   - a response with less than 10% of RateLimit-Remaining halves the rate,
+//   - other responses restore progressively the rate up to its configured maximum.
+type Limiter struct {
+	limiter *rate.Limiter
+	max     rate.Limit
+
+	adjusted    time.Time
+	mutex       sync.Mutex
+	pausedUntil time.Time
+}
+
+// New creates a new Limiter allowing at most rps requests per second, rps being unlimited when zero or negative.
+//
+// An unlimited Limiter still pauses calls on 429 responses.
+func New(rps float64) *Limiter {
+	if rps <= 0 {
+		return &Limiter{limiter: rate.NewLimiter(rate.Inf, 0), max: rate.Inf}
+	}
+	burst := max(int(math.Ceil(rps)), 1)
+	return &Limiter{limiter: rate.NewLimiter(rate.Limit(rps), burst), max: rate.Limit(rps)}
+}
+
+// Limit returns the current rate (in requests per second) of the limiter, zero when it's unlimited.
+func (l *Limiter) Limit() float64 {
+	if limit := l.limiter.Limit(); limit != rate.Inf {
+		return float64(limit)
+	}
+	return 0
+}
+
+// Wait blocks until a request is allowed (or input context is done).
+func (l *Limiter) Wait(ctx context.Context) error {
+	l.mutex.Lock()
+	pause := time.Until(l.pausedUntil)
+	l.mutex.Unlock()
+
+	if pause > 0 {
+		timer := time.NewTimer(pause)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return l.limiter.Wait(ctx)
+}
+
+// Interceptor returns an http.RoundTripper observing gitlab responses to adapt the limiter rate.
+//
+// It's meant to be registered with gitlab.WithInterceptor.
+func (l *Limiter) Interceptor(next http.RoundTripper) http.RoundTripper {
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		resp, err := next.RoundTrip(req)
+		if err != nil {
+			return resp, err
+		}
+		l.Observe(resp)
+		return resp, nil
+	})
+}
+
+// Observe adapts the limiter rate to input gitlab response.
+func (l *Limiter) Observe(resp *http.Response) {
+	now := time.Now()
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		if until := now.Add(retryAfter(resp.Header, now)); until.After(l.pausedUntil) {
+			l.pausedUntil = until
+		}
+		l.slowDown(now)
+		return
+	}
+
+	limit, _ := strconv.ParseFloat(resp.Header.Get("RateLimit-Limit"), 64)
+	remaining, err := strconv.ParseFloat(resp.Header.Get("RateLimit-Remaining"), 64)
+	if err == nil && limit > 0 && remaining/limit < lowRemaining {
+		l.slowDown(now)
+		return
+	}
+	l.speedUp(now)
+}
+
+// slowDown halves the limiter rate (not under minLimit).
+func (l *Limiter) slowDown(now time.Time) {
+	if now.Sub(l.adjusted) < cooldown {
+		return
+	}
+	l.adjusted = now
+
+	current := l.limiter.Limit()
+	if current == rate.Inf {
+		return // unlimited limiter only pauses on 429
+	}
+	l.limiter.SetLimitAt(now, max(current/2, minLimit))
+}
+
+// speedUp increases the limiter rate by a tenth of its maximum (not over it).
+func (l *Limiter) speedUp(now time.Time) {
+	current := l.limiter.Limit()
+	if current >= l.max || now.Sub(l.adjusted) < cooldown {
+		return
+	}
+	l.adjusted = now
+	l.limiter.SetLimitAt(now, min(current+l.max/10, l.max))
+}
+
+// retryAfter returns the duration to wait after a 429 response, read from Retry-After or RateLimit-Reset headers.
+func retryAfter(header http.Header, now time.Time) time.Duration {
+	if value := header.Get("Retry-After"); value != "" {
+		if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+			return time.Duration(seconds) * time.Second
+		}
+		if date, err := http.ParseTime(value); err == nil && date.After(now) {
+			return date.Sub(now)
+		}
+	}
+	if value := header.Get("RateLimit-Reset"); value != "" {
+		if epoch, err := strconv.ParseInt(value, 10, 64); err == nil {
+			if reset := time.Unix(epoch, 0); reset.After(now) {
+				return reset.Sub(now)
+			}
+		}
+	}
+	return defaultPause
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
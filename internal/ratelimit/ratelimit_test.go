@@ -0,0 +1,122 @@
+package ratelimit_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/ratelimit"
+	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/testutils"
+)
+
+func TestNew(t *testing.T) {
+	t.Run("success_unlimited", func(t *testing.T) {
+		// Act
+		limiter := ratelimit.New(0)
+
+		// Assert
+		testutils.Equal(t, 0, limiter.Limit())
+		testutils.NoError(t, limiter.Wait(t.Context()))
+	})
+
+	t.Run("success_limited", func(t *testing.T) {
+		// Act
+		limiter := ratelimit.New(10)
+
+		// Assert
+		testutils.Equal(t, 10, limiter.Limit())
+	})
+}
+
+func TestObserve(t *testing.T) {
+	response := func(status int, headers map[string]string) *http.Response {
+		resp := &http.Response{Header: http.Header{}, StatusCode: status}
+		for key, value := range headers {
+			resp.Header.Set(key, value)
+		}
+		return resp
+	}
+
+	t.Run("success_healthy", func(t *testing.T) {
+		// Arrange
+		limiter := ratelimit.New(10)
+
+		// Act
+		limiter.Observe(response(http.StatusOK, map[string]string{"RateLimit-Limit": "600", "RateLimit-Remaining": "500"}))
+
+		// Assert
+		testutils.Equal(t, 10, limiter.Limit())
+	})
+
+	t.Run("success_low_remaining", func(t *testing.T) {
+		// Arrange
+		limiter := ratelimit.New(10)
+
+		// Act
+		limiter.Observe(response(http.StatusOK, map[string]string{"RateLimit-Limit": "600", "RateLimit-Remaining": "10"}))
+		limiter.Observe(response(http.StatusOK, map[string]string{"RateLimit-Limit": "600", "RateLimit-Remaining": "9"})) // in cooldown
+
+		// Assert
+		testutils.Equal(t, 5, limiter.Limit())
+	})
+
+	t.Run("success_too_many_requests_retry_after", func(t *testing.T) {
+		// Arrange
+		limiter := ratelimit.New(10)
+		ctx, cancel := context.WithTimeout(t.Context(), 50*time.Millisecond)
+		t.Cleanup(cancel)
+
+		// Act
+		limiter.Observe(response(http.StatusTooManyRequests, map[string]string{"Retry-After": "60"}))
+		err := limiter.Wait(ctx)
+
+		// Assert
+		testutils.Equal(t, 5, limiter.Limit())
+		testutils.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+
+	t.Run("success_too_many_requests_reset", func(t *testing.T) {
+		// Arrange
+		limiter := ratelimit.New(0)
+		ctx, cancel := context.WithTimeout(t.Context(), 50*time.Millisecond)
+		t.Cleanup(cancel)
+		reset := strconv.FormatInt(time.Now().Add(time.Minute).Unix(), 10)
+
+		// Act
+		limiter.Observe(response(http.StatusTooManyRequests, map[string]string{"RateLimit-Reset": reset}))
+		err := limiter.Wait(ctx)
+
+		// Assert
+		testutils.Equal(t, 0, limiter.Limit()) // unlimited limiter only pauses
+		testutils.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+}
+
+func TestInterceptor(t *testing.T) {
+	// Arrange
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("RateLimit-Limit", "600")
+		w.Header().Set("RateLimit-Remaining", "0")
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(server.Close)
+
+	limiter := ratelimit.New(10)
+	client := &http.Client{Transport: limiter.Interceptor(http.DefaultTransport)}
+
+	req, err := http.NewRequestWithContext(t.Context(), http.MethodGet, server.URL, nil)
+	testutils.NoError(testutils.Require(t), err)
+
+	// Act
+	resp, err := client.Do(req)
+
+	// Assert
+	testutils.NoError(testutils.Require(t), err)
+	defer resp.Body.Close()
+	testutils.Equal(t, 5, limiter.Limit())
+	testutils.Equal(t, "600", resp.Header.Get("RateLimit-Limit"))
+	testutils.Equal(t, "0", resp.Header.Get("RateLimit-Remaining"))
+}
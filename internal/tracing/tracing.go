@@ -0,0 +1,140 @@
+// Package tracing sets up OpenTelemetry tracing, spans being exported with the exporter
+// configured by standard OTEL_* environment variables (OTLP over http or grpc, console or none).
+//
+// Tracing is a no-op until Setup installs a tracer provider, as such instrumented code doesn't need to check whether it's enabled.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+
+	"go.opentelemetry.io/contrib/propagators/autoprop"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName is the name of the tracer creating all cleaner spans.
+const instrumentationName = "github.com/kilianpaquier/gitlab-storage-cleaner"
+
+// Setup installs the global tracer provider and propagator according to standard OTEL_* environment variables
+// (OTEL_TRACES_EXPORTER, OTEL_EXPORTER_OTLP_ENDPOINT, OTEL_EXPORTER_OTLP_PROTOCOL, OTEL_TRACES_SAMPLER, OTEL_PROPAGATORS, etc.).
+//
+// It returns a function to flush remaining spans and shutdown the tracer provider, to be called before exiting.
+// Tracing stays disabled (the returned function being a no-op) when OTEL_SDK_DISABLED is truthy,
+// when OTEL_TRACES_EXPORTER is 'none' or when neither an exporter nor an OTLP endpoint is set.
+func Setup(ctx context.Context, serviceName string) (func(context.Context) error, error) {
+	noop := func(context.Context) error { return nil }
+
+	if disabled, _ := strconv.ParseBool(os.Getenv("OTEL_SDK_DISABLED")); disabled {
+		return noop, nil
+	}
+	if os.Getenv("OTEL_TRACES_EXPORTER") == "" && os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") == "" && os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") == "" {
+		return noop, nil
+	}
+
+	exporter, err := newExporter(ctx)
+	if err != nil {
+		return noop, fmt.Errorf("new span exporter: %w", err)
+	}
+	if exporter == nil {
+		return noop, nil
+	}
+
+	// OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES take precedence over input service name
+	res, err := resource.New(ctx,
+		resource.WithAttributes(semconv.ServiceName(serviceName)),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK())
+	if err != nil {
+		return noop, fmt.Errorf("new resource: %w", err)
+	}
+
+	options := []sdktrace.TracerProviderOption{sdktrace.WithBatcher(exporter), sdktrace.WithResource(res)}
+	if os.Getenv("OTEL_TRACES_SAMPLER") == "" {
+		// root spans are always sampled while children follow their parent decision (e.g. a propagated one),
+		// OTEL_TRACES_SAMPLER is read by the provider when provided
+		options = append(options, sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.AlwaysSample())))
+	}
+	provider := sdktrace.NewTracerProvider(options...)
+
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(autoprop.NewTextMapPropagator()) // W3C trace context and baggage unless OTEL_PROPAGATORS is set
+	return provider.Shutdown, nil
+}
+
+// newExporter returns the span exporter selected with OTEL_TRACES_EXPORTER ('otlp' by default, 'console' or 'none')
+// and OTEL_EXPORTER_OTLP_TRACES_PROTOCOL or OTEL_EXPORTER_OTLP_PROTOCOL ('http/protobuf' by default or 'grpc').
+//
+// OTLP exporters read all other OTEL_EXPORTER_OTLP_* environment variables (endpoint, headers, timeout, etc.).
+// It returns nil with 'none' exporter.
+func newExporter(ctx context.Context) (sdktrace.SpanExporter, error) {
+	switch exporter := os.Getenv("OTEL_TRACES_EXPORTER"); exporter {
+	case "", "otlp":
+	case "console":
+		return stdouttrace.New()
+	case "none":
+		return nil, nil //nolint:nilnil
+	default:
+		return nil, fmt.Errorf("unsupported traces exporter '%s'", exporter)
+	}
+
+	protocol := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_PROTOCOL")
+	if protocol == "" {
+		protocol = os.Getenv("OTEL_EXPORTER_OTLP_PROTOCOL")
+	}
+	switch protocol {
+	case "", "http/protobuf":
+		return otlptracehttp.New(ctx)
+	case "grpc":
+		return otlptracegrpc.New(ctx)
+	default:
+		return nil, fmt.Errorf("unsupported otlp protocol '%s'", protocol)
+	}
+}
+
+// Start starts a new span child of input context current span (or the root of a new trace when there's none).
+//
+// It returns a copy of input context with the new span as current span.
+// The span must be ended with End.
+func Start(ctx context.Context, name string, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, name, trace.WithAttributes(attributes...))
+}
+
+// SetError records input error on the span and marks it as failed, it's a no-op when err is nil.
+func SetError(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// Interceptor returns an http.RoundTripper injecting the current span of requests context into their headers
+// with the global propagator, as such gitlab api calls can be correlated with the run traces.
+//
+// It's meant to be registered with gitlab.WithInterceptor.
+func Interceptor(next http.RoundTripper) http.RoundTripper {
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		req = req.Clone(req.Context()) // a RoundTripper mustn't modify input request
+		otel.GetTextMapPropagator().Inject(req.Context(), propagation.HeaderCarrier(req.Header))
+		return next.RoundTrip(req)
+	})
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
@@ -0,0 +1,241 @@
+package tracing_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/testutils"
+	"github.com/kilianpaquier/gitlab-storage-cleaner/internal/tracing"
+)
+
+// collector is a fake OTLP endpoint (http/protobuf) keeping received spans, their service name and headers.
+type collector struct {
+	authorization string
+	mutex         sync.Mutex
+	service       string
+	spans         []*tracepb.Span
+}
+
+func (c *collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	var request coltracepb.ExportTraceServiceRequest
+	if err := proto.Unmarshal(body, &request); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.authorization = r.Header.Get("Authorization")
+	for _, resource := range request.GetResourceSpans() {
+		for _, attribute := range resource.GetResource().GetAttributes() {
+			if attribute.GetKey() == "service.name" {
+				c.service = attribute.GetValue().GetStringValue()
+			}
+		}
+		for _, scope := range resource.GetScopeSpans() {
+			c.spans = append(c.spans, scope.GetSpans()...)
+		}
+	}
+	w.Header().Set("Content-Type", "application/x-protobuf")
+}
+
+func TestSetup(t *testing.T) {
+	ctx := t.Context()
+
+	// Setup installs a global tracer provider and propagator, they must be restored for other tests
+	provider, propagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	t.Cleanup(func() {
+		otel.SetTracerProvider(provider)
+		otel.SetTextMapPropagator(propagator)
+	})
+
+	t.Run("success_disabled_without_exporter", func(t *testing.T) {
+		// Arrange
+		t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "")
+		t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "")
+		t.Setenv("OTEL_TRACES_EXPORTER", "")
+
+		// Act
+		shutdown, err := tracing.Setup(ctx, "test")
+
+		// Assert
+		testutils.NoError(testutils.Require(t), err)
+		_, span := tracing.Start(ctx, "noop")
+		testutils.False(t, span.IsRecording())
+		span.End()
+		testutils.NoError(t, shutdown(ctx))
+	})
+
+	t.Run("success_disabled_with_exporter_none", func(t *testing.T) {
+		// Arrange
+		t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "http://localhost:4318")
+		t.Setenv("OTEL_TRACES_EXPORTER", "none")
+
+		// Act
+		shutdown, err := tracing.Setup(ctx, "test")
+
+		// Assert
+		testutils.NoError(testutils.Require(t), err)
+		_, span := tracing.Start(ctx, "noop")
+		testutils.False(t, span.IsRecording())
+		testutils.NoError(t, shutdown(ctx))
+	})
+
+	t.Run("success_sdk_disabled", func(t *testing.T) {
+		// Arrange
+		t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "http://localhost:4318")
+		t.Setenv("OTEL_SDK_DISABLED", "true")
+
+		// Act
+		shutdown, err := tracing.Setup(ctx, "test")
+
+		// Assert
+		testutils.NoError(testutils.Require(t), err)
+		_, span := tracing.Start(ctx, "noop")
+		testutils.False(t, span.IsRecording())
+		testutils.NoError(t, shutdown(ctx))
+	})
+
+	t.Run("error_unknown_exporter", func(t *testing.T) {
+		// Arrange
+		t.Setenv("OTEL_TRACES_EXPORTER", "unknown")
+
+		// Act
+		_, err := tracing.Setup(ctx, "test")
+
+		// Assert
+		testutils.Error(testutils.Require(t), err)
+		testutils.Equal(t, "new span exporter: unsupported traces exporter 'unknown'", err.Error())
+	})
+
+	t.Run("error_unknown_protocol", func(t *testing.T) {
+		// Arrange
+		t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "http://localhost:4318")
+		t.Setenv("OTEL_EXPORTER_OTLP_PROTOCOL", "http/json")
+
+		// Act
+		_, err := tracing.Setup(ctx, "test")
+
+		// Assert
+		testutils.Error(testutils.Require(t), err)
+		testutils.Equal(t, "new span exporter: unsupported otlp protocol 'http/json'", err.Error())
+	})
+
+	t.Run("success_grpc", func(t *testing.T) {
+		// Arrange
+		t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "http://localhost:4317")
+		t.Setenv("OTEL_EXPORTER_OTLP_TRACES_PROTOCOL", "grpc")
+
+		// Act
+		shutdown, err := tracing.Setup(ctx, "test")
+
+		// Assert
+		testutils.NoError(testutils.Require(t), err)
+		_, span := tracing.Start(ctx, "span")
+		testutils.True(t, span.IsRecording())
+		testutils.NoError(t, shutdown(ctx)) // no span ended, nothing is exported
+	})
+
+	t.Run("success_sampler", func(t *testing.T) {
+		// Arrange
+		t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "http://localhost:4318")
+		t.Setenv("OTEL_TRACES_SAMPLER", "always_off")
+
+		shutdown, err := tracing.Setup(ctx, "test")
+		testutils.NoError(testutils.Require(t), err)
+
+		// Act
+		_, span := tracing.Start(ctx, "span")
+		span.End()
+
+		// Assert
+		testutils.False(t, span.SpanContext().IsSampled())
+		testutils.NoError(t, shutdown(ctx))
+	})
+
+	t.Run("success_export", func(t *testing.T) {
+		// Arrange
+		collector := &collector{}
+		server := httptest.NewServer(collector)
+		t.Cleanup(server.Close)
+
+		t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", server.URL)
+		t.Setenv("OTEL_EXPORTER_OTLP_HEADERS", "Authorization=Bearer%20token")
+
+		shutdown, err := tracing.Setup(ctx, "test")
+		testutils.NoError(testutils.Require(t), err)
+
+		// Act
+		parentCtx, parent := tracing.Start(ctx, "parent")
+		_, child := tracing.Start(parentCtx, "child")
+		tracing.SetError(child, errors.New("delete artifacts: 403 Forbidden"))
+		child.End()
+		parent.End()
+		err = shutdown(ctx)
+
+		// Assert
+		testutils.NoError(testutils.Require(t), err)
+		testutils.Equal(t, "Bearer token", collector.authorization)
+		testutils.Equal(t, "test", collector.service)
+		testutils.Equal(t, 2, len(collector.spans))
+
+		childSpan, parentSpan := collector.spans[0], collector.spans[1]
+		testutils.Equal(t, "child", childSpan.GetName())
+		testutils.Equal(t, "parent", parentSpan.GetName())
+		testutils.Equal(t, string(parentSpan.GetTraceId()), string(childSpan.GetTraceId()))
+		testutils.Equal(t, string(parentSpan.GetSpanId()), string(childSpan.GetParentSpanId()))
+		testutils.Equal(t, 0, len(parentSpan.GetParentSpanId()))
+		testutils.Equal(t, tracepb.Status_STATUS_CODE_ERROR, childSpan.GetStatus().GetCode())
+		testutils.Equal(t, "delete artifacts: 403 Forbidden", childSpan.GetStatus().GetMessage())
+	})
+}
+
+func TestInterceptor(t *testing.T) {
+	// Arrange
+	propagator := otel.GetTextMapPropagator()
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() { otel.SetTextMapPropagator(propagator) })
+
+	var traceparent string
+	server := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("Traceparent")
+	}))
+	t.Cleanup(server.Close)
+
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{1},
+		SpanID:     trace.SpanID{2},
+		TraceFlags: trace.FlagsSampled,
+	})
+	ctx := trace.ContextWithSpanContext(context.Background(), sc)
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	testutils.NoError(testutils.Require(t), err)
+	client := &http.Client{Transport: tracing.Interceptor(http.DefaultTransport)}
+
+	// Act
+	response, err := client.Do(request)
+
+	// Assert
+	testutils.NoError(testutils.Require(t), err)
+	defer response.Body.Close()
+	testutils.Equal(t, "00-01000000000000000000000000000000-0200000000000000-01", traceparent)
+	testutils.Equal(t, "", request.Header.Get("Traceparent")) // input request isn't modified
+}